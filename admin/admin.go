@@ -0,0 +1,172 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/prizem-io/gateway/config"
+	"github.com/prizem-io/gateway/context"
+	ef "github.com/prizem-io/gateway/errorfactory"
+	"github.com/prizem-io/gateway/server"
+	fasthttpserver "github.com/prizem-io/gateway/server/fasthttp"
+)
+
+type (
+	adminConfig struct {
+		Prefix string `mapstructure:"prefix"`
+		Token  string `mapstructure:"token"`
+	}
+)
+
+var (
+	bearerPrefix = "Bearer "
+
+	_config = adminConfig{
+		Prefix: "/_admin",
+	}
+)
+
+func Initialize(configuration config.Configuration) error {
+	err := configuration.UnmarshalKey("admin", &_config)
+	if err != nil {
+		return err
+	}
+
+	if !Enabled() {
+		log.Info("Admin routes are disabled because admin.token is empty")
+	}
+	return nil
+}
+
+// Enabled reports whether an admin token is configured.  Admin routes are
+// not found without one.
+func Enabled() bool {
+	return _config.Token != ""
+}
+
+// Prefix returns the path that admin routes are served under.
+func Prefix() string {
+	return _config.Prefix
+}
+
+// Routes registers the admin routes.  It is intended to be
+// passed to server.AddBuildRouterCallbacks.
+func Routes(router server.Router) {
+	router.POST(_config.Prefix+"/explain", Protect(ExplainHandler))
+	router.GET(_config.Prefix+"/config", Protect(ExportHandler))
+	router.GET(_config.Prefix+"/config/hash", Protect(ConfigHashHandler))
+}
+
+// Protect only invokes handle for requests that present the configured admin
+// token as a bearer token.  If no token is configured, the route is not found.
+func Protect(handle server.Handle) server.Handle {
+	return func(ctx context.Context) {
+		if !Enabled() {
+			sendError(ctx, ef.New(ctx, "notFound"))
+			return
+		}
+		if !isAdmin(ctx) {
+			sendError(ctx, ef.New(ctx, "forbidden"))
+			return
+		}
+
+		handle(ctx)
+	}
+}
+
+// ExplainHandler reports how the gateway would process the request
+// described in the body without invoking any filters or upstreams.
+func ExplainHandler(ctx context.Context) {
+	var request fasthttpserver.ExplainRequest
+	err := json.Unmarshal(ctx.Rq().Body(), &request)
+	if err != nil {
+		sendError(ctx, ef.New(ctx, "messageNotReadable"))
+		return
+	}
+
+	gateway, ok := ctx.GetDataAccessor().(*server.Gateway)
+	if !ok {
+		sendError(ctx, ef.New(ctx, "internalError"))
+		return
+	}
+
+	ctx.SendEntity(fasthttpserver.Explain(gateway, request))
+}
+
+// ExportHandler serves the configuration of the active gateway as YAML, or
+// as JSON if the "format" parameter is "json".  The hash of the
+// configuration is sent in the ETag header.
+func ExportHandler(ctx context.Context) {
+	format := ctx.Rq().URLParam("format")
+	if format != "" && format != "yaml" && format != "json" {
+		sendError(ctx, ef.New(ctx, "invalidParameter", ef.Params{
+			"param": "format",
+		}))
+		return
+	}
+
+	data, hash, ok := exportGatewayConfig(ctx, format)
+	if !ok {
+		return
+	}
+
+	contentType := "application/x-yaml"
+	if format == "json" {
+		contentType = "application/json"
+	}
+	ctx.Rs().SetHeader("ETag", `"`+hash+`"`)
+	ctx.Rs().SetContentType(contentType)
+	ctx.Rs().SetBody(data)
+}
+
+// ConfigHashHandler reports the hash of the configuration of the active
+// gateway so that the configurations of nodes can be compared.
+func ConfigHashHandler(ctx context.Context) {
+	_, hash, ok := exportGatewayConfig(ctx, "json")
+	if !ok {
+		return
+	}
+
+	ctx.SendEntity(map[string]string{
+		"hash": hash,
+	})
+}
+
+func exportGatewayConfig(ctx context.Context, format string) ([]byte, string, bool) {
+	gateway, ok := ctx.GetDataAccessor().(*server.Gateway)
+	if !ok {
+		sendError(ctx, ef.New(ctx, "internalError"))
+		return nil, "", false
+	}
+
+	gatewayConfig, err := server.ExportGatewayConfig(gateway)
+	if err != nil {
+		sendError(ctx, ef.New(ctx, "internalError"))
+		return nil, "", false
+	}
+
+	data, hash, err := server.MarshalGatewayConfig(gatewayConfig, format)
+	if err != nil {
+		sendError(ctx, ef.New(ctx, "internalError"))
+		return nil, "", false
+	}
+
+	return data, hash, true
+}
+
+func isAdmin(ctx context.Context) bool {
+	auth := ctx.Rq().Header("Authorization")
+	if len(auth) <= len(bearerPrefix) || auth[:len(bearerPrefix)] != bearerPrefix {
+		return false
+	}
+
+	token := auth[len(bearerPrefix):]
+	return subtle.ConstantTimeCompare([]byte(token), []byte(_config.Token)) == 1
+}
+
+func sendError(ctx context.Context, err *ef.APIError) {
+	ctx.Rs().SetStatusCode(err.Status)
+	ctx.SendEntity(err)
+}
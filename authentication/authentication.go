@@ -14,6 +14,12 @@ type (
 		Name() string
 		Authenticate(context.Context, interface{}) (*config.Credential, identity.Identity, error)
 	}
+
+	// SecuritySchemer is implemented by authenticators that can describe
+	// the credentials they accept as an OpenAPI 3 security scheme object.
+	SecuritySchemer interface {
+		SecurityScheme() map[string]interface{}
+	}
 )
 
 var (
@@ -57,6 +63,12 @@ func SetAuthenticators(_authenticators ...Authenticator) {
 	}
 }
 
+// Authenticators returns the registered authenticators in the
+// order in which they are tried.
+func Authenticators() []Authenticator {
+	return authenticators
+}
+
 func DecodeConfig(name string, conf map[string]interface{}) (interface{}, error) {
 	authenticator, ok := authenticatorMap[name]
 	if !ok {
@@ -72,70 +84,115 @@ func DecodeConfig(name string, conf map[string]interface{}) (interface{}, error)
 }
 
 func Handler(ctx context.Context) error {
-	for _, authenticator := range authenticators {
-		name := authenticator.Name()
+	_, err := Authenticate(ctx)
+	return err
+}
 
-		var config interface{}
-		configuration, err := ctx.GetPlugin(name)
-		if err == nil {
-			config = configuration.Config
+// Authenticate runs the authenticators of the service of ctx, or else the
+// registered authenticators, in order and populates the credential,
+// identity, consumer and plan of ctx.  It returns the authenticator that
+// accepted the request's credential, if any.
+func Authenticate(ctx context.Context) (Authenticator, error) {
+	var matched Authenticator
+
+	configurations := ctx.Service().Authenticators
+	count := len(authenticators)
+	if len(configurations) > 0 {
+		count = len(configurations)
+	}
+
+	for i := 0; i < count; i++ {
+		authenticator, config := candidate(ctx, configurations, i)
+		if authenticator == nil {
+			continue
 		}
 
-		credential, identity, err := authenticator.Authenticate(ctx, config)
+		found, err := authenticate(ctx, authenticator, config)
+		if found || err != nil {
+			matched = authenticator
+		}
 		if err != nil {
-			return err
+			return matched, err
 		}
-
-		// The authenticator did not find any valid credential,
-		// continue on to the next
-		if credential == nil {
-			continue
+		if found {
+			break
 		}
+	}
 
-		//if ctx.SubjectType() != credential.SubjectType {
-		if credential.SubjectType != "consumer" {
-			return ef.New(ctx, "invalidCredential")
-		}
+	authenticationType := ctx.Service().AuthenticationType
 
-		if !credential.Enabled {
-			return ef.New(ctx, "credentialDisabled")
-		}
+	if authenticationType != config.AuthenticationTypeNone && ctx.Consumer() == nil {
+		return matched, ef.New(ctx, "notAuthenticated")
+	}
 
-		ctx.SetCredential(credential)
-		ctx.SetIdentity(identity)
+	if authenticationType == config.AuthenticationTypeThreeLegged && ctx.Identity() == nil {
+		return matched, ef.New(ctx, "notAuthenticated")
+	}
 
-		consumer, err := ctx.GetConsumer(credential.SubjectID)
-		if err != nil {
-			return err
-		}
+	return matched, nil
+}
 
-		if consumer == nil {
-			return ef.New(ctx, "invalidCredential")
-		}
+// candidate returns the i-th authenticator to try and its configuration,
+// taken from configurations when the service has any or else from
+// the default plugin instance of each registered authenticator.
+func candidate(ctx context.Context, configurations []config.PluginConfig, i int) (Authenticator, interface{}) {
+	if len(configurations) > 0 {
+		return authenticatorMap[configurations[i].Name], configurations[i].Config
+	}
 
-		ctx.SetConsumer(consumer)
+	authenticator := authenticators[i]
+	configuration, err := ctx.GetPlugin(authenticator.Name())
+	if err != nil {
+		return authenticator, nil
+	}
+	return authenticator, configuration.Config
+}
 
-		if consumer.PlanID != nil {
-			plan, err := ctx.GetPlan(*consumer.PlanID)
-			if err != nil {
-				return err
-			}
+// authenticate runs authenticator with config and reports whether it found
+// a credential, in which case it populates ctx.
+func authenticate(ctx context.Context, authenticator Authenticator, config interface{}) (bool, error) {
+	credential, identity, err := authenticator.Authenticate(ctx, config)
+	if err != nil {
+		return false, err
+	}
 
-			ctx.SetPlan(plan)
-		}
+	// The authenticator did not find any valid credential,
+	// continue on to the next
+	if credential == nil {
+		return false, nil
+	}
 
-		break
+	//if ctx.SubjectType() != credential.SubjectType {
+	if credential.SubjectType != "consumer" {
+		return true, ef.New(ctx, "invalidCredential")
 	}
 
-	authenticationType := ctx.Service().AuthenticationType
+	if !credential.Enabled {
+		return true, ef.New(ctx, "credentialDisabled")
+	}
 
-	if authenticationType != config.AuthenticationTypeNone && ctx.Consumer() == nil {
-		return ef.New(ctx, "notAuthenticated")
+	ctx.SetCredential(credential)
+	ctx.SetIdentity(identity)
+
+	consumer, err := ctx.GetConsumer(credential.SubjectID)
+	if err != nil {
+		return true, err
 	}
 
-	if authenticationType == config.AuthenticationTypeThreeLegged && ctx.Identity() == nil {
-		return ef.New(ctx, "notAuthenticated")
+	if consumer == nil {
+		return true, ef.New(ctx, "invalidCredential")
+	}
+
+	ctx.SetConsumer(consumer)
+
+	if consumer.PlanID != nil {
+		plan, err := ctx.GetPlan(*consumer.PlanID)
+		if err != nil {
+			return true, err
+		}
+
+		ctx.SetPlan(plan)
 	}
 
-	return nil
+	return true, nil
 }
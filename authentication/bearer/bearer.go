@@ -38,6 +38,13 @@ func (a *BearerAuthenticator) Name() string {
 	return "bearer"
 }
 
+func (a *BearerAuthenticator) SecurityScheme() map[string]interface{} {
+	return map[string]interface{}{
+		"type":   "http",
+		"scheme": "bearer",
+	}
+}
+
 func (a *BearerAuthenticator) Initialize(config config.Configuration) error {
 	return nil
 }
@@ -70,7 +77,8 @@ func (a *BearerAuthenticator) Authenticate(ctx context.Context, confuration inte
 		return nil, nil, err
 	}
 
-	if token.Lifespan == config.LifespanSession {
+	// Explaining a request does not extend the session
+	if token.Lifespan == config.LifespanSession && !ctx.DryRun() {
 		_tokener.Touch(token)
 	}
 
@@ -42,6 +42,14 @@ func (a *JWTAuthenticator) Name() string {
 	return "jwt"
 }
 
+func (a *JWTAuthenticator) SecurityScheme() map[string]interface{} {
+	return map[string]interface{}{
+		"type":         "http",
+		"scheme":       "bearer",
+		"bearerFormat": "JWT",
+	}
+}
+
 func (a *JWTAuthenticator) Initialize(configuration config.Configuration) error {
 	hmacKeyFile, err := configuration.GetString("filter.jwt.secretFile")
 	if err != nil {
@@ -16,6 +16,14 @@ type (
 var exists = SetExists{}
 
 func Handler(ctx context.Context) error {
+	_, err := Authorize(ctx)
+	return err
+}
+
+// Authorize sets the claims of ctx from the permissions granted to the
+// consumer and identity and returns the IDs of the granted permissions.
+func Authorize(ctx context.Context) ([]string, error) {
+	var granted []string
 	consumer := ctx.Consumer()
 	identity := ctx.Identity()
 	var consumerPermissions map[string]StringSet
@@ -49,7 +57,7 @@ func Handler(ctx context.Context) error {
 		for permissionID, _ := range allPermissionIDs {
 			permission, err := ctx.GetPermission(permissionID)
 			if err != nil {
-				return nil
+				return granted, nil
 				/*ef.New(ctx, "unknownPermission", ef.Params{
 					"permissionId": permissionId,
 				})*/
@@ -85,6 +93,7 @@ func Handler(ctx context.Context) error {
 					continue
 				}
 
+				granted = append(granted, permission.ID)
 				if len(intersection) == 1 {
 					for action, _ := range intersection {
 						claims.Set(permission.ClaimPath, action)
@@ -93,12 +102,13 @@ func Handler(ctx context.Context) error {
 					claims.Set(permission.ClaimPath, intersection)
 				}
 			} else {
+				granted = append(granted, permission.ID)
 				claims.Set(permission.ClaimPath, permission.ClaimValue)
 			}
 		}
 	}
 
-	return nil
+	return granted, nil
 }
 
 func maxInt(left, right int) int {
@@ -13,6 +13,12 @@ type (
 		Name() string
 		Handle(context.Context) error
 	}
+
+	// TargetResolver is implemented by handlers that can describe
+	// the upstream URL a request would be sent to.
+	TargetResolver interface {
+		Target(context.Context) string
+	}
 )
 
 var (
@@ -28,6 +34,11 @@ func Register(handlers ...Handler) {
 	backends = lookup
 }
 
+func HasHandler(name string) bool {
+	_, exists := backends[name]
+	return exists
+}
+
 func GetConfig(name string, properties map[string]interface{}) (interface{}, error) {
 	backend, ok := backends[name]
 	if !ok {
@@ -46,14 +46,19 @@ func (r *HTTP) String() string {
 	return r.Name()
 }
 
-func (r *HTTP) Handle(ctx context.Context) error {
-	rq := ctx.Rq()
-	rs := ctx.Rs()
+func (r *HTTP) Target(ctx context.Context) string {
 	s := ctx.Service()
 
 	// TODO: Use discovery for hostname
 
-	target := fmt.Sprintf("%s://%s%s", utils.StringDefault(s.Scheme, "http"), s.Hostnames[0], rq.Path())
+	return fmt.Sprintf("%s://%s%s", utils.StringDefault(s.Scheme, "http"), s.Hostnames[0], ctx.Rq().Path())
+}
+
+func (r *HTTP) Handle(ctx context.Context) error {
+	rq := ctx.Rq()
+	rs := ctx.Rs()
+
+	target := r.Target(ctx)
 	req := fasthttp.AcquireRequest()
 	resp := fasthttp.AcquireResponse()
 	defer fasthttp.ReleaseRequest(req)
@@ -0,0 +1,340 @@
+package cache
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/prizem-io/gateway/admin"
+	"github.com/prizem-io/gateway/command"
+	"github.com/prizem-io/gateway/config"
+	"github.com/prizem-io/gateway/context"
+	"github.com/prizem-io/gateway/server"
+	"github.com/prizem-io/gateway/store"
+)
+
+type (
+	cacheConfig struct {
+		Enabled bool `mapstructure:"enabled"`
+		// Size is the maximum number of cached lookups.
+		Size int `mapstructure:"size"`
+		// TTL is how long entities are cached.  They are cached
+		// until invalidated or evicted when it is zero.
+		TTL time.Duration `mapstructure:"ttl"`
+		// NegativeTTL is how long missing entities are cached.
+		// They are not cached when it is zero.
+		NegativeTTL time.Duration `mapstructure:"negativeTtl"`
+	}
+
+	// CachingAccessor is a context.DataAccessor that caches the lookups
+	// of another.
+	CachingAccessor struct {
+		accessor    context.DataAccessor
+		entries     *lru
+		ttl         time.Duration
+		negativeTTL time.Duration
+
+		mutex sync.Mutex
+		// loads are the lookups in progress by key
+		loads map[string]*load
+	}
+
+	// load is a lookup that concurrent misses of its key wait for.
+	load struct {
+		done  sync.WaitGroup
+		value interface{}
+		err   error
+	}
+)
+
+const (
+	byID     = "id"
+	byName   = "name"
+	byClient = "client"
+)
+
+var (
+	_config = cacheConfig{
+		Size:        10000,
+		TTL:         5 * time.Minute,
+		NegativeTTL: 10 * time.Second,
+	}
+	_cache *CachingAccessor
+)
+
+func Initialize(configuration config.Configuration) error {
+	return configuration.UnmarshalKey("cache", &_config)
+}
+
+func Enabled() bool {
+	return _config.Enabled
+}
+
+// Wrap returns a CachingAccessor for accessor using the "cache"
+// configuration.  It is the cache that InvalidateListener and
+// Routes act on.
+func Wrap(accessor context.DataAccessor) context.DataAccessor {
+	_cache = New(accessor, _config.Size, _config.TTL, _config.NegativeTTL)
+	return _cache
+}
+
+// New returns a CachingAccessor that holds up to size lookups of accessor.
+// Entities that are not found are only cached when accessor reports them
+// with a store.NotFoundError.
+func New(accessor context.DataAccessor, size int, ttl, negativeTTL time.Duration) *CachingAccessor {
+	if size <= 0 {
+		size = 1
+	}
+
+	return &CachingAccessor{
+		accessor:    accessor,
+		entries:     newLRU(size),
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		loads:       map[string]*load{},
+	}
+}
+
+func (c *CachingAccessor) GetPlugin(name string) (*config.Plugin, error) {
+	value, err := c.get("plugin", byName, name, func() (interface{}, error) {
+		return c.accessor.GetPlugin(name)
+	})
+	plugin, _ := value.(*config.Plugin)
+	return plugin, err
+}
+
+// GetPluginInstance serves plugin instances when the wrapped accessor
+// implements server.PluginInstanceAccessor.
+func (c *CachingAccessor) GetPluginInstance(id string) (*config.Plugin, error) {
+	instances, ok := c.accessor.(server.PluginInstanceAccessor)
+	if !ok {
+		return nil, fmt.Errorf("Could not find plugin instance: %s", id)
+	}
+	value, err := c.get("plugin", byID, id, func() (interface{}, error) {
+		return instances.GetPluginInstance(id)
+	})
+	plugin, _ := value.(*config.Plugin)
+	return plugin, err
+}
+
+func (c *CachingAccessor) GetConsumer(id string) (*config.Consumer, error) {
+	value, err := c.get("consumer", byID, id, func() (interface{}, error) {
+		return c.accessor.GetConsumer(id)
+	})
+	consumer, _ := value.(*config.Consumer)
+	return consumer, err
+}
+
+func (c *CachingAccessor) GetCredential(id string) (interface{}, error) {
+	return c.get("credential", byID, id, func() (interface{}, error) {
+		return c.accessor.GetCredential(id)
+	})
+}
+
+func (c *CachingAccessor) FindCredential(credentialType, clientID string) (interface{}, error) {
+	return c.get("credential", byClient, credentialType+"|"+clientID, func() (interface{}, error) {
+		return c.accessor.FindCredential(credentialType, clientID)
+	})
+}
+
+func (c *CachingAccessor) GetPlan(id string) (*config.Plan, error) {
+	value, err := c.get("plan", byID, id, func() (interface{}, error) {
+		return c.accessor.GetPlan(id)
+	})
+	plan, _ := value.(*config.Plan)
+	return plan, err
+}
+
+func (c *CachingAccessor) GetPermission(id string) (*config.Permission, error) {
+	value, err := c.get("permission", byID, id, func() (interface{}, error) {
+		return c.accessor.GetPermission(id)
+	})
+	permission, _ := value.(*config.Permission)
+	return permission, err
+}
+
+// Invalidate removes the cached lookups of the kind of entity with id.
+// Lookups of entities that were not found are also removed unless they
+// were looked up by ID, since the entity may now be found by them.
+// All lookups of the kind are removed if id is empty and all lookups
+// if kind is empty.  It returns the number of lookups removed.
+func (c *CachingAccessor) Invalidate(kind, id string) int {
+	// Lookups that start from now on must not wait for loads in progress
+	c.mutex.Lock()
+	c.loads = map[string]*load{}
+	c.mutex.Unlock()
+
+	return c.entries.removeIf(func(e *entry) bool {
+		switch {
+		case kind == "":
+			return true
+		case e.kind != kind:
+			return false
+		case id == "":
+			return true
+		}
+		return e.id == id || e.lookup == id || (e.err != nil && e.by != byID)
+	})
+}
+
+// Stats returns the size of the cache and the hits and misses so far.
+func (c *CachingAccessor) Stats() Stats {
+	return c.entries.snapshot()
+}
+
+func (c *CachingAccessor) get(kind, by, lookup string, loader func() (interface{}, error)) (interface{}, error) {
+	key := kind + ":" + by + ":" + lookup
+	now := time.Now()
+
+	e, generation, ok := c.entries.get(kind, key, now)
+	if ok {
+		return e.value, e.err
+	}
+
+	return c.load(key, func() (interface{}, error) {
+		value, err := loader()
+		e := entry{
+			key:    key,
+			kind:   kind,
+			by:     by,
+			lookup: lookup,
+		}
+
+		switch err.(type) {
+		case nil:
+			e.value = value
+			e.id = entityID(value)
+			if c.ttl > 0 {
+				e.expires = now.Add(c.ttl)
+			}
+		case *store.NotFoundError:
+			if c.negativeTTL <= 0 {
+				return value, err
+			}
+			e.err = err
+			if by == byID {
+				e.id = lookup
+			}
+			e.expires = now.Add(c.negativeTTL)
+		default:
+			return value, err
+		}
+
+		c.entries.add(&e, generation)
+		return value, err
+	})
+}
+
+// load calls loader for the first miss of key and has concurrent misses
+// wait for its result rather than each calling the wrapped accessor.
+func (c *CachingAccessor) load(key string, loader func() (interface{}, error)) (interface{}, error) {
+	c.mutex.Lock()
+	if l, ok := c.loads[key]; ok {
+		c.mutex.Unlock()
+		l.done.Wait()
+		return l.value, l.err
+	}
+	l := &load{}
+	l.done.Add(1)
+	c.loads[key] = l
+	c.mutex.Unlock()
+
+	defer func() {
+		l.done.Done()
+		c.mutex.Lock()
+		if c.loads[key] == l {
+			delete(c.loads, key)
+		}
+		c.mutex.Unlock()
+	}()
+
+	l.value, l.err = loader()
+	return l.value, l.err
+}
+
+// InvalidateListener is a command.Listener for the "invalidate" command.
+// The kind of entity and its ID are given either as the "entity" and "id"
+// parameters or as the "args" parameter, as in "invalidate consumer <id>".
+// Everything is invalidated when no kind is given or it is "all".
+func InvalidateListener(params command.Params) {
+	if _cache == nil {
+		return
+	}
+
+	kind, id := invalidateArgs(params)
+	if kind == "all" {
+		kind = ""
+	}
+
+	removed := _cache.Invalidate(kind, id)
+	log.WithFields(log.Fields{
+		"entity":  kind,
+		"id":      id,
+		"removed": removed,
+	}).Debug("Invalidated cached lookups")
+}
+
+func invalidateArgs(params command.Params) (kind, id string) {
+	if params == nil {
+		return "", ""
+	}
+
+	if args, ok := params["args"]; ok {
+		values := reflect.ValueOf(args)
+		if values.Kind() == reflect.Slice {
+			if values.Len() > 0 {
+				kind = fmt.Sprint(values.Index(0).Interface())
+			}
+			if values.Len() > 1 {
+				id = fmt.Sprint(values.Index(1).Interface())
+			}
+		}
+		return kind, id
+	}
+
+	kind, _ = params["entity"].(string)
+	id, _ = params["id"].(string)
+	return kind, id
+}
+
+// Routes registers the admin routes that report and purge the cache.
+// It is intended to be passed to server.AddBuildRouterCallbacks.
+func Routes(router server.Router) {
+	router.GET(admin.Prefix()+"/cache", admin.Protect(StatsHandler))
+	router.DELETE(admin.Prefix()+"/cache", admin.Protect(PurgeHandler))
+}
+
+func StatsHandler(ctx context.Context) {
+	if _cache == nil {
+		ctx.SendEntity(Stats{})
+		return
+	}
+
+	ctx.SendEntity(_cache.Stats())
+}
+
+func PurgeHandler(ctx context.Context) {
+	if _cache != nil {
+		_cache.Invalidate("", "")
+	}
+
+	ctx.Rs().SetStatusCode(http.StatusNoContent)
+}
+
+// entityID returns the ID field of an entity, if it has one.
+func entityID(entity interface{}) string {
+	value := reflect.Indirect(reflect.ValueOf(entity))
+	if value.Kind() != reflect.Struct {
+		return ""
+	}
+
+	id := value.FieldByName("ID")
+	if id.Kind() != reflect.String {
+		return ""
+	}
+	return id.String()
+}
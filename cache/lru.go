@@ -0,0 +1,163 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+type (
+	// entry is a cached lookup result.  lookup is the value the entity was
+	// looked up by and id is the ID of the entity that was found, which
+	// differs for plugins, found by name, and credentials, found by client.
+	entry struct {
+		key     string
+		kind    string
+		by      string
+		lookup  string
+		id      string
+		value   interface{}
+		err     error
+		expires time.Time
+	}
+
+	// lru is a size-bounded cache that evicts the least recently used entry.
+	lru struct {
+		sync.Mutex
+		capacity int
+		entries  map[string]*list.Element
+		order    *list.List
+		stats    Stats
+		// generation is incremented whenever entries are invalidated so
+		// that lookups that were loading at the time are not added
+		generation uint64
+	}
+
+	Stats struct {
+		Entries       int               `json:"entries"`
+		Capacity      int               `json:"capacity"`
+		Hits          uint64            `json:"hits"`
+		NegativeHits  uint64            `json:"negativeHits"`
+		Misses        uint64            `json:"misses"`
+		HitRatio      float64           `json:"hitRatio"`
+		Evictions     uint64            `json:"evictions"`
+		Expirations   uint64            `json:"expirations"`
+		Invalidations uint64            `json:"invalidations"`
+		Entities      map[string]Counts `json:"entities"`
+	}
+
+	// Counts are the hits and misses for one kind of entity.
+	Counts struct {
+		Hits   uint64 `json:"hits"`
+		Misses uint64 `json:"misses"`
+	}
+)
+
+func newLRU(capacity int) *lru {
+	return &lru{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element, capacity),
+		order:    list.New(),
+		stats: Stats{
+			Entities: map[string]Counts{},
+		},
+	}
+}
+
+// get returns the unexpired entry for key and records a hit or miss.  The
+// generation of the cache is returned for adding the entry after a miss.
+func (c *lru) get(kind, key string, now time.Time) (*entry, uint64, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	counts := c.stats.Entities[kind]
+	defer func() {
+		c.stats.Entities[kind] = counts
+	}()
+
+	element, ok := c.entries[key]
+	if ok {
+		e := element.Value.(*entry)
+		if e.expires.IsZero() || now.Before(e.expires) {
+			c.order.MoveToFront(element)
+			c.stats.Hits++
+			if e.err != nil {
+				c.stats.NegativeHits++
+			}
+			counts.Hits++
+			return e, c.generation, true
+		}
+		c.remove(element)
+		c.stats.Expirations++
+	}
+
+	c.stats.Misses++
+	counts.Misses++
+	return nil, c.generation, false
+}
+
+// add adds e unless entries were invalidated since generation, in which
+// case e may hold the value from before the invalidation.
+func (c *lru) add(e *entry, generation uint64) {
+	c.Lock()
+	defer c.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+
+	if element, ok := c.entries[e.key]; ok {
+		element.Value = e
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[e.key] = c.order.PushFront(e)
+	for c.order.Len() > c.capacity {
+		c.remove(c.order.Back())
+		c.stats.Evictions++
+	}
+}
+
+// removeIf removes the entries that match and returns how many there were.
+func (c *lru) removeIf(match func(e *entry) bool) int {
+	c.Lock()
+	defer c.Unlock()
+
+	c.generation++
+	removed := 0
+	for element := c.order.Front(); element != nil; {
+		next := element.Next()
+		if match(element.Value.(*entry)) {
+			c.remove(element)
+			removed++
+		}
+		element = next
+	}
+	c.stats.Invalidations += uint64(removed)
+
+	return removed
+}
+
+func (c *lru) remove(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.entries, element.Value.(*entry).key)
+}
+
+func (c *lru) snapshot() Stats {
+	c.Lock()
+	defer c.Unlock()
+
+	stats := c.stats
+	stats.Entries = c.order.Len()
+	stats.Capacity = c.capacity
+	if lookups := stats.Hits + stats.Misses; lookups > 0 {
+		stats.HitRatio = float64(stats.Hits) / float64(lookups)
+	}
+	stats.Entities = make(map[string]Counts, len(c.stats.Entities))
+	for kind, counts := range c.stats.Entities {
+		stats.Entities[kind] = counts
+	}
+
+	return stats
+}
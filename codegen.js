@@ -69,12 +69,13 @@ function setVarNames(obj) {
 // MODEL GENERATOR
 ///////////////////////////////////////////////////////////
 
+// Fields that are set at runtime and never serialized
 var additionalFields = {
   PluginConfig: [
-    'Config interface{}'
+    'Config interface{} `json:"-" yaml:"-" msgpack:"-"`'
   ],
   Operation: [
-    'UpstreamConfig interface{}'
+    'BackendConfig interface{} `json:"-" yaml:"-" msgpack:"-"`'
   ]
 }
 
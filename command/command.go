@@ -7,10 +7,35 @@ import (
 type Params map[string]interface{}
 type Listener func(Params)
 
+// Publisher sends a command to every gateway, including this one.
+type Publisher func(command string, payload Params) error
+
 var (
 	listenersByCommandName = map[string][]Listener{}
+	publisher              Publisher
 )
 
+// SetPublisher sets the publisher of Publish.
+func SetPublisher(p Publisher) {
+	publisher = p
+}
+
+// Publish sends a command to every gateway through the publisher, or only
+// notifies the listeners of this gateway if there is none or it fails.
+func Publish(command string, payload Params) {
+	if publisher != nil {
+		err := publisher(command, payload)
+		if err == nil {
+			return
+		}
+		log.WithFields(log.Fields{
+			"command": command,
+		}).Warnf("Could not publish command: %s", err)
+	}
+
+	Notify(command, payload)
+}
+
 func AddListener(command string, listener Listener) {
 	listeners := listenersByCommandName[command]
 	if listeners == nil {
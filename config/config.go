@@ -172,8 +172,9 @@ type Operation struct {
 	PermissionIDs []string       `json:"permissionIds" yaml:"permissionIds" msgpack:"permissionIds" valid:"required"`
 	Claims        []ClaimEntry   `json:"claims" yaml:"claims" msgpack:"claims" valid:"required"`
 	Filters       []PluginConfig `json:"filters" yaml:"filters" msgpack:"filters" valid:"required"`
+	Tags          []string       `json:"tags" yaml:"tags" msgpack:"tags"`
 	Backend       *PluginConfig  `json:"backend" yaml:"backend" msgpack:"backend"`
-	BackendConfig interface{}
+	BackendConfig interface{}    `json:"-" yaml:"-" msgpack:"-"`
 }
 
 type Permission struct {
@@ -228,7 +229,27 @@ type Plugin struct {
 type PluginConfig struct {
 	Name       string                 `json:"name" yaml:"name" msgpack:"name" valid:"required"`
 	Properties map[string]interface{} `json:"properties" yaml:"properties" msgpack:"properties" valid:"required"`
-	Config     interface{}
+	// PluginID references a plugin instance whose name and properties
+	// are used.  Properties that are set here take precedence.
+	PluginID *string     `json:"pluginId" yaml:"pluginId" msgpack:"pluginId"`
+	When     *Predicate  `json:"when" yaml:"when" msgpack:"when"`
+	Config   interface{} `json:"-" yaml:"-" msgpack:"-"`
+}
+
+// Predicate limits a filter to the requests that match every condition
+// that is set.  A condition that lists several values matches any of them.
+type Predicate struct {
+	Methods []string `json:"methods" yaml:"methods" msgpack:"methods"`
+	// Paths are matched against the request path with path.Match.
+	Paths []string `json:"paths" yaml:"paths" msgpack:"paths"`
+	// Headers must all be present and, unless empty, have the given value.
+	Headers      map[string]string `json:"headers" yaml:"headers" msgpack:"headers"`
+	ConsumerTags []string          `json:"consumerTags" yaml:"consumerTags" msgpack:"consumerTags"`
+	PlanIDs      []string          `json:"planIds" yaml:"planIds" msgpack:"planIds"`
+	// Claims must all have the given value.  Nested claims are
+	// separated by dots.
+	Claims    map[string]interface{} `json:"claims" yaml:"claims" msgpack:"claims"`
+	TimeOfDay *TimeOfDay             `json:"timeOfDay" yaml:"timeOfDay" msgpack:"timeOfDay"`
 }
 
 type PrincipalClaims struct {
@@ -316,6 +337,7 @@ type ServiceUpdate struct {
 	ContextRoot          *string                `json:"contextRoot" yaml:"contextRoot" msgpack:"contextRoot"`
 	RequestWeights       map[string]int32       `json:"requestWeights" yaml:"requestWeights" msgpack:"requestWeights" valid:"required"`
 	AuthenticationType   AuthenticationType     `json:"authenticationType" yaml:"authenticationType" msgpack:"authenticationType" valid:"required"`
+	Authenticators       []PluginConfig         `json:"authenticators" yaml:"authenticators" msgpack:"authenticators"`
 	GlobalClaims         []ClaimEntry           `json:"globalClaims" yaml:"globalClaims" msgpack:"globalClaims" valid:"required"`
 	AccessControlEnabled bool                   `json:"accessControlEnabled" yaml:"accessControlEnabled" msgpack:"accessControlEnabled" valid:"required"`
 	Operations           []Operation            `json:"operations" yaml:"operations" msgpack:"operations" valid:"required"`
@@ -340,6 +362,14 @@ type Summary struct {
 	Roles      int64  `json:"roles" yaml:"roles" msgpack:"roles" valid:"required"`
 }
 
+// TimeOfDay is the range of times, as in "09:00", from From up to To.
+// The range wraps around midnight when To is before From.
+type TimeOfDay struct {
+	From     string `json:"from" yaml:"from" msgpack:"from" valid:"required"`
+	To       string `json:"to" yaml:"to" msgpack:"to" valid:"required"`
+	Location string `json:"location" yaml:"location" msgpack:"location"`
+}
+
 type Token struct {
 	Entity        `msgpack:",inline" mapstructure:",squash"`
 	CredentialID  string                 `json:"credentialId" yaml:"credentialId" msgpack:"credentialId"`
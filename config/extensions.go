@@ -43,3 +43,18 @@ func (c *PluginConfig) HandleConfig(f func(*PluginConfig) (interface{}, error))
 	c.Config = conf
 	return nil
 }
+
+// RequestWeight returns the number of units that a request to operation is
+// charged against quotas.  Weights are looked up by operation name, then by
+// method, and default to 1.
+func (s *ServiceUpdate) RequestWeight(operation *Operation) int64 {
+	if operation != nil {
+		if weight, ok := s.RequestWeights[operation.Name]; ok && operation.Name != "" {
+			return int64(weight)
+		}
+		if weight, ok := s.RequestWeights[string(operation.Method)]; ok {
+			return int64(weight)
+		}
+	}
+	return 1
+}
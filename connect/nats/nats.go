@@ -5,14 +5,14 @@ import (
 
 	"github.com/nats-io/nats"
 
-	"github.com/prizem-io/gateway"
+	"github.com/prizem-io/gateway/config"
 )
 
 type natsConfig struct {
 	Url string `mapstructure:"url"`
 }
 
-func Connect(configuration prizem.Configuration) (*nats.Conn, error) {
+func Connect(configuration config.Configuration) (*nats.Conn, error) {
 	var config natsConfig
 	configuration.UnmarshalKey("nats", &config)
 
@@ -0,0 +1,39 @@
+package nats
+
+import (
+	"encoding/json"
+
+	"github.com/nats-io/nats"
+
+	"github.com/prizem-io/gateway/usage"
+)
+
+// NATSUsageSink publishes each usage event as JSON to a subject.
+type NATSUsageSink struct {
+	conn    *nats.Conn
+	subject string
+}
+
+func NewUsageSink(conn *nats.Conn, subject string) *NATSUsageSink {
+	return &NATSUsageSink{
+		conn:    conn,
+		subject: subject,
+	}
+}
+
+func (s *NATSUsageSink) Write(events []usage.Event) error {
+	for i := range events {
+		data, err := json.Marshal(&events[i])
+		if err != nil {
+			return err
+		}
+
+		err = s.conn.Publish(s.subject, data)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Publish only buffers messages
+	return s.conn.Flush()
+}
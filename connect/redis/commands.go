@@ -9,8 +9,26 @@ import (
 	"github.com/prizem-io/gateway/command"
 )
 
+const commandChannel = "prizem"
+
+// CommandPublisher returns a command.Publisher that publishes commands
+// on the channel of CommandSubscribe.
+func CommandPublisher(redisClient *redis.Client) command.Publisher {
+	return func(commandName string, payload command.Params) error {
+		message := commandName
+		if len(payload) > 0 {
+			data, err := json.Marshal(payload)
+			if err != nil {
+				return err
+			}
+			message += " " + string(data)
+		}
+		return redisClient.Publish(commandChannel, message).Err()
+	}
+}
+
 func CommandSubscribe(redisClient *redis.Client) error {
-	pubSub := redisClient.Subscribe("prizem")
+	pubSub := redisClient.Subscribe(commandChannel)
 
 	defer pubSub.Unsubscribe()
 
@@ -29,7 +47,12 @@ func CommandSubscribe(redisClient *redis.Client) error {
 			commandName = message.Payload[0:index]
 			jsonStr := strings.TrimSpace(message.Payload[index:len(message.Payload)])
 			payload = command.Params{}
-			json.Unmarshal([]byte(jsonStr), &payload)
+			if strings.HasPrefix(jsonStr, "{") {
+				json.Unmarshal([]byte(jsonStr), &payload)
+			} else {
+				// Plain arguments, as in "invalidate consumer <id>"
+				payload["args"] = strings.Fields(jsonStr)
+			}
 		}
 
 		command.Notify(commandName, payload)
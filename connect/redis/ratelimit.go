@@ -0,0 +1,104 @@
+package redis
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis"
+
+	"github.com/prizem-io/gateway/filter/ratelimit"
+)
+
+type (
+	redisScripter interface {
+		Eval(script string, keys []string, args ...interface{}) *redis.Cmd
+		EvalSha(sha1 string, keys []string, args ...interface{}) *redis.Cmd
+		ScriptExists(hashes ...string) *redis.BoolSliceCmd
+		ScriptLoad(script string) *redis.StringCmd
+	}
+
+	// RedisRateCounter is a ratelimit.Counter whose counts are shared by
+	// every gateway using the same Redis server.
+	RedisRateCounter struct {
+		redis redisScripter
+	}
+)
+
+// takeScript applies the generic cell rate algorithm to every key at once.
+// Theoretical arrival times are stored in microseconds, formatted so that
+// Lua does not round them.  The arguments are now and the cost of the
+// request followed by the interval and window of each key.  The script
+// returns whether the request was allowed followed by how far ahead of now
+// each arrival time is.
+var takeScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local cost = tonumber(ARGV[2])
+local increments = {}
+local arrivals = {}
+local allowed = 1
+for i = 1, #KEYS do
+	local increment = cost * tonumber(ARGV[2 * i + 1])
+	local window = tonumber(ARGV[2 * i + 2])
+	local arrival = tonumber(redis.call('GET', KEYS[i]) or now)
+	if arrival < now then
+		arrival = now
+	end
+	increments[i] = increment
+	arrivals[i] = arrival
+	if arrival + increment - window > now then
+		allowed = 0
+	end
+end
+
+local result = {allowed}
+for i = 1, #KEYS do
+	local arrival = arrivals[i]
+	if allowed == 1 then
+		arrival = arrival + increments[i]
+		redis.call('SET', KEYS[i], string.format('%.0f', arrival), 'PX', math.ceil((arrival - now) / 1000))
+	end
+	result[i + 1] = arrival - now
+end
+return result
+`)
+
+func NewRateCounter(redis redisScripter) *RedisRateCounter {
+	return &RedisRateCounter{
+		redis: redis,
+	}
+}
+
+func (c *RedisRateCounter) Take(limits []ratelimit.Limit, cost int64, now time.Time) ([]time.Duration, bool, error) {
+	keys := make([]string, len(limits))
+	args := make([]interface{}, 2, 2+2*len(limits))
+	args[0] = now.UnixNano() / int64(time.Microsecond)
+	args[1] = cost
+	for i, limit := range limits {
+		keys[i] = limit.Key
+		args = append(args,
+			int64(limit.Interval/time.Microsecond),
+			int64(limit.Window/time.Microsecond))
+	}
+
+	result, err := takeScript.Run(c.redis, keys, args...).Result()
+	if err != nil {
+		return nil, false, err
+	}
+
+	values, ok := result.([]interface{})
+	if !ok || len(values) != len(limits)+1 {
+		return nil, false, fmt.Errorf("Unexpected rate limit result: %v", result)
+	}
+
+	ahead := make([]time.Duration, len(limits))
+	for i := range limits {
+		micros, ok := values[i+1].(int64)
+		if !ok {
+			return nil, false, fmt.Errorf("Unexpected rate limit result: %v", result)
+		}
+		ahead[i] = time.Duration(micros) * time.Microsecond
+	}
+
+	allowed, _ := values[0].(int64)
+	return ahead, allowed == 1, nil
+}
@@ -0,0 +1,126 @@
+package redis
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/go-redis/redis"
+
+	"github.com/prizem-io/gateway/usage"
+)
+
+type (
+	redisPipeliner interface {
+		Pipelined(fn func(redis.Pipeliner) error) ([]redis.Cmder, error)
+		HGetAll(key string) *redis.StringStringMapCmd
+	}
+
+	// RedisUsageSink adds usage events to a Redis stream as JSON in the
+	// "event" field of each entry.
+	RedisUsageSink struct {
+		redis  redisPipeliner
+		stream string
+		maxLen int64
+	}
+
+	// RedisUsageStore keeps the usage totals of each consumer and period
+	// in a hash whose fields are the plan ID and the name of a total.
+	RedisUsageStore struct {
+		redis  redisPipeliner
+		prefix string
+	}
+)
+
+// NewUsageSink returns a sink that adds events to stream, which is trimmed
+// to about maxLen entries unless maxLen is 0.
+func NewUsageSink(redis redisPipeliner, stream string, maxLen int64) *RedisUsageSink {
+	return &RedisUsageSink{
+		redis:  redis,
+		stream: stream,
+		maxLen: maxLen,
+	}
+}
+
+func (s *RedisUsageSink) Write(events []usage.Event) error {
+	_, err := s.redis.Pipelined(func(pipe redis.Pipeliner) error {
+		for i := range events {
+			data, err := json.Marshal(&events[i])
+			if err != nil {
+				return err
+			}
+
+			// The pinned go-redis has no XAdd, so the command is sent as is
+			args := []interface{}{"XADD", s.stream}
+			if s.maxLen > 0 {
+				args = append(args, "MAXLEN", "~", s.maxLen)
+			}
+			args = append(args, "*", "event", data)
+			pipe.Process(redis.NewStringCmd(args...))
+		}
+		return nil
+	})
+	return err
+}
+
+func NewUsageStore(redis redisPipeliner, prefix string) *RedisUsageStore {
+	return &RedisUsageStore{
+		redis:  redis,
+		prefix: prefix,
+	}
+}
+
+func (s *RedisUsageStore) Add(consumerID, period, planID string, totals usage.Totals) error {
+	key := s.key(consumerID, period)
+	_, err := s.redis.Pipelined(func(pipe redis.Pipeliner) error {
+		pipe.HIncrBy(key, planID+":requests", totals.Requests)
+		pipe.HIncrBy(key, planID+":errors", totals.Errors)
+		pipe.HIncrBy(key, planID+":cost", totals.Cost)
+		pipe.HIncrBy(key, planID+":requestBytes", totals.RequestBytes)
+		pipe.HIncrBy(key, planID+":responseBytes", totals.ResponseBytes)
+		return nil
+	})
+	return err
+}
+
+func (s *RedisUsageStore) Totals(consumerID, period string) (map[string]usage.Totals, error) {
+	fields, err := s.redis.HGetAll(s.key(consumerID, period)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	byPlan := map[string]usage.Totals{}
+	for field, value := range fields {
+		// Plan IDs may contain colons but the names of totals do not
+		index := strings.LastIndex(field, ":")
+		if index == -1 {
+			continue
+		}
+		count, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		planID := field[:index]
+		totals := byPlan[planID]
+		switch field[index+1:] {
+		case "requests":
+			totals.Requests = count
+		case "errors":
+			totals.Errors = count
+		case "cost":
+			totals.Cost = count
+		case "requestBytes":
+			totals.RequestBytes = count
+		case "responseBytes":
+			totals.ResponseBytes = count
+		}
+		byPlan[planID] = totals
+	}
+
+	return byPlan, nil
+}
+
+func (s *RedisUsageStore) key(consumerID, period string) string {
+	return s.prefix + "{" + consumerID + "}:" + period
+}
@@ -15,6 +15,7 @@ type Common struct {
 	dataAccessor      DataAccessor
 	credential        *config.Credential
 	identity          identity.Identity
+	client            *config.Client
 	consumer          *config.Consumer
 	plan              *config.Plan
 	service           *config.Service
@@ -23,6 +24,7 @@ type Common struct {
 	claims            identity.Claims
 	middlewareHandler MiddlewareHandler
 	err               error
+	dryRun            bool
 }
 
 func (c *Common) Initialize(subjectType string) {
@@ -35,6 +37,7 @@ func (c *Common) Reset() {
 	c.dataAccessor = nil
 	c.credential = nil
 	c.identity = nil
+	c.client = nil
 	c.consumer = nil
 	c.plan = nil
 	c.service = nil
@@ -42,6 +45,7 @@ func (c *Common) Reset() {
 	c.claims = nil
 	c.middlewareHandler = nil
 	c.err = nil
+	c.dryRun = false
 }
 
 func (c *Common) RequestID() string {
@@ -76,6 +80,14 @@ func (c *Common) SetIdentity(identity identity.Identity) {
 	c.identity = identity
 }
 
+func (c *Common) Client() *config.Client {
+	return c.client
+}
+
+func (c *Common) SetClient(client *config.Client) {
+	c.client = client
+}
+
 func (c *Common) Consumer() *config.Consumer {
 	return c.consumer
 }
@@ -116,6 +128,16 @@ func (c *Common) SetVersion(version string) {
 	c.version = version
 }
 
+// DryRun reports whether the request is only being explained, in which
+// case handlers must not change any state, such as extending sessions.
+func (c *Common) DryRun() bool {
+	return c.dryRun
+}
+
+func (c *Common) SetDryRun(dryRun bool) {
+	c.dryRun = dryRun
+}
+
 func (c *Common) Claims() identity.Claims {
 	return c.claims
 }
@@ -126,7 +148,10 @@ func (c *Common) GetError() error {
 
 func (c *Common) SetError(err error) {
 	c.err = err
-	c.middlewareHandler.Stop()
+	// Authentication and authorization fail before there are filters
+	if c.middlewareHandler != nil {
+		c.middlewareHandler.Stop()
+	}
 }
 
 func (c *Common) IsStopped() bool {
@@ -40,6 +40,7 @@ type (
 		RequestPath(bool) string
 		RequestIP() string
 		RemoteAddr() string
+		Time() time.Time
 		SetAccepts(string)
 		SetAcceptsBytes([]byte)
 		SetContentType(string)
@@ -112,6 +113,7 @@ type (
 		Reset()
 		ResetBody()
 		SetConnectionClose()
+		StatusCode() int
 		SetStatusCode(int)
 		SetContentLength(contentLength int)
 		SetContentRange(startPos, endPos, contentLength int)
@@ -163,6 +165,8 @@ type (
 		SetCredential(*config.Credential)
 		Identity() identity.Identity
 		SetIdentity(identity.Identity)
+		Client() *config.Client
+		SetClient(*config.Client)
 		Consumer() *config.Consumer
 		SetConsumer(*config.Consumer)
 		Plan() *config.Plan
@@ -173,6 +177,8 @@ type (
 		SetOperation(*config.Operation)
 		Version() string
 		SetVersion(string)
+		DryRun() bool
+		SetDryRun(bool)
 		Claims() identity.Claims
 		Get(string) interface{}
 		GetString(string) string
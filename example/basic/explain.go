@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/viper"
+
+	"github.com/prizem-io/gateway/connect/redis"
+	ef "github.com/prizem-io/gateway/errorfactory"
+	"github.com/prizem-io/gateway/server"
+	fasthttpserver "github.com/prizem-io/gateway/server/fasthttp"
+	"github.com/prizem-io/gateway/utils"
+)
+
+type headerFlags map[string]string
+
+func (h headerFlags) String() string {
+	return fmt.Sprint(map[string]string(h))
+}
+
+func (h headerFlags) Set(value string) error {
+	pair := strings.SplitN(value, ":", 2)
+	if len(pair) != 2 {
+		return fmt.Errorf("Invalid header %q, expected Name: value", value)
+	}
+	h[strings.TrimSpace(pair[0])] = strings.TrimSpace(pair[1])
+	return nil
+}
+
+// explainCommand shows how the gateway would handle a request
+// without invoking any filters or upstreams.
+//
+//	basic explain [-config etc/config.yaml] [-X GET] [-host h] [-H "Name: value"] [-credential token] [-json] path
+func explainCommand(args []string) int {
+	headers := headerFlags{}
+	flags := flag.NewFlagSet("explain", flag.ExitOnError)
+	configFile := flags.String("config", "", "path to the gateway process configuration")
+	method := flags.String("X", "GET", "request method")
+	host := flags.String("host", "", "request host")
+	credential := flags.String("credential", "", "access token to send as a bearer token")
+	asJSON := flags.Bool("json", false, "write the explanation as JSON")
+	flags.Var(headers, "H", "request header, may be repeated")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	err := readConfiguration(*configFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	ef.Initialize("etc/errors")
+	configuration := &utils.ViperConfiguration{}
+	redisClient := redis.Connect(configuration)
+	registerComponents(configuration, redis.NewTokener(redisClient), redis.NewRateCounter(redisClient))
+
+	gateway, err := server.LoadGateway(viper.GetString("gateway.config"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	explanation := fasthttpserver.Explain(gateway, fasthttpserver.ExplainRequest{
+		Method:     *method,
+		Host:       *host,
+		Path:       flags.Arg(0),
+		Headers:    headers,
+		Credential: *credential,
+	})
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(explanation)
+	} else {
+		writeExplanation(explanation)
+	}
+
+	if explanation.Error != nil {
+		return 1
+	}
+	return 0
+}
+
+func writeExplanation(e *fasthttpserver.Explanation) {
+	fmt.Printf("Request:       %s %s\n", e.Request.Method, e.Request.Path)
+	fmt.Printf("Status:        %d\n", e.Status)
+	if e.Service != "" {
+		fmt.Printf("Operation:     %s::%s\n", e.Service, e.Operation)
+	}
+	for key, value := range e.Params {
+		fmt.Printf("Param:         %s = %s\n", key, value)
+	}
+	if e.Authenticator != "" {
+		fmt.Printf("Authenticator: %s\n", e.Authenticator)
+	}
+	if e.Consumer != "" {
+		fmt.Printf("Consumer:      %s (credential %s)\n", e.Consumer, e.CredentialID)
+	}
+	if e.Plan != "" {
+		fmt.Printf("Plan:          %s\n", e.Plan)
+	}
+	if len(e.Permissions) > 0 {
+		fmt.Printf("Permissions:   %s\n", strings.Join(e.Permissions, ", "))
+	}
+	if len(e.Claims) > 0 {
+		claims, _ := json.Marshal(e.Claims)
+		fmt.Printf("Claims:        %s\n", claims)
+	}
+	for i, filter := range e.Filters {
+		configuration, _ := json.Marshal(filter.Configuration)
+		fmt.Printf("Filter %d:      %s (priority %d, from %s) %s\n", i+1, filter.Name, filter.Priority,
+			strings.Join(filter.Sources, ", "), configuration)
+	}
+	if e.Backend != "" {
+		fmt.Printf("Backend:       %s %s\n", e.Backend, e.Target)
+	}
+	if e.Error != nil {
+		fmt.Printf("Error:         %v\n", e.Error)
+	}
+}
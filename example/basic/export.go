@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/spf13/viper"
+
+	"github.com/prizem-io/gateway/server"
+	"github.com/prizem-io/gateway/utils"
+)
+
+// exportCommand processes the gateway configuration as the gateway would
+// and writes it back in canonical form.  The hash of the configuration,
+// which matches the one reported by a gateway running it, is written to
+// stderr.
+//
+//	basic export [-config etc/config.yaml] [-format yaml|json] [-o file] [gateway-config]
+func exportCommand(args []string) int {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	configFile := flags.String("config", "", "path to the gateway process configuration")
+	format := flags.String("format", "yaml", "output format, yaml or json")
+	output := flags.String("o", "", "file to write the configuration to instead of stdout")
+	flags.Parse(args)
+
+	err := readConfiguration(*configFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	configuration := &utils.ViperConfiguration{}
+	registerComponents(configuration, nil, nil)
+
+	configLocation := viper.GetString("gateway.config")
+	if flags.NArg() > 0 {
+		configLocation = flags.Arg(0)
+	}
+
+	gateway, err := server.LoadGateway(configLocation)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	gatewayConfig, err := server.ExportGatewayConfig(gateway)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	data, hash, err := server.MarshalGatewayConfig(gatewayConfig, *format)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if *output == "" {
+		os.Stdout.Write(data)
+	} else {
+		err = ioutil.WriteFile(*output, data, 0644)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+
+	fmt.Fprintln(os.Stderr, hash)
+	return 0
+}
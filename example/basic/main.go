@@ -8,21 +8,34 @@ import (
 	"github.com/spf13/viper"
 	"github.com/valyala/fasthttp"
 
+	"github.com/prizem-io/gateway/admin"
 	"github.com/prizem-io/gateway/authentication"
 	"github.com/prizem-io/gateway/authentication/bearer"
 	"github.com/prizem-io/gateway/authentication/jwt"
 	"github.com/prizem-io/gateway/authorization"
 	"github.com/prizem-io/gateway/backend"
 	"github.com/prizem-io/gateway/backend/http"
+	"github.com/prizem-io/gateway/cache"
 	"github.com/prizem-io/gateway/command"
+	"github.com/prizem-io/gateway/config"
 	"github.com/prizem-io/gateway/connect/redis"
 	ef "github.com/prizem-io/gateway/errorfactory"
 	"github.com/prizem-io/gateway/filter"
+	"github.com/prizem-io/gateway/filter/assertion"
+	"github.com/prizem-io/gateway/filter/concurrency"
+	"github.com/prizem-io/gateway/filter/cors"
+	"github.com/prizem-io/gateway/filter/headers"
+	"github.com/prizem-io/gateway/filter/ipfilter"
 	"github.com/prizem-io/gateway/filter/logger"
+	"github.com/prizem-io/gateway/filter/ratelimit"
+	"github.com/prizem-io/gateway/filter/validate"
 	"github.com/prizem-io/gateway/identity/simple"
+	"github.com/prizem-io/gateway/management"
 	"github.com/prizem-io/gateway/oauth2"
+	"github.com/prizem-io/gateway/openapi"
 	"github.com/prizem-io/gateway/server"
 	fasthttpserver "github.com/prizem-io/gateway/server/fasthttp"
+	"github.com/prizem-io/gateway/usage"
 	"github.com/prizem-io/gateway/utils"
 )
 
@@ -40,17 +53,20 @@ func main() {
 		os.Chdir(wd)
 	}
 
-	var configName = "config"
-	if environment != "" {
-		configName += "." + environment
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "validate":
+			os.Exit(validateCommand(os.Args[2:]))
+		case "explain":
+			os.Exit(explainCommand(os.Args[2:]))
+		case "openapi":
+			os.Exit(openAPICommand(os.Args[2:]))
+		case "export":
+			os.Exit(exportCommand(os.Args[2:]))
+		}
 	}
 
-	log.Println("Reading configuration")
-	viper.SetConfigName(configName)
-	viper.AddConfigPath("./etc/")
-	viper.SetEnvPrefix("core")
-	viper.AutomaticEnv()
-	err := viper.ReadInConfig()
+	err := readConfiguration("")
 	if err != nil { // Handle errors reading the config file
 		panic(fmt.Errorf("Fatal error reading config file: %s", err))
 	}
@@ -61,6 +77,124 @@ func main() {
 	redisClient := redis.Connect(configuration)
 	tokener := redis.NewTokener(redisClient)
 
+	registerComponents(configuration, tokener, redis.NewRateCounter(redisClient))
+
+	err = admin.Initialize(configuration)
+	if err != nil {
+		panic(fmt.Errorf("Error reading admin configuration: %s", err))
+	}
+	err = management.Initialize(configuration)
+	if err != nil {
+		panic(fmt.Errorf("Error reading management configuration: %s", err))
+	}
+	err = cache.Initialize(configuration)
+	if err != nil {
+		panic(fmt.Errorf("Error reading cache configuration: %s", err))
+	}
+	err = openapi.Initialize(configuration)
+	if err != nil {
+		panic(fmt.Errorf("Error reading openapi configuration: %s", err))
+	}
+	err = usage.Initialize(configuration)
+	if err != nil {
+		panic(fmt.Errorf("Error reading usage configuration: %s", err))
+	}
+
+	server.GatewayConfigLocation = viper.GetString("gateway.config")
+
+	err = server.SetTrustedProxies(viper.GetStringSlice("gateway.trustedProxies"))
+	if err != nil {
+		panic(fmt.Errorf("Error reading trusted proxies: %s", err))
+	}
+
+	err = setupStore(redisClient)
+	if err != nil {
+		panic(fmt.Errorf("Error setting up store: %s", err))
+	}
+
+	if usage.Enabled() {
+		err = setupUsage(configuration, redisClient)
+		if err != nil {
+			panic(fmt.Errorf("Error setting up usage metering: %s", err))
+		}
+	}
+
+	server.AddBuildRouterCallbacks(func(router server.Router) {
+		router.POST("/oauth2/token", oauth2.GrantHandler)
+	})
+	server.AddBuildRouterCallbacks(admin.Routes)
+	server.AddBuildRouterCallbacks(validate.Routes)
+	if cache.Enabled() {
+		server.AddBuildRouterCallbacks(cache.Routes)
+	}
+	if openapi.Enabled() {
+		server.AddBuildRouterCallbacks(openapi.Routes)
+	}
+
+	if management.Enabled() {
+		server.AddGatewayConfigProcessors(management.SyncGatewayConfig)
+		if management.Listen() == "" {
+			server.AddBuildRouterCallbacks(management.Routes)
+		} else {
+			go func() {
+				log.Fatal(fasthttp.ListenAndServe(management.Listen(), fasthttpserver.NewHandler(management.Routes)))
+			}()
+		}
+	}
+
+	command.AddListener("reload", func(params command.Params) {
+		err := fasthttpserver.LoadGatewayRouter()
+		if err != nil {
+			log.Warn("Could not reload gateway configuration: " + err.Error())
+		}
+	})
+
+	command.AddListener("invalidate", cache.InvalidateListener)
+
+	err = fasthttpserver.LoadGatewayRouter()
+	if err != nil {
+		panic(fmt.Errorf("Error processing gateway config: %s", err))
+	}
+	command.SetPublisher(redis.CommandPublisher(redisClient))
+	go redis.CommandSubscribe(redisClient)
+
+	if viper.GetBool("gateway.watch.enabled") {
+		watcher, err := server.WatchGatewayConfig(
+			server.GatewayConfigLocation,
+			viper.GetDuration("gateway.watch.interval"),
+			viper.GetDuration("gateway.watch.debounce"),
+			func() {
+				command.Notify("reload", nil)
+			})
+		if err != nil {
+			panic(fmt.Errorf("Error watching gateway config: %s", err))
+		}
+		defer watcher.Close()
+	}
+
+	log.Fatal(fasthttp.ListenAndServe(viper.GetString("gateway.listen"), fasthttpserver.Serve))
+}
+
+// readConfiguration reads configFile or, if empty, the
+// config file for the current environment from ./etc.
+func readConfiguration(configFile string) error {
+	log.Println("Reading configuration")
+	if configFile != "" {
+		viper.SetConfigFile(configFile)
+	} else {
+		var configName = "config"
+		if environment != "" {
+			configName += "." + environment
+		}
+		viper.SetConfigName(configName)
+		viper.AddConfigPath("./etc/")
+	}
+	viper.SetEnvPrefix("core")
+	viper.AutomaticEnv()
+	return viper.ReadInConfig()
+}
+
+func registerComponents(configuration config.Configuration, tokener *redis.RedisTokener, rateCounter ratelimit.Counter) {
 	server.Initialize(configuration)
 	filter.Initialize(configuration)
 	authentication.Initialize(configuration)
@@ -83,6 +217,13 @@ func main() {
 
 	filter.Register(
 		logger.New(),
+		ratelimit.New(rateCounter),
+		concurrency.New(),
+		cors.New(),
+		ipfilter.New(),
+		validate.New(),
+		headers.New(),
+		assertion.New(),
 	)
 
 	backend.Register(
@@ -94,25 +235,6 @@ func main() {
 		authorization.Handler,
 		filter.Handler,
 	)
-
-	server.GatewayConfigLocation = viper.GetString("gateway.config")
-
-	server.AddBuildRouterCallbacks(func(router server.Router) {
-		router.POST("/oauth2/token", oauth2.GrantHandler)
-	})
-
-	command.AddListener("reload", func(params command.Params) {
-		err := fasthttpserver.LoadGatewayRouter()
-		if err != nil {
-			log.Warn("Could not reload gateway configuration: " + err.Error())
-		}
-	})
-
-	err = fasthttpserver.LoadGatewayRouter()
-	if err != nil {
-		panic(fmt.Errorf("Error processing gateway config: %s", err))
-	}
-	go redis.CommandSubscribe(redisClient)
-
-	log.Fatal(fasthttp.ListenAndServe(viper.GetString("gateway.listen"), fasthttpserver.Serve))
+	server.SetPreflightHandler(cors.Preflight)
+	server.SetErrorHandlers(cors.Error)
 }
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/spf13/viper"
+
+	"github.com/prizem-io/gateway/config"
+	"github.com/prizem-io/gateway/openapi"
+	"github.com/prizem-io/gateway/server"
+)
+
+// openAPICommand converts an OpenAPI 2 or 3 document to a service and writes
+// it as gateway configuration.  A service of the same name in the gateway
+// configuration is re-synced, keeping everything the document does not
+// describe.
+//
+//	basic openapi [-config etc/config.yaml] [-name service] [-o file] document
+func openAPICommand(args []string) int {
+	flags := flag.NewFlagSet("openapi", flag.ExitOnError)
+	configFile := flags.String("config", "", "path to the gateway process configuration")
+	name := flags.String("name", "", "service name, defaults to the document title")
+	output := flags.String("o", "", "file to write the service to instead of stdout")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	data, err := ioutil.ReadFile(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	document, err := openapi.Parse(data)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	service, changes, err := openapi.Import(document, *name, nil, func(name string) (*config.Service, error) {
+		return findService(*configFile, name)
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	data, err = marshalService(service)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if *output == "" {
+		os.Stdout.Write(data)
+	} else {
+		err = ioutil.WriteFile(*output, data, 0644)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+
+	writeChanges(changes)
+	return 0
+}
+
+// findService returns the service with name from the gateway configuration,
+// or nil if there is none.
+func findService(configFile, name string) (*config.Service, error) {
+	err := readConfiguration(configFile)
+	if err != nil {
+		return nil, err
+	}
+
+	gatewayConfig, err := server.LoadGatewayConfig(viper.GetString("gateway.config"))
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range gatewayConfig.Services {
+		if gatewayConfig.Services[i].Name == name {
+			return &gatewayConfig.Services[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// marshalService writes service as the services of a gateway configuration,
+// leaving out fields that are not set.
+func marshalService(service *config.Service) ([]byte, error) {
+	data, err := json.Marshal(service)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields interface{}
+	err = json.Unmarshal(data, &fields)
+	if err != nil {
+		return nil, err
+	}
+
+	return yaml.Marshal(map[string]interface{}{
+		"services": []interface{}{withoutNulls(fields)},
+	})
+}
+
+func withoutNulls(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, field := range value {
+			if field == nil {
+				delete(value, key)
+			} else {
+				value[key] = withoutNulls(field)
+			}
+		}
+	case []interface{}:
+		for i, item := range value {
+			value[i] = withoutNulls(item)
+		}
+	}
+	return value
+}
+
+func writeChanges(changes *openapi.Changes) {
+	for _, change := range []struct {
+		label      string
+		operations []string
+	}{
+		{"Added", changes.Added},
+		{"Updated", changes.Updated},
+		{"Removed", changes.Removed},
+		{"Skipped", changes.Skipped},
+	} {
+		if len(change.operations) > 0 {
+			fmt.Fprintf(os.Stderr, "%-9s%s\n", change.label+":", strings.Join(change.operations, ", "))
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+
+	goredis "github.com/go-redis/redis"
+	"github.com/spf13/viper"
+
+	"github.com/prizem-io/gateway/cache"
+	"github.com/prizem-io/gateway/management"
+	"github.com/prizem-io/gateway/server"
+	"github.com/prizem-io/gateway/store"
+	redisstore "github.com/prizem-io/gateway/store/redis"
+	sqlstore "github.com/prizem-io/gateway/store/sql"
+)
+
+// setupStore serves consumers, credentials, permissions, plans and plugins
+// from the store selected by store.driver, through a cache when one is
+// enabled.  Nothing changes if it is unset.
+// The sql driver requires the database/sql driver named by
+// store.sql.driverName to be linked into the binary.
+func setupStore(redisClient *goredis.Client) error {
+	var s store.Store
+
+	switch driver := viper.GetString("store.driver"); driver {
+	case "":
+		return nil
+	case "redis":
+		prefix := redisstore.DefaultPrefix
+		if viper.IsSet("store.redis.prefix") {
+			prefix = viper.GetString("store.redis.prefix")
+		}
+		s = redisstore.New(redisClient, prefix)
+	case "sql":
+		driverName := viper.GetString("store.sql.driverName")
+		db, err := sql.Open(driverName, viper.GetString("store.sql.dataSource"))
+		if err != nil {
+			return err
+		}
+		sqlStore := sqlstore.New(db, driverName)
+		err = sqlStore.CreateSchema()
+		if err != nil {
+			return err
+		}
+		s = sqlStore
+	default:
+		return fmt.Errorf("Unknown store driver %q", driver)
+	}
+
+	if viper.GetBool("store.import") {
+		gatewayConfig, err := server.LoadGatewayConfig(server.GatewayConfigLocation)
+		if err != nil {
+			return err
+		}
+		err = store.Import(s, gatewayConfig)
+		if err != nil {
+			return err
+		}
+	}
+
+	accessor := store.NewDataAccessor(s)
+	if cache.Enabled() {
+		accessor = cache.Wrap(accessor)
+	}
+
+	server.SetDataAccessor(accessor)
+	management.SetStore(management.NewRepositoryStore(s, management.NewMemoryStore()))
+
+	return nil
+}
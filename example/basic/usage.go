@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+
+	goredis "github.com/go-redis/redis"
+	"github.com/spf13/viper"
+
+	"github.com/prizem-io/gateway/config"
+	"github.com/prizem-io/gateway/connect/nats"
+	"github.com/prizem-io/gateway/connect/redis"
+	"github.com/prizem-io/gateway/server"
+	"github.com/prizem-io/gateway/usage"
+)
+
+// setupUsage records a usage event for each request, writes the events to
+// the sink selected by usage.sink and aggregates them into reports that
+// are kept in Redis, or in memory if usage.store is "memory".
+func setupUsage(configuration config.Configuration, redisClient *goredis.Client) error {
+	var store usage.Store
+	switch storeName := viper.GetString("usage.store"); storeName {
+	case "", "redis":
+		store = redis.NewUsageStore(redisClient, viper.GetString("usage.redis.prefix"))
+	case "memory":
+		store = usage.NewMemoryStore()
+	default:
+		return fmt.Errorf("Unknown usage store %q", storeName)
+	}
+
+	sinks := []usage.Sink{usage.NewAggregator(store)}
+	switch sink := viper.GetString("usage.sink"); sink {
+	case "":
+	case "redis":
+		sinks = append(sinks, redis.NewUsageSink(
+			redisClient,
+			viper.GetString("usage.redis.stream"),
+			viper.GetInt64("usage.redis.maxLen")))
+	case "nats":
+		conn, err := nats.Connect(configuration)
+		if err != nil {
+			return err
+		}
+		sinks = append(sinks, nats.NewUsageSink(conn, viper.GetString("usage.nats.subject")))
+	case "file":
+		fileSink, err := usage.NewFileSink(viper.GetString("usage.file"))
+		if err != nil {
+			return err
+		}
+		sinks = append(sinks, fileSink)
+	default:
+		return fmt.Errorf("Unknown usage sink %q", sink)
+	}
+
+	usage.SetStore(store)
+	usage.SetSinks(sinks...)
+	usage.Start()
+
+	server.AddSuccessHandlers(usage.Record)
+	server.AddErrorHandlers(usage.Record)
+	server.AddBuildRouterCallbacks(usage.Routes)
+
+	return nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/spf13/viper"
+
+	"github.com/prizem-io/gateway/authentication"
+	"github.com/prizem-io/gateway/utils"
+	"github.com/prizem-io/gateway/validation"
+)
+
+// validateCommand checks the configuration without starting the listener
+// or connecting to Redis.  It returns the process exit code.
+//
+//	basic validate [-config etc/config.yaml] [-json] [gateway-config]
+func validateCommand(args []string) int {
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	configFile := flags.String("config", "", "path to the gateway process configuration")
+	asJSON := flags.Bool("json", false, "write the report as JSON")
+	flags.Parse(args)
+
+	err := readConfiguration(*configFile)
+	if err != nil {
+		// The file that viper resolved, if it found one
+		location := viper.ConfigFileUsed()
+		if location == "" {
+			location = *configFile
+		}
+		if location == "" {
+			location = "etc"
+		}
+		return writeReport(&validation.Report{
+			Problems: []validation.Problem{{
+				Severity: validation.SeverityError,
+				Location: location,
+				Message:  err.Error(),
+			}},
+		}, *asJSON)
+	}
+
+	configuration := &utils.ViperConfiguration{}
+	registerComponents(configuration, nil, nil)
+	validation.AddPluginRecognizers(authentication.HasAuthenticator)
+
+	configLocation := viper.GetString("gateway.config")
+	if flags.NArg() > 0 {
+		configLocation = flags.Arg(0)
+	}
+
+	return writeReport(validation.Validate(configuration, configLocation), *asJSON)
+}
+
+func writeReport(report *validation.Report, asJSON bool) int {
+	if asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(report)
+	} else {
+		for _, problem := range report.Problems {
+			fmt.Printf("%s: %s: %s\n", problem.Severity, problem.Location, problem.Message)
+		}
+		if report.Valid {
+			fmt.Println("Configuration is valid")
+		}
+	}
+
+	if !report.Valid {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,252 @@
+package assertion
+
+import (
+	"crypto"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/mitchellh/mapstructure"
+
+	"github.com/prizem-io/gateway/config"
+	"github.com/prizem-io/gateway/context"
+	ef "github.com/prizem-io/gateway/errorfactory"
+	"github.com/prizem-io/gateway/identity"
+)
+
+type (
+	// Assertion forwards the verified caller of a request to the backend
+	// as a short-lived JWT signed with a key of the gateway.
+	Assertion struct {
+		PrioritySetting int `mapstructure:"priority"`
+		// Issuer is the "iss" claim of assertions.
+		Issuer string `mapstructure:"issuer"`
+		// Algorithm is HS256, HS384, HS512, RS256, RS384, RS512, ES256,
+		// ES384 or ES512.
+		Algorithm string `mapstructure:"algorithm"`
+		// KeyFile is a secret for HMAC algorithms, or else a PEM encoded
+		// private key.  It must not be the key of client tokens.
+		KeyFile string `mapstructure:"keyFile"`
+		// KeyID is the "kid" header of assertions, if set.
+		KeyID string `mapstructure:"keyId"`
+
+		method jwt.SigningMethod
+		key    crypto.PrivateKey
+		keyErr error
+	}
+
+	// assertionConfig is the assertion of a service.  The static claims of
+	// the service and operation are added to the claims of the caller.
+	assertionConfig struct {
+		// Header carries the assertion, replacing any value from the client.
+		Header string `mapstructure:"header"`
+		// Prefix precedes the assertion in the header, as in "Bearer ".
+		Prefix string `mapstructure:"prefix"`
+		// Audience is the "aud" claim, defaulting to the service name.
+		Audience string        `mapstructure:"audience"`
+		TTL      time.Duration `mapstructure:"ttl"`
+	}
+)
+
+const (
+	defaultHeader = "X-Jwt-Assertion"
+	defaultTTL    = time.Minute
+)
+
+func New() *Assertion {
+	return &Assertion{
+		PrioritySetting: 110,
+		Issuer:          "prizem-gateway",
+		Algorithm:       "HS256",
+	}
+}
+
+func (*Assertion) Name() string {
+	return "assertion"
+}
+
+func (a *Assertion) Priority() int {
+	return a.PrioritySetting
+}
+
+func (a *Assertion) Initialize(config config.Configuration) error {
+	a.keyErr = a.loadKey(config)
+	return a.keyErr
+}
+
+func (a *Assertion) loadKey(config config.Configuration) error {
+	err := config.UnmarshalKey("assertion", a)
+	if err != nil {
+		return err
+	}
+
+	a.method = jwt.GetSigningMethod(a.Algorithm)
+	if a.method == nil {
+		return fmt.Errorf("Unknown assertion algorithm %q", a.Algorithm)
+	}
+	if a.KeyFile == "" {
+		return fmt.Errorf("No assertion key file is configured")
+	}
+
+	data, err := ioutil.ReadFile(a.KeyFile)
+	if err != nil {
+		return err
+	}
+
+	switch a.method.(type) {
+	case *jwt.SigningMethodHMAC:
+		a.key = data
+	case *jwt.SigningMethodRSA:
+		a.key, err = jwt.ParseRSAPrivateKeyFromPEM(data)
+	case *jwt.SigningMethodECDSA:
+		a.key, err = jwt.ParseECPrivateKeyFromPEM(data)
+	default:
+		err = fmt.Errorf("Unsupported assertion algorithm %q", a.Algorithm)
+	}
+	return err
+}
+
+func (a *Assertion) DecodeConfig(input map[string]interface{}) (interface{}, error) {
+	if a.keyErr != nil {
+		return nil, a.keyErr
+	}
+
+	config := assertionConfig{
+		Header: defaultHeader,
+		TTL:    defaultTTL,
+	}
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook: mapstructure.StringToTimeDurationHookFunc(),
+		Result:     &config,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = decoder.Decode(input)
+	if err != nil {
+		return nil, err
+	}
+	if config.TTL <= 0 {
+		return nil, fmt.Errorf("Invalid assertion ttl %s", config.TTL)
+	}
+
+	return config, nil
+}
+
+func (a *Assertion) Evaluate(ctx context.Context, configuration interface{}) error {
+	assertion, _ := configuration.(assertionConfig)
+	rq := ctx.Rq()
+
+	// Assertions from clients are never forwarded
+	rq.DeleteHeader(assertion.Header)
+
+	if ctx.Consumer() == nil && ctx.Identity() == nil {
+		return ctx.Next()
+	}
+
+	token, err := a.sign(ctx, &assertion)
+	if err != nil {
+		log.Errorf("Could not sign assertion: %s", err)
+		return ef.New(ctx, "internalError")
+	}
+	rq.SetHeader(assertion.Header, assertion.Prefix+token)
+
+	return ctx.Next()
+}
+
+// sign returns an assertion of the caller of ctx.  The claims that
+// authorization granted are included, then the static claims of the
+// service and then of the operation, and the registered claims last.
+func (a *Assertion) sign(ctx context.Context, assertion *assertionConfig) (string, error) {
+	// Claims are copied, as static claims may be set within them
+	claims := jwt.MapClaims(copyClaims(ctx.Claims()))
+
+	service := ctx.Service()
+	if service != nil {
+		addClaims(ctx, claims, service.GlobalClaims)
+	}
+	if operation := ctx.Operation(); operation != nil {
+		addClaims(ctx, claims, operation.Claims)
+	}
+
+	if consumer := ctx.Consumer(); consumer != nil {
+		claims["consumer_id"] = consumer.ID
+		claims["sub"] = consumer.ID
+	}
+	if plan := ctx.Plan(); plan != nil {
+		claims["plan_id"] = plan.ID
+	}
+	if client := ctx.Client(); client != nil {
+		claims["client_id"] = client.ID
+	}
+	if credential := ctx.Credential(); credential != nil {
+		claims["credential_id"] = credential.ID
+	}
+	if caller := ctx.Identity(); caller != nil {
+		claims["identity_id"] = caller.ID()
+		claims["sub"] = caller.ID()
+	}
+
+	audience := assertion.Audience
+	if audience == "" && service != nil {
+		audience = service.Name
+	}
+
+	now := time.Now()
+	claims["iss"] = a.Issuer
+	claims["aud"] = audience
+	claims["jti"] = ctx.RequestID()
+	claims["iat"] = now.Unix()
+	claims["nbf"] = now.Unix()
+	claims["exp"] = now.Add(assertion.TTL).Unix()
+
+	token := jwt.NewWithClaims(a.method, claims)
+	if a.KeyID != "" {
+		token.Header["kid"] = a.KeyID
+	}
+	return token.SignedString(a.key)
+}
+
+// addClaims adds claim entries to claims.  Entries with a value are
+// static claims, and entries without one copy the claim of the caller
+// with that name, which may be a path such as "account.id".
+func addClaims(ctx context.Context, claims jwt.MapClaims, entries []config.ClaimEntry) {
+	for _, entry := range entries {
+		if entry.Value != nil {
+			identity.Claims(claims).Set(strings.Split(entry.Type, "."), *entry.Value)
+			continue
+		}
+		if value, ok := lookupClaim(ctx.Claims(), entry.Type); ok {
+			identity.Claims(claims).Set(strings.Split(entry.Type, "."), value)
+		}
+	}
+}
+
+func lookupClaim(claims identity.Claims, name string) (interface{}, bool) {
+	var value interface{} = map[string]interface{}(claims)
+	for _, key := range strings.Split(name, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = object[key]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+func copyClaims(claims map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(claims))
+	for name, value := range claims {
+		if object, ok := value.(map[string]interface{}); ok {
+			value = copyClaims(object)
+		}
+		copied[name] = value
+	}
+	return copied
+}
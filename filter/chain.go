@@ -0,0 +1,303 @@
+package filter
+
+import (
+	"bytes"
+	"container/list"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/prizem-io/gateway/config"
+)
+
+// Filters are collected from these sources, in order of precedence:
+//
+//	client     the client application of the caller
+//	consumer   the caller
+//	plan       the plan of the caller
+//	service    the service of the route
+//	operation  the operation of the route
+//
+// The configurations of a filter are passed to its ConfigurationCombiner
+// in this order.  Filters that do not combine configurations use the one
+// of the first source that references them.  The chain is then ordered
+// by filter priority, with filters of equal priority in the order in
+// which they are first referenced.
+const (
+	SourceClient    = "client"
+	SourceConsumer  = "consumer"
+	SourcePlan      = "plan"
+	SourceService   = "service"
+	SourceOperation = "operation"
+)
+
+type (
+	// Chain is the filter chain of an operation, compiled from the filters
+	// of its service and of the operation.  The filters of the client,
+	// consumer and plan of a caller are merged in when the caller first
+	// calls the operation and the result is kept until any of them is
+	// replaced or until it is one of the least recently used once
+	// maxSubjectChains are kept.
+	Chain struct {
+		name       string
+		method     string
+		sources    []filterSource
+		executions []Execution
+		err        error
+
+		mutex    sync.Mutex
+		subjects map[string]*list.Element
+		order    *list.List
+	}
+
+	subjectChain struct {
+		key        string
+		client     *config.Client
+		consumer   *config.Consumer
+		plan       *config.Plan
+		executions []Execution
+		err        error
+	}
+
+	filterSource struct {
+		name    string
+		id      string
+		filters []config.PluginConfig
+	}
+
+	unregisteredFilter string
+)
+
+var (
+	// chains holds a map[*config.Operation]*Chain of the routes that were
+	// last built.
+	chains atomic.Value
+
+	// maxSubjectChains bounds the chains kept per operation for the
+	// combinations of client, consumer and plan that called it.
+	maxSubjectChains = 1024
+)
+
+func (e unregisteredFilter) Error() string {
+	return "Could not find filter: " + string(e)
+}
+
+// Compile compiles the filter chains of every operation of services,
+// replacing those of the previous router.  It is called when the router
+// is built.
+func Compile(services []config.Service) {
+	compiled := make(map[*config.Operation]*Chain)
+
+	for j := range services {
+		service := &services[j]
+
+		for i := range service.Operations {
+			operation := &service.Operations[i]
+			chain := NewChain(service, operation)
+			if chain.err != nil {
+				log.Warnf("Could not compile filters of %s: %s", chain.name, chain.err)
+			}
+			compiled[operation] = chain
+		}
+	}
+
+	chains.Store(compiled)
+}
+
+// NewChain compiles the filter chain of operation, which may be nil.
+func NewChain(service *config.Service, operation *config.Operation) *Chain {
+	chain := &Chain{
+		name:     service.Name,
+		sources:  []filterSource{{SourceService, service.Name, service.Filters}},
+		subjects: map[string]*list.Element{},
+		order:    list.New(),
+	}
+	if operation != nil {
+		chain.name += "::" + operation.Name
+		chain.method = operation.Method.String()
+		chain.sources = append(chain.sources, filterSource{SourceOperation, operation.Name, operation.Filters})
+	}
+
+	chain.executions, chain.err = compile(chain.sources, chain.method)
+	if chain.err == nil && log.GetLevel() >= log.DebugLevel {
+		log.WithField("filters", describe(chain.executions)).
+			Debugf("Compiled filters of %s", chain.name)
+	}
+
+	return chain
+}
+
+// Executions returns the filter chain including the filters of client,
+// consumer and plan, any of which may be nil.  The returned slice is shared
+// and must not be modified.
+func (c *Chain) Executions(client *config.Client, consumer *config.Consumer, plan *config.Plan) ([]Execution, error) {
+	sources := make([]filterSource, 0, 3+len(c.sources))
+	key := ""
+	if client != nil && len(client.Filters) > 0 {
+		sources = append(sources, filterSource{SourceClient, client.ID, client.Filters})
+		key += client.ID
+	}
+	key += "|"
+	if consumer != nil && len(consumer.Filters) > 0 {
+		sources = append(sources, filterSource{SourceConsumer, consumer.ID, consumer.Filters})
+		key += consumer.ID
+	}
+	key += "|"
+	if plan != nil && len(plan.Filters) > 0 {
+		sources = append(sources, filterSource{SourcePlan, plan.ID, plan.Filters})
+		key += plan.ID
+	}
+
+	if c.err != nil || len(sources) == 0 {
+		return c.executions, c.err
+	}
+
+	if cached := c.cached(key, client, consumer, plan); cached != nil {
+		return cached.executions, cached.err
+	}
+
+	sources = append(sources, c.sources...)
+	cached := &subjectChain{
+		key:      key,
+		client:   client,
+		consumer: consumer,
+		plan:     plan,
+	}
+	cached.executions, cached.err = compile(sources, c.method)
+	if cached.err == nil && log.GetLevel() >= log.DebugLevel {
+		fields := log.Fields{"filters": describe(cached.executions)}
+		for _, source := range sources[:len(sources)-len(c.sources)] {
+			fields[source.name] = source.id
+		}
+		log.WithFields(fields).Debugf("Compiled filters of %s", c.name)
+	}
+
+	c.add(cached)
+
+	return cached.executions, cached.err
+}
+
+// cached returns the chain kept for key if it was compiled for the same
+// client, consumer and plan.  Entities are replaced, not modified, when
+// their configuration changes.
+func (c *Chain) cached(key string, client *config.Client, consumer *config.Consumer, plan *config.Plan) *subjectChain {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	element, ok := c.subjects[key]
+	if !ok {
+		return nil
+	}
+	cached := element.Value.(*subjectChain)
+	if cached.client != client || cached.consumer != consumer || cached.plan != plan {
+		return nil
+	}
+	c.order.MoveToFront(element)
+
+	return cached
+}
+
+// add keeps cached, evicting the least recently used chains beyond
+// maxSubjectChains.
+func (c *Chain) add(cached *subjectChain) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if element, ok := c.subjects[cached.key]; ok {
+		element.Value = cached
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.subjects[cached.key] = c.order.PushFront(cached)
+	for c.order.Len() > maxSubjectChains {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.subjects, oldest.Value.(*subjectChain).key)
+	}
+}
+
+// compiledChain returns the chain compiled for operation when
+// the router was built, or compiles one if there is none.
+func compiledChain(service *config.Service, operation *config.Operation) *Chain {
+	if compiled, ok := chains.Load().(map[*config.Operation]*Chain); ok && operation != nil {
+		if chain, ok := compiled[operation]; ok {
+			return chain
+		}
+	}
+
+	return NewChain(service, operation)
+}
+
+// compile groups the configurations of the filters of sources by filter
+// and orders the filters by priority.  Configurations whose predicate
+// cannot match method are left out, unless method is empty.
+func compile(sources []filterSource, method string) ([]Execution, error) {
+	numFilters := 0
+	for _, source := range sources {
+		numFilters += len(source.filters)
+	}
+
+	invocations := make(SortedByPriority, 0, numFilters)
+	positions := make(map[string]int, numFilters)
+
+	for _, source := range sources {
+		for i := range source.filters {
+			configuration := &source.filters[i]
+			filter, ok := filterMap[configuration.Name]
+			if !ok {
+				return nil, unregisteredFilter(configuration.Name)
+			}
+
+			condition, err := newPredicate(configuration.When)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid predicate of filter %s: %s", configuration.Name, err)
+			}
+			if condition != nil && method != "" {
+				if !condition.matchesMethod(method) {
+					continue
+				}
+				if !condition.dynamic() {
+					condition = nil
+				}
+			}
+
+			position, ok := positions[filter.Name()]
+			if !ok {
+				position = len(invocations)
+				positions[filter.Name()] = position
+				invocations = append(invocations, Invocation{
+					filter: filter,
+				})
+			}
+
+			invocation := &invocations[position]
+			invocation.configurations = append(invocation.configurations, configuration.Config)
+			invocation.conditions = append(invocation.conditions, condition)
+			invocation.sources = append(invocation.sources, source.name)
+		}
+	}
+
+	// Filters of equal priority keep the order in which they were referenced
+	sort.Stable(invocations)
+
+	return getFilterExecutions(invocations)
+}
+
+// describe lists each filter of executions with its priority and the
+// sources of its configurations, as in "logger(0)[plan,operation]".
+func describe(executions []Execution) string {
+	var buffer bytes.Buffer
+	for i, execution := range executions {
+		if i > 0 {
+			buffer.WriteByte(' ')
+		}
+		fmt.Fprintf(&buffer, "%s(%d)[%s]", execution.Filter.Name(),
+			execution.Filter.Priority(), strings.Join(execution.Sources, ","))
+	}
+	return buffer.String()
+}
@@ -0,0 +1,107 @@
+package filter_test
+
+import (
+	"runtime"
+	"strconv"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+
+	"github.com/prizem-io/gateway/backend"
+	"github.com/prizem-io/gateway/config"
+	"github.com/prizem-io/gateway/context"
+	"github.com/prizem-io/gateway/filter"
+	fh "github.com/prizem-io/gateway/server/fasthttp"
+)
+
+type (
+	noopFilter  string
+	noopBackend struct{}
+)
+
+func (f noopFilter) Name() string {
+	return string(f)
+}
+
+func (f noopFilter) Priority() int {
+	return 0
+}
+
+func (f noopFilter) Evaluate(ctx context.Context, configuration interface{}) error {
+	return nil
+}
+
+func (noopBackend) Name() string {
+	return "noop"
+}
+
+func (noopBackend) Handle(ctx context.Context) error {
+	return nil
+}
+
+// BenchmarkHandler runs the filters of an operation for one consumer, whose
+// chain is compiled once, and for a new consumer on every call, whose chains
+// are compiled and kept.  retained-B/op is the heap that is still in use
+// after the run.
+func BenchmarkHandler(b *testing.B) {
+	backend.Register(noopBackend{})
+	filter.Register(noopFilter("service"), noopFilter("consumer"))
+
+	services := make([]config.Service, 1)
+	service := &services[0]
+	service.Name = "bench"
+	service.Backend = &config.PluginConfig{Name: "noop"}
+	service.Filters = []config.PluginConfig{{Name: "service"}}
+	service.Operations = []config.Operation{{Name: "get", Method: config.Method("GET")}}
+	filter.Compile(services)
+	operation := &service.Operations[0]
+
+	newConsumer := func(id string) *config.Consumer {
+		consumer := &config.Consumer{}
+		consumer.ID = id
+		consumer.Filters = []config.PluginConfig{{Name: "consumer"}}
+		return consumer
+	}
+
+	run := func(b *testing.B, consumer func(i int) *config.Consumer) {
+		ctx := fh.AcquireFastHttpContext(&fasthttp.RequestCtx{}, "bench")
+		defer fh.ReleaseFastHttpContext(ctx)
+		ctx.SetService(service)
+		ctx.SetOperation(operation)
+
+		var before, after runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&before)
+		b.ReportAllocs()
+		b.ResetTimer()
+
+		for i := 0; i < b.N; i++ {
+			ctx.SetConsumer(consumer(i))
+			if err := filter.Handler(ctx); err != nil {
+				b.Fatal(err)
+			}
+		}
+
+		b.StopTimer()
+		runtime.GC()
+		runtime.ReadMemStats(&after)
+		retained := int64(after.HeapAlloc) - int64(before.HeapAlloc)
+		if retained < 0 {
+			retained = 0
+		}
+		b.ReportMetric(float64(retained)/float64(b.N), "retained-B/op")
+	}
+
+	b.Run("sameConsumer", func(b *testing.B) {
+		consumer := newConsumer("consumer")
+		run(b, func(int) *config.Consumer {
+			return consumer
+		})
+	})
+
+	b.Run("newConsumers", func(b *testing.B) {
+		run(b, func(i int) *config.Consumer {
+			return newConsumer(strconv.Itoa(i))
+		})
+	})
+}
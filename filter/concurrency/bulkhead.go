@@ -0,0 +1,104 @@
+package concurrency
+
+import (
+	"sync"
+	"time"
+)
+
+type (
+	// bulkheads holds the bulkhead of each key that has requests in flight
+	// or waiting.  Bulkheads are removed once they are idle so that keys
+	// of consumers do not accumulate.
+	bulkheads struct {
+		mutex sync.Mutex
+		byKey map[string]*bulkhead
+	}
+
+	bulkhead struct {
+		key      string
+		limit    int
+		inFlight int
+		// waiters are granted slots in the order that they arrived
+		waiters []chan struct{}
+	}
+)
+
+func newBulkheads() *bulkheads {
+	return &bulkheads{
+		byKey: map[string]*bulkhead{},
+	}
+}
+
+// acquire takes a slot of the bulkhead of key, waiting in its queue until
+// deadline if all limit slots are taken and fewer than queueSize requests
+// are waiting already.
+func (bs *bulkheads) acquire(key string, limit, queueSize int, deadline time.Time) (*bulkhead, bool) {
+	bs.mutex.Lock()
+	b, ok := bs.byKey[key]
+	if !ok {
+		b = &bulkhead{key: key}
+		bs.byKey[key] = b
+	}
+	// The limit of the latest configuration applies
+	b.limit = limit
+
+	if b.inFlight < b.limit {
+		b.inFlight++
+		bs.mutex.Unlock()
+		return b, true
+	}
+
+	wait := time.Until(deadline)
+	if len(b.waiters) >= queueSize || wait <= 0 {
+		bs.removeIdle(b)
+		bs.mutex.Unlock()
+		return nil, false
+	}
+
+	granted := make(chan struct{})
+	b.waiters = append(b.waiters, granted)
+	bs.mutex.Unlock()
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-granted:
+		return b, true
+	case <-timer.C:
+	}
+
+	bs.mutex.Lock()
+	defer bs.mutex.Unlock()
+	for i, waiter := range b.waiters {
+		if waiter == granted {
+			b.waiters = append(b.waiters[:i], b.waiters[i+1:]...)
+			bs.removeIdle(b)
+			return nil, false
+		}
+	}
+
+	// The slot was granted as the timer fired
+	return b, true
+}
+
+// release frees a slot of b and passes it on to the longest waiting
+// request.
+func (bs *bulkheads) release(b *bulkhead) {
+	bs.mutex.Lock()
+	defer bs.mutex.Unlock()
+
+	b.inFlight--
+	for b.inFlight < b.limit && len(b.waiters) > 0 {
+		close(b.waiters[0])
+		b.waiters = b.waiters[1:]
+		b.inFlight++
+	}
+	bs.removeIdle(b)
+}
+
+func (bs *bulkheads) removeIdle(b *bulkhead) {
+	if b.inFlight == 0 && len(b.waiters) == 0 {
+		delete(bs.byKey, b.key)
+	}
+}
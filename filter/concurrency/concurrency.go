@@ -0,0 +1,164 @@
+package concurrency
+
+import (
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+
+	"github.com/prizem-io/gateway/config"
+	"github.com/prizem-io/gateway/context"
+	ef "github.com/prizem-io/gateway/errorfactory"
+)
+
+type (
+	// Limiter caps the number of requests in flight for a service, for
+	// each of its operations and for each consumer of it.  Requests over a
+	// cap wait in a bounded queue or are rejected.
+	Limiter struct {
+		PrioritySetting int `mapstructure:"priority"`
+
+		bulkheads *bulkheads
+	}
+
+	// limitConfig is the configuration of the filter.  Limits of 0 are not
+	// enforced.
+	limitConfig struct {
+		Service   int `mapstructure:"service"`
+		Operation int `mapstructure:"operation"`
+		Consumer  int `mapstructure:"consumer"`
+		// QueueSize is the number of requests that may wait for each
+		// limit.  Requests over a limit are rejected immediately when it
+		// is 0.
+		QueueSize int `mapstructure:"queueSize"`
+		// QueueTimeout is how long a request waits for all of its limits,
+		// as in "500ms".
+		QueueTimeout time.Duration `mapstructure:"queueTimeout"`
+	}
+)
+
+// New returns a limiter that runs after rate limiting, so that requests
+// refused by quotas never occupy a slot.
+func New() *Limiter {
+	return &Limiter{
+		PrioritySetting: -50,
+		bulkheads:       newBulkheads(),
+	}
+}
+
+func (*Limiter) Name() string {
+	return "concurrency"
+}
+
+func (l *Limiter) Priority() int {
+	return l.PrioritySetting
+}
+
+func (l *Limiter) Initialize(config config.Configuration) error {
+	return config.UnmarshalKey("concurrency", l)
+}
+
+func (*Limiter) DecodeConfig(input map[string]interface{}) (interface{}, error) {
+	var config limitConfig
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook: mapstructure.StringToTimeDurationHookFunc(),
+		Result:     &config,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = decoder.Decode(input)
+	if err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// Combine takes each setting from the configuration with the highest
+// precedence that sets it.
+func (*Limiter) Combine(configurations ...interface{}) (interface{}, error) {
+	var combined limitConfig
+	for i := len(configurations) - 1; i >= 0; i-- {
+		configuration, ok := configurations[i].(limitConfig)
+		if !ok {
+			continue
+		}
+		if configuration.Service != 0 {
+			combined.Service = configuration.Service
+		}
+		if configuration.Operation != 0 {
+			combined.Operation = configuration.Operation
+		}
+		if configuration.Consumer != 0 {
+			combined.Consumer = configuration.Consumer
+		}
+		if configuration.QueueSize != 0 {
+			combined.QueueSize = configuration.QueueSize
+		}
+		if configuration.QueueTimeout != 0 {
+			combined.QueueTimeout = configuration.QueueTimeout
+		}
+	}
+	return combined, nil
+}
+
+// Evaluate holds a slot of each limit while the rest of the chain runs.
+// Limits are acquired from the narrowest to the widest, so that requests
+// queued for a busy consumer or operation do not hold slots of the service
+// that other consumers need.  The request is rejected with the first limit
+// that it cannot acquire in time.
+func (l *Limiter) Evaluate(ctx context.Context, configuration interface{}) error {
+	limits, _ := configuration.(limitConfig)
+
+	service := ctx.Service()
+	operation := ctx.Operation()
+	consumer := ctx.Consumer()
+
+	var acquired []*bulkhead
+	defer func() {
+		for _, b := range acquired {
+			l.bulkheads.release(b)
+		}
+	}()
+
+	deadline := time.Now().Add(limits.QueueTimeout)
+	for _, scope := range []struct {
+		name  string
+		key   string
+		limit int
+	}{
+		{"consumer", service.Name + "/" + consumerID(consumer), limits.Consumer},
+		{"operation", service.Name + "/" + operationName(operation), limits.Operation},
+		{"service", service.Name, limits.Service},
+	} {
+		if scope.limit <= 0 || (scope.name == "consumer" && consumer == nil) {
+			continue
+		}
+
+		b, ok := l.bulkheads.acquire(scope.name+":"+scope.key, scope.limit, limits.QueueSize, deadline)
+		if !ok {
+			return ef.New(ctx, "concurrencyLimitExceeded", ef.Params{
+				"scope": scope.name,
+				"limit": scope.limit,
+			})
+		}
+		acquired = append(acquired, b)
+	}
+
+	return ctx.Next()
+}
+
+func operationName(operation *config.Operation) string {
+	if operation == nil {
+		return ""
+	}
+	return operation.Name
+}
+
+func consumerID(consumer *config.Consumer) string {
+	if consumer == nil {
+		return ""
+	}
+	return consumer.ID
+}
@@ -0,0 +1,98 @@
+package concurrency
+
+import (
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+
+	"github.com/prizem-io/gateway/config"
+	"github.com/prizem-io/gateway/context"
+	fh "github.com/prizem-io/gateway/server/fasthttp"
+)
+
+// nextMiddleware runs next in place of the rest of the chain.
+type nextMiddleware struct {
+	next func()
+}
+
+func (m nextMiddleware) Execute(ctx context.Context) error {
+	return nil
+}
+
+func (m nextMiddleware) Next(ctx context.Context) error {
+	m.next()
+	return nil
+}
+
+func (nextMiddleware) Stop() {}
+
+func (nextMiddleware) IsStopped() bool {
+	return false
+}
+
+// TestConsumerQueueDoesNotHoldService checks that the requests of a
+// consumer that wait for its limit do not take slots of the service away
+// from other consumers.
+func TestConsumerQueueDoesNotHoldService(t *testing.T) {
+	limiter := New()
+	limits := limitConfig{
+		Service:      10,
+		Consumer:     2,
+		QueueSize:    10,
+		QueueTimeout: time.Second,
+	}
+	service := &config.Service{}
+	service.Name = "service"
+
+	evaluate := func(consumerID string, next func()) error {
+		ctx := fh.AcquireFastHttpContext(&fasthttp.RequestCtx{}, "test")
+		defer fh.ReleaseFastHttpContext(ctx)
+		consumer := &config.Consumer{}
+		consumer.ID = consumerID
+		ctx.SetService(service)
+		ctx.SetConsumer(consumer)
+		ctx.SetMiddlewareHandler(nextMiddleware{next: next})
+		return limiter.Evaluate(ctx, limits)
+	}
+
+	release := make(chan struct{})
+	done := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		go func() {
+			done <- evaluate("busy", func() { <-release })
+		}()
+	}
+	defer func() {
+		close(release)
+		for i := 0; i < 10; i++ {
+			if err := <-done; err != nil {
+				t.Errorf("busy consumer: %v", err)
+			}
+		}
+	}()
+
+	// Wait for the requests over the consumer limit to queue
+	waiting := func() int {
+		limiter.bulkheads.mutex.Lock()
+		defer limiter.bulkheads.mutex.Unlock()
+		if b, ok := limiter.bulkheads.byKey["consumer:service/busy"]; ok {
+			return len(b.waiters)
+		}
+		return 0
+	}
+	for start := time.Now(); waiting() < 8; {
+		if time.Since(start) > time.Second {
+			t.Fatalf("%d requests are waiting, want 8", waiting())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	start := time.Now()
+	if err := evaluate("other", func() {}); err != nil {
+		t.Fatalf("other consumer: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > limits.QueueTimeout/2 {
+		t.Errorf("other consumer waited %v", elapsed)
+	}
+}
@@ -0,0 +1,331 @@
+package cors
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+
+	"github.com/prizem-io/gateway/config"
+	"github.com/prizem-io/gateway/context"
+	ef "github.com/prizem-io/gateway/errorfactory"
+	"github.com/prizem-io/gateway/filter"
+)
+
+type (
+	// CORS adds the headers that allow browsers to call operations from
+	// other origins and answers their preflight requests.
+	CORS struct {
+		PrioritySetting int `mapstructure:"priority"`
+	}
+
+	// corsConfig is a CORS policy.  Policies of services, operations,
+	// plans and consumers are merged setting by setting, each taken from
+	// the policy with the highest precedence that sets it.
+	corsConfig struct {
+		// AllowedOrigins are exact origins, "*" for any origin, or patterns
+		// such as "https://*.example.com".
+		AllowedOrigins []string `mapstructure:"allowedOrigins"`
+		// AllowedOriginPatterns are regular expressions matching origins.
+		AllowedOriginPatterns []string `mapstructure:"allowedOriginPatterns"`
+		// AllowedMethods defaults to the method of the operation.
+		AllowedMethods []string `mapstructure:"allowedMethods"`
+		// AllowedHeaders may be "*" to allow any requested header.
+		AllowedHeaders   []string      `mapstructure:"allowedHeaders"`
+		ExposedHeaders   []string      `mapstructure:"exposedHeaders"`
+		AllowCredentials *bool         `mapstructure:"allowCredentials"`
+		MaxAge           time.Duration `mapstructure:"maxAge"`
+
+		originPatterns []*regexp.Regexp
+	}
+)
+
+func New() *CORS {
+	return &CORS{
+		PrioritySetting: -200,
+	}
+}
+
+func (*CORS) Name() string {
+	return "cors"
+}
+
+func (c *CORS) Priority() int {
+	return c.PrioritySetting
+}
+
+func (c *CORS) Initialize(config config.Configuration) error {
+	return config.UnmarshalKey("cors", c)
+}
+
+func (*CORS) DecodeConfig(input map[string]interface{}) (interface{}, error) {
+	var config corsConfig
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook: mapstructure.StringToTimeDurationHookFunc(),
+		Result:     &config,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = decoder.Decode(input)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, origin := range config.AllowedOrigins {
+		if _, err := path.Match(origin, ""); err != nil {
+			return nil, fmt.Errorf("Invalid origin %q: %s", origin, err)
+		}
+	}
+	for _, pattern := range config.AllowedOriginPatterns {
+		originPattern, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid origin pattern %q: %s", pattern, err)
+		}
+		config.originPatterns = append(config.originPatterns, originPattern)
+	}
+
+	return config, nil
+}
+
+// Combine merges policies given in order of precedence.
+func (*CORS) Combine(configurations ...interface{}) (interface{}, error) {
+	var combined corsConfig
+	for i := len(configurations) - 1; i >= 0; i-- {
+		configuration, ok := configurations[i].(corsConfig)
+		if !ok {
+			continue
+		}
+		if configuration.AllowedOrigins != nil {
+			combined.AllowedOrigins = configuration.AllowedOrigins
+		}
+		if configuration.AllowedOriginPatterns != nil {
+			combined.AllowedOriginPatterns = configuration.AllowedOriginPatterns
+			combined.originPatterns = configuration.originPatterns
+		}
+		if configuration.AllowedMethods != nil {
+			combined.AllowedMethods = configuration.AllowedMethods
+		}
+		if configuration.AllowedHeaders != nil {
+			combined.AllowedHeaders = configuration.AllowedHeaders
+		}
+		if configuration.ExposedHeaders != nil {
+			combined.ExposedHeaders = configuration.ExposedHeaders
+		}
+		if configuration.AllowCredentials != nil {
+			combined.AllowCredentials = configuration.AllowCredentials
+		}
+		if configuration.MaxAge != 0 {
+			combined.MaxAge = configuration.MaxAge
+		}
+	}
+	return combined, nil
+}
+
+// Evaluate allows the response to be read by the origin of the request, if
+// the policy allows the origin.  Requests from other origins are processed
+// without CORS headers, so browsers do not expose their responses.
+func (*CORS) Evaluate(ctx context.Context, configuration interface{}) error {
+	policy, _ := configuration.(corsConfig)
+	origin := ctx.Rq().Header("Origin")
+	if origin == "" || !policy.allowsOrigin(origin) {
+		return ctx.Next()
+	}
+
+	policy.setResponseHeaders(ctx, origin)
+	err := ctx.Next()
+
+	// The backend may have replaced the response headers
+	policy.setResponseHeaders(ctx, origin)
+
+	return err
+}
+
+// Error adds the CORS headers to error responses, such as those of failed
+// authentication, that were sent before the filter was evaluated or
+// without it being able to add them.  It is intended to be passed to
+// server.SetErrorHandlers.
+func Error(ctx context.Context) {
+	origin := ctx.Rq().Header("Origin")
+	if origin == "" || ctx.Service() == nil || ctx.Rs().Header("Access-Control-Allow-Origin") != "" {
+		return
+	}
+
+	policy, ok, err := resolve(ctx)
+	if err != nil || !ok || !policy.allowsOrigin(origin) {
+		return
+	}
+
+	policy.setResponseHeaders(ctx, origin)
+}
+
+// Preflight answers a preflight request with the CORS policy of the
+// operation of ctx.  Consumers are not known for preflight requests, so
+// only the policies of services and operations apply.  It is intended to
+// be passed to server.SetPreflightHandler.
+func Preflight(ctx context.Context) error {
+	rq := ctx.Rq()
+	origin := rq.Header("Origin")
+	method := rq.Header("Access-Control-Request-Method")
+
+	// Filters are resolved for the request that the preflight precedes
+	rq.SetMethod(method)
+	policy, ok, err := resolve(ctx)
+	rq.SetMethod(http.MethodOptions)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ef.New(ctx, "methodNotAllowed")
+	}
+
+	if !policy.allowsOrigin(origin) {
+		return notAllowed(ctx, "origin "+origin+" is not allowed")
+	}
+	if !policy.allowsMethod(ctx, method) {
+		return notAllowed(ctx, "method "+method+" is not allowed")
+	}
+	requestedHeaders := splitList(rq.Header("Access-Control-Request-Headers"))
+	for _, header := range requestedHeaders {
+		if !policy.allowsHeader(header) {
+			return notAllowed(ctx, "header "+header+" is not allowed")
+		}
+	}
+
+	rs := ctx.Rs()
+	policy.setResponseHeaders(ctx, origin)
+	addVary(ctx, "Access-Control-Request-Method")
+	addVary(ctx, "Access-Control-Request-Headers")
+	if len(policy.AllowedMethods) > 0 {
+		rs.SetHeader("Access-Control-Allow-Methods", strings.Join(policy.AllowedMethods, ", "))
+	} else {
+		rs.SetHeader("Access-Control-Allow-Methods", method)
+	}
+	if len(requestedHeaders) > 0 {
+		rs.SetHeader("Access-Control-Allow-Headers", strings.Join(requestedHeaders, ", "))
+	}
+	if policy.MaxAge > 0 {
+		rs.SetHeader("Access-Control-Max-Age", strconv.FormatInt(int64(policy.MaxAge/time.Second), 10))
+	}
+	rs.SetStatusCode(http.StatusNoContent)
+
+	return nil
+}
+
+// resolve returns the policy of the cors filter in the chain of ctx and
+// whether the filter applies.
+func resolve(ctx context.Context) (corsConfig, bool, error) {
+	executions, err := filter.Executions(ctx)
+	if err != nil {
+		return corsConfig{}, false, err
+	}
+
+	for i := range executions {
+		if executions[i].Filter.Name() != "cors" {
+			continue
+		}
+
+		configuration, ok, err := executions[i].Resolve(ctx)
+		policy, _ := configuration.(corsConfig)
+		return policy, ok, err
+	}
+
+	return corsConfig{}, false, nil
+}
+
+func (p *corsConfig) setResponseHeaders(ctx context.Context, origin string) {
+	rs := ctx.Rs()
+	credentials := p.AllowCredentials != nil && *p.AllowCredentials
+
+	// Credentials cannot be allowed for any origin, so the origin is
+	// echoed instead
+	if !credentials && len(p.originPatterns) == 0 && len(p.AllowedOrigins) == 1 && p.AllowedOrigins[0] == "*" {
+		rs.SetHeader("Access-Control-Allow-Origin", "*")
+	} else {
+		rs.SetHeader("Access-Control-Allow-Origin", origin)
+		addVary(ctx, "Origin")
+	}
+	if credentials {
+		rs.SetHeader("Access-Control-Allow-Credentials", "true")
+	}
+	if len(p.ExposedHeaders) > 0 {
+		rs.SetHeader("Access-Control-Expose-Headers", strings.Join(p.ExposedHeaders, ", "))
+	}
+}
+
+func (p *corsConfig) allowsOrigin(origin string) bool {
+	for _, allowed := range p.AllowedOrigins {
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+		if matched, _ := path.Match(allowed, origin); matched {
+			return true
+		}
+	}
+	for _, pattern := range p.originPatterns {
+		if pattern.MatchString(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *corsConfig) allowsMethod(ctx context.Context, method string) bool {
+	if len(p.AllowedMethods) == 0 {
+		return method == ctx.Operation().Method.String()
+	}
+	for _, allowed := range p.AllowedMethods {
+		if strings.EqualFold(allowed, method) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *corsConfig) allowsHeader(header string) bool {
+	for _, allowed := range p.AllowedHeaders {
+		if allowed == "*" || strings.EqualFold(allowed, header) {
+			return true
+		}
+	}
+	return false
+}
+
+// addVary adds header to the Vary header of the response unless it is
+// listed already.
+func addVary(ctx context.Context, header string) {
+	rs := ctx.Rs()
+	vary := rs.Header("Vary")
+	for _, value := range splitList(vary) {
+		if strings.EqualFold(value, header) {
+			return
+		}
+	}
+
+	if vary != "" {
+		header = vary + ", " + header
+	}
+	rs.SetHeader("Vary", header)
+}
+
+func notAllowed(ctx context.Context, reason string) error {
+	return ef.New(ctx, "corsNotAllowed", ef.Params{
+		"reason": reason,
+	})
+}
+
+func splitList(value string) []string {
+	values := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			values = append(values, item)
+		}
+	}
+	return values
+}
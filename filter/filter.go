@@ -2,7 +2,6 @@ package filter
 
 import (
 	"fmt"
-	"sort"
 
 	"github.com/prizem-io/gateway/backend"
 	"github.com/prizem-io/gateway/config"
@@ -20,6 +19,14 @@ type (
 	Execution struct {
 		Filter        Filter
 		Configuration interface{}
+		// Sources lists where the configurations of the filter came
+		// from, in order of precedence.
+		Sources []string
+
+		// Conditional configurations are combined for each request
+		// from those whose predicate matches.
+		configurations []interface{}
+		conditions     []*predicate
 	}
 )
 
@@ -46,6 +53,8 @@ func Register(filters ...Filter) {
 type Invocation struct {
 	filter         Filter
 	configurations []interface{}
+	conditions     []*predicate
+	sources        []string
 }
 
 type SortedByPriority []Invocation
@@ -80,119 +89,107 @@ func GetConfig(configuration *config.PluginConfig) (interface{}, error) {
 }
 
 func Handler(ctx context.Context) error {
-	filters := getFilterConfigurations(ctx)
-
-	invocations, err := getFilterInvocations(ctx, filters)
+	executions, err := Executions(ctx)
 	if err != nil {
 		return err
 	}
 
-	return invokeFilters(ctx, invocations)
+	return invokeFilters(ctx, executions)
 }
 
-func getFilterConfigurations(ctx context.Context) []config.PluginConfig {
-	consumer := ctx.Consumer()
-	operation := ctx.Operation()
-	numFilters := len(ctx.Service().Filters)
-
-	if operation != nil {
-		numFilters += len(operation.Filters)
-	}
-	if consumer != nil {
-		numFilters += len(consumer.Filters)
-	}
+// Executions returns the ordered filter chain for ctx with
+// each filter's configurations combined.  The returned slice
+// is shared between requests and must not be modified.
+func Executions(ctx context.Context) ([]Execution, error) {
+	chain := compiledChain(ctx.Service(), ctx.Operation())
 
-	filters := make([]config.PluginConfig, 0, numFilters)
-	if consumer != nil {
-		filters = append(filters, consumer.Filters...)
-	}
-	filters = append(filters, ctx.Service().Filters...)
-	if operation != nil {
-		filters = append(filters, operation.Filters...)
+	executions, err := chain.Executions(ctx.Client(), ctx.Consumer(), ctx.Plan())
+	if name, ok := err.(unregisteredFilter); ok {
+		return nil, ef.New(ctx, "unregisterdFilter", ef.Params{
+			"filter": string(name),
+		})
 	}
 
-	return filters
+	return executions, err
 }
 
-func getFilterInvocations(ctx context.Context, filters []config.PluginConfig) (SortedByPriority, error) {
-	sorted := make(SortedByPriority, 0, len(filters))
-	grouped := make(map[string][]interface{}, len(filters))
-
-	for _, configuration := range filters {
-		// First time we are referencing this policy
-		// Look it up
-		filter, ok := filterMap[configuration.Name]
-		if !ok {
-			return nil, ef.New(ctx, "unregisterdFilter", ef.Params{
-				"filter": configuration.Name,
-			})
-		}
+func getFilterExecutions(invocations SortedByPriority) ([]Execution, error) {
+	executions := make([]Execution, 0, len(invocations))
 
-		list, ok := grouped[filter.Name()]
-		if !ok {
-			// Create slice of configurations
-			// Append to grouped configurations and sorted slice
-			list = make([]interface{}, 0, 10)
-			grouped[filter.Name()] = list
+	for _, invocation := range invocations {
+		execution := Execution{
+			Filter:  invocation.filter,
+			Sources: invocation.sources,
+		}
 
-			sorted = append(sorted, Invocation{
-				filter: filter,
-			})
+		if conditional(invocation.conditions) {
+			execution.configurations = invocation.configurations
+			execution.conditions = invocation.conditions
+		} else {
+			configuration, err := combine(invocation.filter, invocation.configurations)
+			if err != nil {
+				return nil, err
+			}
+			execution.Configuration = configuration
 		}
 
-		list = append(list, configuration.Config)
-		grouped[configuration.Name] = list
+		executions = append(executions, execution)
 	}
 
-	for i := 0; i < len(sorted); i++ {
-		fi := &sorted[i]
-		fi.configurations = grouped[fi.filter.Name()]
+	return executions, nil
+}
+
+// Resolve returns the configuration of the filter for the request of ctx
+// and whether the filter applies to the request at all.
+func (e *Execution) Resolve(ctx context.Context) (interface{}, bool, error) {
+	if e.conditions == nil {
+		return e.Configuration, true, nil
 	}
 
-	// Sorts by Filter.Priority()
-	sort.Sort(sorted)
+	configurations := make([]interface{}, 0, len(e.configurations))
+	for i, configuration := range e.configurations {
+		if e.conditions[i] == nil || e.conditions[i].matches(ctx) {
+			configurations = append(configurations, configuration)
+		}
+	}
+	if len(configurations) == 0 {
+		return nil, false, nil
+	}
 
-	return sorted, nil
+	configuration, err := combine(e.Filter, configurations)
+	return configuration, err == nil, err
 }
 
-func invokeFilters(ctx context.Context, invocations SortedByPriority) error {
-	executions := make([]Execution, 0, len(invocations))
-
-	for _, invocation := range invocations {
-		filter := invocation.filter
+func combine(filter Filter, configurations []interface{}) (interface{}, error) {
+	if combinable, ok := filter.(config.ConfigurationCombiner); ok {
+		// Give the policy the chance to combine configuration data
+		return combinable.Combine(configurations...)
+	} else if len(configurations) > 0 {
+		return configurations[0], nil
+	}
 
-		configurations := invocation.configurations
-		var configuration interface{}
+	return nil, nil
+}
 
-		if combinable, ok := filter.(config.ConfigurationCombiner); ok {
-			// Give the policy the chance to combine configuration data
-			config, err := combinable.Combine(configurations)
-			if err != nil {
-				return err
-			}
-			configuration = config
-		} else if len(configurations) > 0 {
-			configuration = configurations[0]
+func conditional(conditions []*predicate) bool {
+	for _, condition := range conditions {
+		if condition != nil {
+			return true
 		}
-
-		executions = append(executions, Execution{
-			Filter:        filter,
-			Configuration: configuration,
-		})
 	}
+	return false
+}
 
-	handlerName := ctx.Service().Backend.Name
-	handler, err := backend.GetHandler(ctx, &handlerName)
+func invokeFilters(ctx context.Context, executions []Execution) error {
+	handler, err := backend.GetHandler(ctx, &ctx.Service().Backend.Name)
 	if err != nil {
 		return err
 	}
 
-	middleware := filterMiddleware{
-		filters:        executions,
-		backendHandler: handler,
-	}
+	middleware := acquireMiddleware(executions, handler)
+	defer releaseMiddleware(ctx, middleware)
 
-	ctx.SetMiddlewareHandler(&middleware)
+	ctx.SetMiddlewareHandler(middleware)
 
 	for !ctx.IsStopped() {
 		err := ctx.Execute()
@@ -0,0 +1,210 @@
+package headers
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/mitchellh/mapstructure"
+
+	"github.com/prizem-io/gateway/config"
+	"github.com/prizem-io/gateway/context"
+)
+
+type (
+	// Headers transforms the headers of requests before they are sent to
+	// the backend and of responses after it returns.
+	Headers struct {
+		PrioritySetting int `mapstructure:"priority"`
+		Request         struct {
+			// Remove lists the request headers that are removed unless a
+			// policy of the request has its own remove list.
+			Remove []string `mapstructure:"remove"`
+		} `mapstructure:"request"`
+	}
+
+	headersConfig struct {
+		Request  rulesConfig `mapstructure:"request"`
+		Response rulesConfig `mapstructure:"response"`
+
+		request  rules
+		response rules
+	}
+
+	// rulesConfig transforms headers.  Headers are renamed, removed, set
+	// and added, in that order.  Values of set and added headers are
+	// templates such as "{consumer.id}" or "Bearer {claims.token}"; headers
+	// whose values are empty are left out.
+	rulesConfig struct {
+		Rename map[string]string `mapstructure:"rename"`
+		Remove []string          `mapstructure:"remove"`
+		Set    map[string]string `mapstructure:"set"`
+		Add    map[string]string `mapstructure:"add"`
+	}
+
+	rules struct {
+		rename [][2]string
+		remove []string
+		set    []headerValue
+		add    []headerValue
+	}
+
+	headerValue struct {
+		name  string
+		value template
+	}
+
+	// headers is the minimal interface of request and response headers.
+	headers interface {
+		Header(string) string
+		SetHeader(string, string)
+		AddHeader(string, string)
+		DeleteHeader(string)
+	}
+
+	// policies are the policies of every source of a request, applied
+	// from the lowest precedence so that higher precedences win.
+	policies []headersConfig
+)
+
+func New() *Headers {
+	return &Headers{
+		PrioritySetting: 100,
+	}
+}
+
+func (*Headers) Name() string {
+	return "headers"
+}
+
+func (h *Headers) Priority() int {
+	return h.PrioritySetting
+}
+
+func (h *Headers) Initialize(config config.Configuration) error {
+	return config.UnmarshalKey("headers", h)
+}
+
+func (*Headers) DecodeConfig(input map[string]interface{}) (interface{}, error) {
+	var config headersConfig
+	err := mapstructure.Decode(input, &config)
+	if err != nil {
+		return nil, err
+	}
+
+	config.request, err = config.Request.compile()
+	if err != nil {
+		return nil, fmt.Errorf("request: %s", err)
+	}
+	config.response, err = config.Response.compile()
+	if err != nil {
+		return nil, fmt.Errorf("response: %s", err)
+	}
+
+	return config, nil
+}
+
+func (*Headers) Combine(configurations ...interface{}) (interface{}, error) {
+	combined := make(policies, 0, len(configurations))
+	for i := len(configurations) - 1; i >= 0; i-- {
+		if policy, ok := configurations[i].(headersConfig); ok {
+			combined = append(combined, policy)
+		}
+	}
+	return combined, nil
+}
+
+func (h *Headers) Evaluate(ctx context.Context, configuration interface{}) error {
+	combined, _ := configuration.(policies)
+
+	if !combined.removes() {
+		for _, name := range h.Request.Remove {
+			ctx.Rq().DeleteHeader(name)
+		}
+	}
+	for i := range combined {
+		combined[i].request.apply(ctx, ctx.Rq())
+	}
+
+	err := ctx.Next()
+
+	// Errors are written to the response after the filters return, so
+	// response headers are transformed for them as well
+	for i := range combined {
+		combined[i].response.apply(ctx, ctx.Rs())
+	}
+
+	return err
+}
+
+// removes reports whether any policy has a request remove list.  An empty
+// list keeps every header.
+func (p policies) removes() bool {
+	for i := range p {
+		if p[i].Request.Remove != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *rulesConfig) compile() (rules, error) {
+	var r rules
+	for _, name := range sortedKeys(c.Rename) {
+		r.rename = append(r.rename, [2]string{name, c.Rename[name]})
+	}
+	r.remove = c.Remove
+
+	var err error
+	if r.set, err = compileValues(c.Set); err != nil {
+		return r, err
+	}
+	if r.add, err = compileValues(c.Add); err != nil {
+		return r, err
+	}
+	return r, nil
+}
+
+func compileValues(values map[string]string) ([]headerValue, error) {
+	compiled := make([]headerValue, 0, len(values))
+	for _, name := range sortedKeys(values) {
+		value, err := parseTemplate(values[name])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", name, err)
+		}
+		compiled = append(compiled, headerValue{name, value})
+	}
+	return compiled, nil
+}
+
+func (r *rules) apply(ctx context.Context, h headers) {
+	for _, rename := range r.rename {
+		if value := h.Header(rename[0]); value != "" {
+			h.DeleteHeader(rename[0])
+			h.SetHeader(rename[1], value)
+		}
+	}
+	for _, name := range r.remove {
+		h.DeleteHeader(name)
+	}
+	for _, header := range r.set {
+		if value := header.value.execute(ctx); value != "" {
+			h.SetHeader(header.name, value)
+		} else {
+			h.DeleteHeader(header.name)
+		}
+	}
+	for _, header := range r.add {
+		if value := header.value.execute(ctx); value != "" {
+			h.AddHeader(header.name, value)
+		}
+	}
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
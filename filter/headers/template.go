@@ -0,0 +1,227 @@
+package headers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/prizem-io/gateway/context"
+)
+
+type (
+	// template is a header value with variables such as "{consumer.id}"
+	// that are replaced for each request.
+	template []part
+
+	part struct {
+		literal  string
+		variable func(ctx context.Context) string
+	}
+)
+
+// variables resolve the variables without an argument.
+var variables = map[string]func(ctx context.Context) string{
+	"requestId": func(ctx context.Context) string {
+		return ctx.RequestID()
+	},
+	"clientIp": func(ctx context.Context) string {
+		return ctx.Rq().RequestIP()
+	},
+	"service": func(ctx context.Context) string {
+		if service := ctx.Service(); service != nil {
+			return service.Name
+		}
+		return ""
+	},
+	"operation": func(ctx context.Context) string {
+		if operation := ctx.Operation(); operation != nil {
+			return operation.Name
+		}
+		return ""
+	},
+	"consumer.id": func(ctx context.Context) string {
+		if consumer := ctx.Consumer(); consumer != nil {
+			return consumer.ID
+		}
+		return ""
+	},
+	"consumer.name": func(ctx context.Context) string {
+		if consumer := ctx.Consumer(); consumer != nil {
+			return consumer.Name
+		}
+		return ""
+	},
+	"plan.id": func(ctx context.Context) string {
+		if plan := ctx.Plan(); plan != nil {
+			return plan.ID
+		}
+		return ""
+	},
+	"plan.name": func(ctx context.Context) string {
+		if plan := ctx.Plan(); plan != nil {
+			return plan.Name
+		}
+		return ""
+	},
+	"client.id": func(ctx context.Context) string {
+		if client := ctx.Client(); client != nil {
+			return client.ID
+		}
+		return ""
+	},
+	"credential.id": func(ctx context.Context) string {
+		if credential := ctx.Credential(); credential != nil {
+			return credential.ID
+		}
+		return ""
+	},
+	"identity.id": func(ctx context.Context) string {
+		if identity := ctx.Identity(); identity != nil {
+			return identity.ID()
+		}
+		return ""
+	},
+	"identity.name": func(ctx context.Context) string {
+		if identity := ctx.Identity(); identity != nil {
+			return identity.Name()
+		}
+		return ""
+	},
+}
+
+// prefixedVariables resolve the variables whose names end with an
+// argument, such as "{claims.sub}" or "{path.id}".
+var prefixedVariables = map[string]func(ctx context.Context, argument string) string{
+	"claims": func(ctx context.Context, argument string) string {
+		var value interface{} = map[string]interface{}(ctx.Claims())
+		for _, key := range strings.Split(argument, ".") {
+			object, ok := value.(map[string]interface{})
+			if !ok {
+				return ""
+			}
+			value = object[key]
+		}
+		return format(value)
+	},
+	"path": func(ctx context.Context, argument string) string {
+		return ctx.Rq().Param(argument)
+	},
+	"query": func(ctx context.Context, argument string) string {
+		return ctx.Rq().URLParam(argument)
+	},
+	"header": func(ctx context.Context, argument string) string {
+		return ctx.Rq().Header(argument)
+	},
+}
+
+// parseTemplate parses value, in which variables are enclosed in braces.
+func parseTemplate(value string) (template, error) {
+	var t template
+	for value != "" {
+		start := strings.IndexByte(value, '{')
+		if start == -1 {
+			if err := checkLiteral(value); err != nil {
+				return nil, err
+			}
+			t = append(t, part{literal: value})
+			break
+		}
+		end := strings.IndexByte(value[start:], '}')
+		if end == -1 {
+			return nil, fmt.Errorf("Unclosed variable in %q", value)
+		}
+		end += start
+
+		if start > 0 {
+			if err := checkLiteral(value[:start]); err != nil {
+				return nil, err
+			}
+			t = append(t, part{literal: value[:start]})
+		}
+		variable, err := lookupVariable(value[start+1 : end])
+		if err != nil {
+			return nil, err
+		}
+		t = append(t, part{variable: variable})
+		value = value[end+1:]
+	}
+	return t, nil
+}
+
+func lookupVariable(name string) (func(ctx context.Context) string, error) {
+	if variable, ok := variables[name]; ok {
+		return variable, nil
+	}
+
+	if dot := strings.IndexByte(name, '.'); dot > 0 && dot < len(name)-1 {
+		if variable, ok := prefixedVariables[name[:dot]]; ok {
+			argument := name[dot+1:]
+			return func(ctx context.Context) string {
+				return variable(ctx, argument)
+			}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("Unknown variable {%s}", name)
+}
+
+func checkLiteral(literal string) error {
+	if strings.IndexFunc(literal, isControl) != -1 {
+		return fmt.Errorf("Control character in %q", literal)
+	}
+	return nil
+}
+
+// execute returns the value of the template for the request of ctx.
+// Control characters, such as CR and LF in a query parameter, are
+// stripped from the values of variables so that callers cannot inject
+// headers.
+func (t template) execute(ctx context.Context) string {
+	if len(t) == 1 && t[0].variable == nil {
+		return t[0].literal
+	}
+
+	var value strings.Builder
+	for _, p := range t {
+		if p.variable != nil {
+			value.WriteString(stripControl(p.variable(ctx)))
+		} else {
+			value.WriteString(p.literal)
+		}
+	}
+	return value.String()
+}
+
+// isControl reports whether r may not appear in a header value.  Tabs
+// are allowed.
+func isControl(r rune) bool {
+	return (r < ' ' && r != '\t') || r == 0x7f
+}
+
+func stripControl(value string) string {
+	if strings.IndexFunc(value, isControl) == -1 {
+		return value
+	}
+	return strings.Map(func(r rune) rune {
+		if isControl(r) {
+			return -1
+		}
+		return r
+	}, value)
+}
+
+func format(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case map[string]interface{}, []interface{}:
+		data, _ := json.Marshal(value)
+		return string(data)
+	}
+	return fmt.Sprint(value)
+}
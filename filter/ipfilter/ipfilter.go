@@ -0,0 +1,111 @@
+package ipfilter
+
+import (
+	"net"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/mitchellh/mapstructure"
+
+	"github.com/prizem-io/gateway/config"
+	"github.com/prizem-io/gateway/context"
+	ef "github.com/prizem-io/gateway/errorfactory"
+	"github.com/prizem-io/gateway/utils"
+)
+
+type (
+	// IPFilter allows or blocks requests by the IP address of the client.
+	IPFilter struct {
+		PrioritySetting int `mapstructure:"priority"`
+	}
+
+	// ipConfig is the policy of a source.  A request is blocked if its
+	// address is denied or, when addresses are allowed, not allowed.
+	ipConfig struct {
+		// Allow and Deny are IPv4 and IPv6 CIDR ranges or addresses.
+		Allow []string `mapstructure:"allow"`
+		Deny  []string `mapstructure:"deny"`
+
+		allow []*net.IPNet
+		deny  []*net.IPNet
+	}
+
+	// policies are the policies of every source of a request, all of which
+	// must accept it.
+	policies []ipConfig
+)
+
+func New() *IPFilter {
+	return &IPFilter{
+		PrioritySetting: -300,
+	}
+}
+
+func (*IPFilter) Name() string {
+	return "ipfilter"
+}
+
+func (f *IPFilter) Priority() int {
+	return f.PrioritySetting
+}
+
+func (f *IPFilter) Initialize(config config.Configuration) error {
+	return config.UnmarshalKey("ipfilter", f)
+}
+
+func (*IPFilter) DecodeConfig(input map[string]interface{}) (interface{}, error) {
+	var config ipConfig
+	err := mapstructure.Decode(input, &config)
+	if err != nil {
+		return nil, err
+	}
+
+	config.allow, err = utils.ParseNetworks(config.Allow)
+	if err != nil {
+		return nil, err
+	}
+	config.deny, err = utils.ParseNetworks(config.Deny)
+	if err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// Combine keeps the policy of each source, so that a consumer limited to
+// its own ranges cannot be widened by the policy of a service.
+func (*IPFilter) Combine(configurations ...interface{}) (interface{}, error) {
+	combined := make(policies, 0, len(configurations))
+	for _, configuration := range configurations {
+		if policy, ok := configuration.(ipConfig); ok {
+			combined = append(combined, policy)
+		}
+	}
+	return combined, nil
+}
+
+func (*IPFilter) Evaluate(ctx context.Context, configuration interface{}) error {
+	combined, _ := configuration.(policies)
+	if len(combined) == 0 {
+		return ctx.Next()
+	}
+
+	ip := net.ParseIP(ctx.Rq().RequestIP())
+	for i := range combined {
+		if !combined[i].accepts(ip) {
+			log.Debugf("Blocked request from %s to %s", ip, ctx.Service().Name)
+			return ef.New(ctx, "restrictedIpAddress")
+		}
+	}
+
+	return ctx.Next()
+}
+
+func (c *ipConfig) accepts(ip net.IP) bool {
+	if ip == nil {
+		return len(c.allow) == 0 && len(c.deny) == 0
+	}
+	if utils.ContainsIP(c.deny, ip) {
+		return false
+	}
+	return len(c.allow) == 0 || utils.ContainsIP(c.allow, ip)
+}
@@ -1,16 +1,43 @@
 package filter
 
 import (
+	"sync"
+
 	"github.com/prizem-io/gateway/backend"
 	"github.com/prizem-io/gateway/context"
 )
 
-type filterMiddleware struct {
-	currentFilter  int
-	filters        []Execution
-	backendHandler backend.Handler
-	nextCalled     bool
-	stopped        bool
+type (
+	filterMiddleware struct {
+		currentFilter  int
+		filters        []Execution
+		backendHandler backend.Handler
+		nextCalled     bool
+		stopped        bool
+	}
+
+	// stoppedMiddleware replaces a filterMiddleware that was released
+	// so that the context no longer refers to it.
+	stoppedMiddleware struct{}
+)
+
+var middlewarePool = sync.Pool{
+	New: func() interface{} {
+		return &filterMiddleware{}
+	},
+}
+
+func acquireMiddleware(executions []Execution, handler backend.Handler) *filterMiddleware {
+	m := middlewarePool.Get().(*filterMiddleware)
+	m.filters = executions
+	m.backendHandler = handler
+	return m
+}
+
+func releaseMiddleware(ctx context.Context, m *filterMiddleware) {
+	ctx.SetMiddlewareHandler(stoppedMiddleware{})
+	*m = filterMiddleware{}
+	middlewarePool.Put(m)
 }
 
 func (m *filterMiddleware) Execute(ctx context.Context) error {
@@ -19,8 +46,16 @@ func (m *filterMiddleware) Execute(ctx context.Context) error {
 		if next < len(m.filters) {
 			m.currentFilter++
 			execution := &m.filters[next]
+			configuration, applies, err := execution.Resolve(ctx)
+			if err != nil {
+				m.stopped = true
+				return err
+			}
+			if !applies {
+				continue
+			}
 			m.nextCalled = false
-			err := execution.Filter.Evaluate(ctx, execution.Configuration)
+			err = execution.Filter.Evaluate(ctx, configuration)
 			if err != nil {
 				m.stopped = true
 				return err
@@ -57,3 +92,18 @@ func (m *filterMiddleware) Stop() {
 func (m *filterMiddleware) IsStopped() bool {
 	return m.stopped
 }
+
+func (stoppedMiddleware) Execute(ctx context.Context) error {
+	return nil
+}
+
+func (stoppedMiddleware) Next(ctx context.Context) error {
+	return nil
+}
+
+func (stoppedMiddleware) Stop() {
+}
+
+func (stoppedMiddleware) IsStopped() bool {
+	return true
+}
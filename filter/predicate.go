@@ -0,0 +1,224 @@
+package filter
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/prizem-io/gateway/config"
+	"github.com/prizem-io/gateway/context"
+)
+
+type (
+	// predicate is the compiled form of a config.Predicate.
+	predicate struct {
+		methods      []string
+		paths        []string
+		headers      map[string]string
+		consumerTags []string
+		planIDs      []string
+		claims       []claimCondition
+		timeOfDay    *timeRange
+	}
+
+	claimCondition struct {
+		path  []string
+		value string
+	}
+
+	// timeRange holds minutes since midnight.
+	timeRange struct {
+		from     int
+		to       int
+		location *time.Location
+	}
+)
+
+// now is replaced to evaluate time of day conditions at a fixed time.
+var now = time.Now
+
+// CheckPredicate returns an error if when is not a valid predicate.
+func CheckPredicate(when *config.Predicate) error {
+	_, err := newPredicate(when)
+	return err
+}
+
+func newPredicate(when *config.Predicate) (*predicate, error) {
+	if when == nil {
+		return nil, nil
+	}
+
+	p := &predicate{
+		methods:      when.Methods,
+		paths:        when.Paths,
+		headers:      when.Headers,
+		consumerTags: when.ConsumerTags,
+		planIDs:      when.PlanIDs,
+	}
+
+	for _, pattern := range p.paths {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("Invalid path pattern %q: %s", pattern, err)
+		}
+	}
+
+	for name, value := range when.Claims {
+		p.claims = append(p.claims, claimCondition{
+			path:  strings.Split(name, "."),
+			value: fmt.Sprint(value),
+		})
+	}
+
+	if when.TimeOfDay != nil {
+		timeOfDay, err := newTimeRange(when.TimeOfDay)
+		if err != nil {
+			return nil, err
+		}
+		p.timeOfDay = timeOfDay
+	}
+
+	return p, nil
+}
+
+func newTimeRange(timeOfDay *config.TimeOfDay) (*timeRange, error) {
+	from, err := minuteOfDay(timeOfDay.From)
+	if err != nil {
+		return nil, err
+	}
+	to, err := minuteOfDay(timeOfDay.To)
+	if err != nil {
+		return nil, err
+	}
+
+	location := time.UTC
+	if timeOfDay.Location != "" {
+		location, err = time.LoadLocation(timeOfDay.Location)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid location %q: %s", timeOfDay.Location, err)
+		}
+	}
+
+	return &timeRange{
+		from:     from,
+		to:       to,
+		location: location,
+	}, nil
+}
+
+func minuteOfDay(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid time of day %q, expected HH:MM", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// matchesMethod reports whether the predicate can match requests with method.
+func (p *predicate) matchesMethod(method string) bool {
+	if len(p.methods) == 0 {
+		return true
+	}
+	for _, m := range p.methods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// dynamic reports whether the predicate has conditions, other than the
+// method, that can only be evaluated for a request.
+func (p *predicate) dynamic() bool {
+	return len(p.paths) > 0 || len(p.headers) > 0 || len(p.consumerTags) > 0 ||
+		len(p.planIDs) > 0 || len(p.claims) > 0 || p.timeOfDay != nil
+}
+
+// matches reports whether the request of ctx meets every condition.
+func (p *predicate) matches(ctx context.Context) bool {
+	if !p.matchesMethod(ctx.Rq().Method()) {
+		return false
+	}
+
+	if len(p.paths) > 0 && !p.matchesPath(ctx.Rq().Path()) {
+		return false
+	}
+
+	for name, value := range p.headers {
+		actual := ctx.Rq().Header(name)
+		if actual == "" || (value != "" && actual != value) {
+			return false
+		}
+	}
+
+	if len(p.consumerTags) > 0 {
+		consumer := ctx.Consumer()
+		if consumer == nil || !containsAny(consumer.Tags, p.consumerTags) {
+			return false
+		}
+	}
+
+	if len(p.planIDs) > 0 {
+		plan := ctx.Plan()
+		if plan == nil || !containsAny([]string{plan.ID}, p.planIDs) {
+			return false
+		}
+	}
+
+	for _, claim := range p.claims {
+		value, ok := lookupClaim(ctx.Claims(), claim.path)
+		if !ok || fmt.Sprint(value) != claim.value {
+			return false
+		}
+	}
+
+	if p.timeOfDay != nil && !p.timeOfDay.contains(now()) {
+		return false
+	}
+
+	return true
+}
+
+func (p *predicate) matchesPath(requestPath string) bool {
+	for _, pattern := range p.paths {
+		if matched, _ := path.Match(pattern, requestPath); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *timeRange) contains(t time.Time) bool {
+	t = t.In(r.location)
+	minute := t.Hour()*60 + t.Minute()
+	if r.from <= r.to {
+		return minute >= r.from && minute < r.to
+	}
+	return minute >= r.from || minute < r.to
+}
+
+func containsAny(values, candidates []string) bool {
+	for _, value := range values {
+		for _, candidate := range candidates {
+			if value == candidate {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func lookupClaim(claims map[string]interface{}, path []string) (interface{}, bool) {
+	var value interface{} = claims
+	for _, key := range path {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		value, ok = m[key]
+		if !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
@@ -0,0 +1,73 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// LocalCounter counts requests in memory.  Its counts are not shared with
+// other gateways.
+type LocalCounter struct {
+	mutex sync.Mutex
+	// arrivals holds the theoretical arrival time of each key
+	arrivals map[string]time.Time
+	sweepAt  int
+}
+
+const minSweep = 1024
+
+func NewLocalCounter() *LocalCounter {
+	return &LocalCounter{
+		arrivals: map[string]time.Time{},
+		sweepAt:  minSweep,
+	}
+}
+
+func (c *LocalCounter) Take(limits []Limit, cost int64, now time.Time) ([]time.Duration, bool, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	increments := make([]time.Duration, len(limits))
+	arrivals := make([]time.Time, len(limits))
+	allowed := true
+	for i, limit := range limits {
+		increments[i] = time.Duration(cost) * limit.Interval
+		arrival, ok := c.arrivals[limit.Key]
+		if !ok || arrival.Before(now) {
+			arrival = now
+		}
+		arrivals[i] = arrival
+		if arrival.Add(increments[i] - limit.Window).After(now) {
+			allowed = false
+		}
+	}
+
+	ahead := make([]time.Duration, len(limits))
+	for i, limit := range limits {
+		if allowed {
+			arrivals[i] = arrivals[i].Add(increments[i])
+			c.arrivals[limit.Key] = arrivals[i]
+		}
+		ahead[i] = arrivals[i].Sub(now)
+	}
+
+	if len(c.arrivals) >= c.sweepAt {
+		c.sweep(now)
+	}
+
+	return ahead, allowed, nil
+}
+
+// sweep removes the keys whose quota is fully available again.
+func (c *LocalCounter) sweep(now time.Time) {
+	for key, arrival := range c.arrivals {
+		if !arrival.After(now) {
+			delete(c.arrivals, key)
+		}
+	}
+
+	c.sweepAt = 2 * len(c.arrivals)
+	if c.sweepAt < minSweep {
+		c.sweepAt = minSweep
+	}
+}
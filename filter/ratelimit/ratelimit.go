@@ -0,0 +1,206 @@
+package ratelimit
+
+import (
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/prizem-io/gateway/config"
+	"github.com/prizem-io/gateway/context"
+	ef "github.com/prizem-io/gateway/errorfactory"
+)
+
+type (
+	// Limit is a quota of Window/Interval units per Window, enforced with
+	// the generic cell rate algorithm: units are spaced Interval apart on
+	// average and bursts of up to the whole quota are allowed.
+	Limit struct {
+		Key      string
+		Interval time.Duration
+		Window   time.Duration
+	}
+
+	// Counter records a request costing cost units against all of limits
+	// at once, or against none of them if that would exceed any.  For each
+	// limit it returns how far its theoretical arrival time is ahead of now
+	// afterwards.
+	Counter interface {
+		Take(limits []Limit, cost int64, now time.Time) ([]time.Duration, bool, error)
+	}
+
+	// RateLimiter enforces the quotas of the plan of the consumer.
+	RateLimiter struct {
+		PrioritySetting int `mapstructure:"priority"`
+		// Prefix is prepended to the keys of the counters.
+		Prefix string `mapstructure:"prefix"`
+
+		counter  Counter
+		local    *LocalCounter
+		degraded int32
+	}
+
+	status struct {
+		quota      config.Quota
+		remaining  int64
+		reset      time.Duration
+		retryAfter time.Duration
+	}
+)
+
+var windows = map[config.TimeUnit]time.Duration{
+	config.TimeUnitMinute: time.Minute,
+	config.TimeUnitHour:   time.Hour,
+	config.TimeUnitDay:    24 * time.Hour,
+	// Months are counted as 30 days
+	config.TimeUnitMonth: 30 * 24 * time.Hour,
+}
+
+// New returns a rate limiter that counts requests with counter, which may
+// be nil, and with local counters when counter fails.
+func New(counter Counter) *RateLimiter {
+	return &RateLimiter{
+		PrioritySetting: -100,
+		Prefix:          "ratelimit:",
+		counter:         counter,
+		local:           NewLocalCounter(),
+	}
+}
+
+func (*RateLimiter) Name() string {
+	return "ratelimit"
+}
+
+func (r *RateLimiter) Priority() int {
+	return r.PrioritySetting
+}
+
+func (r *RateLimiter) Initialize(config config.Configuration) error {
+	return config.UnmarshalKey("ratelimit", r)
+}
+
+// Evaluate charges the request weight of the operation against each quota
+// of the plan of the consumer.  Requests without a consumer or plan, and
+// operations weighted 0, are not limited.
+func (r *RateLimiter) Evaluate(ctx context.Context, _ interface{}) error {
+	consumer := ctx.Consumer()
+	plan := ctx.Plan()
+	if consumer == nil || plan == nil || len(plan.Quotas) == 0 {
+		return ctx.Next()
+	}
+
+	cost := int64(1)
+	if service := ctx.Service(); service != nil {
+		cost = service.RequestWeight(ctx.Operation())
+	}
+	if cost <= 0 {
+		return ctx.Next()
+	}
+
+	quotas := make([]config.Quota, 0, len(plan.Quotas))
+	limits := make([]Limit, 0, len(plan.Quotas))
+	for _, quota := range plan.Quotas {
+		window, ok := windows[quota.TimeUnit]
+		if !ok || quota.RequestCount <= 0 {
+			continue
+		}
+		quotas = append(quotas, quota)
+		limits = append(limits, Limit{
+			// The consumer ID is a hash tag so that the keys of a
+			// consumer are in the same Redis cluster slot
+			Key:      r.Prefix + "{" + consumer.ID + "}:" + string(quota.TimeUnit),
+			Interval: window / time.Duration(quota.RequestCount),
+			Window:   window,
+		})
+	}
+	if len(limits) == 0 {
+		return ctx.Next()
+	}
+
+	ahead, allowed, err := r.take(limits, cost, time.Now())
+	if err != nil {
+		return err
+	}
+
+	current := mostRestrictive(quotas, limits, cost, ahead, allowed)
+	setHeaders(ctx, current)
+
+	if !allowed {
+		retryAfter := seconds(current.retryAfter)
+		ctx.Rs().SetHeader("Retry-After", strconv.FormatInt(retryAfter, 10))
+		return ef.New(ctx, "rateLimitExceeded", ef.Params{
+			"limit":      current.quota.RequestCount,
+			"timeUnit":   current.quota.TimeUnit,
+			"retryAfter": retryAfter,
+		})
+	}
+
+	err = ctx.Next()
+
+	// The backend may have replaced the response headers
+	setHeaders(ctx, current)
+
+	return err
+}
+
+// take counts the request with the counter of the rate limiter or, if it
+// is unavailable, with local counters.
+func (r *RateLimiter) take(limits []Limit, cost int64, now time.Time) ([]time.Duration, bool, error) {
+	if r.counter != nil {
+		ahead, allowed, err := r.counter.Take(limits, cost, now)
+		if err == nil {
+			if atomic.CompareAndSwapInt32(&r.degraded, 1, 0) {
+				log.Info("Rate limiting with shared counters again")
+			}
+			return ahead, allowed, nil
+		}
+
+		if atomic.CompareAndSwapInt32(&r.degraded, 0, 1) {
+			log.Warnf("Rate limiting with local counters: %s", err)
+		}
+	}
+
+	return r.local.Take(limits, cost, now)
+}
+
+// mostRestrictive returns the status of the quota that refused the request
+// for the longest time or, if the request was allowed, the quota with the
+// fewest remaining units.
+func mostRestrictive(quotas []config.Quota, limits []Limit, cost int64, ahead []time.Duration, allowed bool) *status {
+	var current *status
+	for i, limit := range limits {
+		s := &status{
+			quota:     quotas[i],
+			remaining: int64((limit.Window - ahead[i]) / limit.Interval),
+			reset:     ahead[i],
+		}
+		if s.remaining < 0 {
+			s.remaining = 0
+		}
+		if !allowed {
+			s.retryAfter = ahead[i] + time.Duration(cost)*limit.Interval - limit.Window
+		}
+
+		if current == nil ||
+			(!allowed && s.retryAfter > current.retryAfter) ||
+			(allowed && s.remaining < current.remaining) {
+			current = s
+		}
+	}
+	return current
+}
+
+// setHeaders reports the quota of s.  X-RateLimit-Reset is the number of
+// seconds until the whole quota is available again.
+func setHeaders(ctx context.Context, s *status) {
+	rs := ctx.Rs()
+	rs.SetHeader("X-RateLimit-Limit", strconv.FormatInt(int64(s.quota.RequestCount), 10))
+	rs.SetHeader("X-RateLimit-Remaining", strconv.FormatInt(s.remaining, 10))
+	rs.SetHeader("X-RateLimit-Reset", strconv.FormatInt(seconds(s.reset), 10))
+}
+
+// seconds rounds d up to whole seconds.
+func seconds(d time.Duration) int64 {
+	return int64((d + time.Second - 1) / time.Second)
+}
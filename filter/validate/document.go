@@ -0,0 +1,248 @@
+package validate
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ghodss/yaml"
+)
+
+type (
+	// document is a parsed OpenAPI 2 (Swagger) or OpenAPI 3 document.
+	document struct {
+		modified time.Time
+		root     map[string]interface{}
+	}
+
+	// definitions are the schemas of an operation, by location.
+	definitions struct {
+		body         map[string]interface{}
+		bodyRequired bool
+		query        map[string]interface{}
+		path         map[string]interface{}
+		headers      map[string]interface{}
+		// responses are the schemas of JSON response bodies by status.
+		// Statuses without a body have nil schemas.
+		responses map[string]map[string]interface{}
+	}
+)
+
+var (
+	documentsMu sync.Mutex
+	documents   = map[string]*document{}
+
+	// pathMethods are the operations of a path item.
+	pathMethods = []string{"get", "put", "post", "delete", "options", "head", "patch"}
+)
+
+// loadDocument reads the OpenAPI document at filename in either JSON or
+// YAML.  Documents are read again when they change.
+func loadDocument(filename string) (*document, error) {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	documentsMu.Lock()
+	defer documentsMu.Unlock()
+
+	if doc, ok := documents[filename]; ok && doc.modified.Equal(info.ModTime()) {
+		return doc, nil
+	}
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	data, err = yaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &document{modified: info.ModTime()}
+	err = json.Unmarshal(data, &doc.root)
+	if err != nil {
+		return nil, err
+	}
+
+	documents[filename] = doc
+	return doc, nil
+}
+
+// resolve returns the object that a local reference such as
+// "#/components/schemas/Pet" points to.
+func (d *document) resolve(ref string) (map[string]interface{}, error) {
+	if !strings.HasPrefix(ref, "#/") {
+		return nil, fmt.Errorf("Unsupported reference %s, only local references are resolved", ref)
+	}
+
+	var current interface{} = d.root
+	for _, token := range strings.Split(ref[2:], "/") {
+		token = strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("Reference %s not found", ref)
+		}
+		if current, ok = object[token]; !ok {
+			return nil, fmt.Errorf("Reference %s not found", ref)
+		}
+	}
+
+	object, ok := current.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("Reference %s is not an object", ref)
+	}
+	return object, nil
+}
+
+// deref follows the reference of object, if it has one.
+func (d *document) deref(object map[string]interface{}) (map[string]interface{}, error) {
+	if ref, ok := object["$ref"].(string); ok {
+		return d.resolve(ref)
+	}
+	return object, nil
+}
+
+// operation returns the schemas of the operation with operationID.
+// Parameters of the path item are overridden by those of the operation.
+func (d *document) operation(operationID string) (*definitions, error) {
+	paths, _ := d.root["paths"].(map[string]interface{})
+	for _, item := range paths {
+		pathItem, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		for _, method := range pathMethods {
+			operation, ok := pathItem[method].(map[string]interface{})
+			if !ok || operation["operationId"] != operationID {
+				continue
+			}
+
+			defs := &definitions{}
+			parameters, _ := pathItem["parameters"].([]interface{})
+			operationParameters, _ := operation["parameters"].([]interface{})
+			for _, parameter := range append(parameters, operationParameters...) {
+				object, ok := parameter.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				object, err := d.deref(object)
+				if err != nil {
+					return nil, err
+				}
+				defs.addParameter(object)
+			}
+
+			if requestBody, ok := operation["requestBody"].(map[string]interface{}); ok {
+				requestBody, err := d.deref(requestBody)
+				if err != nil {
+					return nil, err
+				}
+				defs.bodyRequired, _ = requestBody["required"].(bool)
+				defs.body = jsonSchema(requestBody)
+			}
+
+			responses, _ := operation["responses"].(map[string]interface{})
+			for status, value := range responses {
+				response, ok := value.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				response, err := d.deref(response)
+				if err != nil {
+					return nil, err
+				}
+				if defs.responses == nil {
+					defs.responses = map[string]map[string]interface{}{}
+				}
+				if definition, ok := response["schema"].(map[string]interface{}); ok {
+					defs.responses[status] = definition
+				} else {
+					defs.responses[status] = jsonSchema(response)
+				}
+			}
+
+			return defs, nil
+		}
+	}
+
+	return nil, fmt.Errorf("Operation %q not found", operationID)
+}
+
+// addParameter adds the schema of an OpenAPI parameter.  Parameters of
+// OpenAPI 2 are their own schemas, and its body parameter is the schema of
+// the request body.
+func (d *definitions) addParameter(parameter map[string]interface{}) {
+	name, _ := parameter["name"].(string)
+	required, _ := parameter["required"].(bool)
+	definition, ok := parameter["schema"].(map[string]interface{})
+	if !ok {
+		definition = parameter
+	}
+
+	var location *map[string]interface{}
+	switch parameter["in"] {
+	case "body":
+		d.body, d.bodyRequired = definition, required
+		return
+	case "query":
+		location = &d.query
+	case "path":
+		location = &d.path
+	case "header":
+		location = &d.headers
+	default:
+		return
+	}
+
+	if *location == nil {
+		*location = map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{},
+		}
+	}
+	object := *location
+	object["properties"].(map[string]interface{})[name] = definition
+
+	// An overriding parameter may no longer be required
+	var names []interface{}
+	existing, _ := object["required"].([]interface{})
+	for _, n := range existing {
+		if n != name {
+			names = append(names, n)
+		}
+	}
+	if required {
+		names = append(names, name)
+	}
+	object["required"] = names
+}
+
+// jsonSchema returns the schema of the JSON media type of an OpenAPI 3
+// request body or response.
+func jsonSchema(object map[string]interface{}) map[string]interface{} {
+	content, _ := object["content"].(map[string]interface{})
+	for mediaType, value := range content {
+		if !isJSON(mediaType) {
+			continue
+		}
+		if mediaTypeObject, ok := value.(map[string]interface{}); ok {
+			definition, _ := mediaTypeObject["schema"].(map[string]interface{})
+			return definition
+		}
+	}
+	return nil
+}
+
+// isJSON reports whether contentType is a JSON media type, such as
+// "application/json; charset=utf-8" or "application/problem+json".
+func isJSON(contentType string) bool {
+	mediaType := strings.ToLower(strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0]))
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
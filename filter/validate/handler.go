@@ -0,0 +1,19 @@
+package validate
+
+import (
+	"github.com/prizem-io/gateway/admin"
+	"github.com/prizem-io/gateway/context"
+	"github.com/prizem-io/gateway/server"
+)
+
+// Routes registers the contract violations route.  It is intended to be
+// passed to server.AddBuildRouterCallbacks.
+func Routes(router server.Router) {
+	router.GET(admin.Prefix()+"/contracts", admin.Protect(ContractsHandler))
+}
+
+// ContractsHandler serves the number of responses that broke their
+// contract per operation.
+func ContractsHandler(ctx context.Context) {
+	ctx.SendEntity(GetContractViolations())
+}
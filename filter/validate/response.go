@@ -0,0 +1,183 @@
+package validate
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/prizem-io/gateway/context"
+	ef "github.com/prizem-io/gateway/errorfactory"
+)
+
+const (
+	reportMode  = "report"
+	enforceMode = "enforce"
+	offMode     = "off"
+
+	// sampleSize is the number of bytes of a response body that are logged
+	// with its violations.
+	sampleSize = 512
+)
+
+type (
+	// ContractViolations is the number of responses of an operation that
+	// broke its contract.
+	ContractViolations struct {
+		Service   string `json:"service"`
+		Operation string `json:"operation"`
+		Count     int64  `json:"count"`
+	}
+
+	contractKey struct {
+		service   string
+		operation string
+	}
+)
+
+var (
+	contractMu     sync.Mutex
+	contractCounts = map[contractKey]int64{}
+)
+
+// validateResponse checks the response of the upstream against the
+// response schemas of every policy.  Violations are logged and counted,
+// and replace the response with an error if any policy that found them
+// enforces its contract.
+func (p policies) validateResponse(ctx context.Context) error {
+	var violations []Violation
+	enforce := false
+	for i := range p {
+		if p[i].responses == nil {
+			continue
+		}
+		found := p[i].validateResponse(ctx, nil)
+		if len(found) > 0 && p[i].ResponseMode == enforceMode {
+			enforce = true
+		}
+		violations = append(violations, found...)
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+
+	rs := ctx.Rs()
+	service, operation := ctx.Service().Name, ctx.Operation().Name
+	countViolation(service, operation)
+
+	sample := rs.Body()
+	if len(sample) > sampleSize {
+		sample = sample[:sampleSize]
+	}
+	log.WithFields(log.Fields{
+		"service":    service,
+		"operation":  operation,
+		"status":     rs.StatusCode(),
+		"violations": violations,
+		"sample":     string(sample),
+		"enforced":   enforce,
+	}).Warn("Upstream response breaks its contract")
+
+	if !enforce {
+		return nil
+	}
+
+	// Nothing of the upstream response is passed on.  Filters that run
+	// before this one, such as cors and ratelimit, set their headers again
+	// once it returns.  The violations are only logged, as they describe
+	// the upstream to clients.
+	rs.Reset()
+	err := ef.New(ctx, "contractViolation", ef.Params{
+		"service": service,
+	})
+	rs.SetStatusCode(err.Status)
+	return err
+}
+
+func (c *validateConfig) validateResponse(ctx context.Context, violations []Violation) []Violation {
+	rs := ctx.Rs()
+	status := rs.StatusCode()
+	s, ok := c.responseSchema(status)
+	if !ok {
+		return append(violations, Violation{
+			Field:   "status",
+			Message: strconv.Itoa(status) + " is not a documented status",
+		})
+	}
+	// Streamed bodies cannot be read without consuming them
+	if s == nil || rs.IsBodyStream() {
+		return violations
+	}
+
+	body := rs.Body()
+	if strings.EqualFold(rs.Header("Content-Encoding"), "gzip") {
+		var err error
+		if body, err = rs.BodyGunzip(); err != nil {
+			return append(violations, Violation{
+				Field:   "body",
+				Message: "must be valid gzip",
+			})
+		}
+	}
+
+	return validateJSON(s, true, rs.Header("Content-Type"), body, violations)
+}
+
+// responseSchema returns the schema of responses with status, from the
+// exact status, its range or the default response, and whether the status
+// is documented.
+func (c *validateConfig) responseSchema(status int) (*schema, bool) {
+	code := strconv.Itoa(status)
+	for _, key := range []string{code, code[:1] + "XX", code[:1] + "xx", "default"} {
+		if s, ok := c.responses[key]; ok {
+			return s, true
+		}
+	}
+	return nil, false
+}
+
+func validStatus(status string) bool {
+	if status == "default" {
+		return true
+	}
+	if len(status) != 3 || status[0] < '1' || status[0] > '5' {
+		return false
+	}
+	rest := strings.ToUpper(status[1:])
+	if rest == "XX" {
+		return true
+	}
+	_, err := strconv.Atoi(status)
+	return err == nil
+}
+
+func countViolation(service, operation string) {
+	contractMu.Lock()
+	contractCounts[contractKey{service, operation}]++
+	contractMu.Unlock()
+}
+
+// GetContractViolations returns the number of responses that broke their
+// contract, per operation, since the gateway started.
+func GetContractViolations() []ContractViolations {
+	contractMu.Lock()
+	counts := make([]ContractViolations, 0, len(contractCounts))
+	for key, count := range contractCounts {
+		counts = append(counts, ContractViolations{
+			Service:   key.service,
+			Operation: key.operation,
+			Count:     count,
+		})
+	}
+	contractMu.Unlock()
+
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Service != counts[j].Service {
+			return counts[i].Service < counts[j].Service
+		}
+		return counts[i].Operation < counts[j].Operation
+	})
+	return counts
+}
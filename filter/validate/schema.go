@@ -0,0 +1,570 @@
+package validate
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+type (
+	// schema is a compiled JSON Schema.  The keywords of draft 4 and of
+	// OpenAPI schema objects that describe request data are supported:
+	// type, nullable, enum, const, properties, required,
+	// additionalProperties, minProperties, maxProperties, items,
+	// minItems, maxItems, uniqueItems, minimum, maximum,
+	// exclusiveMinimum, exclusiveMaximum, multipleOf, minLength,
+	// maxLength, pattern, format, allOf, anyOf, oneOf, not and local $ref.
+	schema struct {
+		types    []string
+		nullable bool
+		enum     []interface{}
+
+		properties           map[string]*schema
+		required             []string
+		additionalProperties *schema
+		noAdditional         bool
+		minProperties        *int
+		maxProperties        *int
+
+		items       *schema
+		minItems    *int
+		maxItems    *int
+		uniqueItems bool
+
+		minimum          *float64
+		maximum          *float64
+		exclusiveMinimum bool
+		exclusiveMaximum bool
+		multipleOf       *float64
+
+		minLength *int
+		maxLength *int
+		pattern   *regexp.Regexp
+		format    string
+
+		allOf []*schema
+		anyOf []*schema
+		oneOf []*schema
+		not   *schema
+	}
+
+	// Violation describes why a value does not match its schema.
+	Violation struct {
+		Field   string `json:"field"`
+		Message string `json:"message"`
+
+		missing bool
+	}
+
+	// resolver returns the schema that a $ref refers to.
+	resolver func(ref string) (map[string]interface{}, error)
+
+	compiler struct {
+		resolve resolver
+		refs    map[string]*schema
+	}
+)
+
+var formats = map[string]func(string) bool{
+	"date-time": func(value string) bool {
+		_, err := time.Parse(time.RFC3339, value)
+		return err == nil
+	},
+	"date": func(value string) bool {
+		_, err := time.Parse("2006-01-02", value)
+		return err == nil
+	},
+	"email": func(value string) bool {
+		at := strings.LastIndexByte(value, '@')
+		return at > 0 && at < len(value)-1
+	},
+	"uuid": regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`).MatchString,
+	"ipv4": func(value string) bool {
+		ip := net.ParseIP(value)
+		return ip != nil && ip.To4() != nil && !strings.Contains(value, ":")
+	},
+	"ipv6": func(value string) bool {
+		return net.ParseIP(value) != nil && strings.Contains(value, ":")
+	},
+	"uri": func(value string) bool {
+		u, err := url.Parse(value)
+		return err == nil && u.Scheme != ""
+	},
+}
+
+func (v Violation) String() string {
+	return v.Field + " " + v.Message
+}
+
+func newCompiler(resolve resolver) *compiler {
+	return &compiler{
+		resolve: resolve,
+		refs:    map[string]*schema{},
+	}
+}
+
+func (c *compiler) compile(definition map[string]interface{}) (*schema, error) {
+	if ref, ok := definition["$ref"].(string); ok {
+		return c.compileRef(ref)
+	}
+
+	s := &schema{}
+
+	switch types := definition["type"].(type) {
+	case string:
+		s.types = []string{types}
+	case []interface{}:
+		for _, t := range types {
+			s.types = append(s.types, fmt.Sprint(t))
+		}
+	}
+	s.nullable, _ = definition["nullable"].(bool)
+	if enum, ok := definition["enum"].([]interface{}); ok {
+		s.enum = enum
+	}
+	if value, ok := definition["const"]; ok {
+		s.enum = []interface{}{value}
+	}
+
+	if properties, ok := definition["properties"].(map[string]interface{}); ok {
+		s.properties = make(map[string]*schema, len(properties))
+		for name, property := range properties {
+			compiled, err := c.compileValue(property)
+			if err != nil {
+				return nil, fmt.Errorf("properties.%s: %s", name, err)
+			}
+			s.properties[name] = compiled
+		}
+	}
+	if required, ok := definition["required"].([]interface{}); ok {
+		for _, name := range required {
+			s.required = append(s.required, fmt.Sprint(name))
+		}
+	}
+	switch additional := definition["additionalProperties"].(type) {
+	case bool:
+		s.noAdditional = !additional
+	case map[string]interface{}:
+		compiled, err := c.compile(additional)
+		if err != nil {
+			return nil, fmt.Errorf("additionalProperties: %s", err)
+		}
+		s.additionalProperties = compiled
+	}
+	s.minProperties = intKeyword(definition, "minProperties")
+	s.maxProperties = intKeyword(definition, "maxProperties")
+
+	if items, ok := definition["items"]; ok {
+		compiled, err := c.compileValue(items)
+		if err != nil {
+			return nil, fmt.Errorf("items: %s", err)
+		}
+		s.items = compiled
+	}
+	s.minItems = intKeyword(definition, "minItems")
+	s.maxItems = intKeyword(definition, "maxItems")
+	s.uniqueItems, _ = definition["uniqueItems"].(bool)
+
+	s.minimum = numberKeyword(definition, "minimum")
+	s.maximum = numberKeyword(definition, "maximum")
+	// Exclusive bounds are flags in draft 4 and OpenAPI 3.0 and bounds of
+	// their own in later drafts
+	switch exclusive := definition["exclusiveMinimum"].(type) {
+	case bool:
+		s.exclusiveMinimum = exclusive
+	case float64:
+		s.minimum, s.exclusiveMinimum = &exclusive, true
+	}
+	switch exclusive := definition["exclusiveMaximum"].(type) {
+	case bool:
+		s.exclusiveMaximum = exclusive
+	case float64:
+		s.maximum, s.exclusiveMaximum = &exclusive, true
+	}
+	s.multipleOf = numberKeyword(definition, "multipleOf")
+
+	s.minLength = intKeyword(definition, "minLength")
+	s.maxLength = intKeyword(definition, "maxLength")
+	if pattern, ok := definition["pattern"].(string); ok {
+		compiled, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("pattern: %s", err)
+		}
+		s.pattern = compiled
+	}
+	s.format, _ = definition["format"].(string)
+
+	var err error
+	if s.allOf, err = c.compileList(definition, "allOf"); err != nil {
+		return nil, err
+	}
+	if s.anyOf, err = c.compileList(definition, "anyOf"); err != nil {
+		return nil, err
+	}
+	if s.oneOf, err = c.compileList(definition, "oneOf"); err != nil {
+		return nil, err
+	}
+	if not, ok := definition["not"]; ok {
+		if s.not, err = c.compileValue(not); err != nil {
+			return nil, fmt.Errorf("not: %s", err)
+		}
+	}
+
+	return s, nil
+}
+
+// compileRef compiles the schema that ref refers to once, so that
+// recursive schemas refer to themselves.
+func (c *compiler) compileRef(ref string) (*schema, error) {
+	if s, ok := c.refs[ref]; ok {
+		return s, nil
+	}
+	if c.resolve == nil {
+		return nil, fmt.Errorf("Cannot resolve %s outside of a document", ref)
+	}
+
+	definition, err := c.resolve(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &schema{}
+	c.refs[ref] = s
+	compiled, err := c.compile(definition)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", ref, err)
+	}
+	*s = *compiled
+
+	return s, nil
+}
+
+func (c *compiler) compileValue(value interface{}) (*schema, error) {
+	definition, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("schema must be an object")
+	}
+	return c.compile(definition)
+}
+
+func (c *compiler) compileList(definition map[string]interface{}, keyword string) ([]*schema, error) {
+	values, ok := definition[keyword].([]interface{})
+	if !ok {
+		return nil, nil
+	}
+
+	schemas := make([]*schema, len(values))
+	for i, value := range values {
+		compiled, err := c.compileValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s[%d]: %s", keyword, i, err)
+		}
+		schemas[i] = compiled
+	}
+	return schemas, nil
+}
+
+func intKeyword(definition map[string]interface{}, keyword string) *int {
+	if value, ok := definition[keyword].(float64); ok {
+		i := int(value)
+		return &i
+	}
+	return nil
+}
+
+func numberKeyword(definition map[string]interface{}, keyword string) *float64 {
+	if value, ok := definition[keyword].(float64); ok {
+		return &value
+	}
+	return nil
+}
+
+// validate appends the violations of value, found at field, to violations.
+func (s *schema) validate(field string, value interface{}, violations []Violation) []Violation {
+	if value == nil && s.nullable {
+		return violations
+	}
+
+	if len(s.types) > 0 && !s.hasType(value) {
+		return append(violations, Violation{
+			Field:   field,
+			Message: "must be of type " + strings.Join(s.types, " or "),
+		})
+	}
+
+	if len(s.enum) > 0 && !containsValue(s.enum, value) {
+		violations = append(violations, Violation{
+			Field:   field,
+			Message: "must be one of " + encode(s.enum),
+		})
+	}
+
+	switch value := value.(type) {
+	case map[string]interface{}:
+		violations = s.validateObject(field, value, violations)
+	case []interface{}:
+		violations = s.validateArray(field, value, violations)
+	case float64:
+		violations = s.validateNumber(field, value, violations)
+	case string:
+		violations = s.validateString(field, value, violations)
+	}
+
+	for _, sub := range s.allOf {
+		violations = sub.validate(field, value, violations)
+	}
+	if len(s.anyOf) > 0 && s.matching(s.anyOf, field, value) == 0 {
+		violations = append(violations, Violation{
+			Field:   field,
+			Message: "must match at least one schema of anyOf",
+		})
+	}
+	if len(s.oneOf) > 0 && s.matching(s.oneOf, field, value) != 1 {
+		violations = append(violations, Violation{
+			Field:   field,
+			Message: "must match exactly one schema of oneOf",
+		})
+	}
+	if s.not != nil && len(s.not.validate(field, value, nil)) == 0 {
+		violations = append(violations, Violation{
+			Field:   field,
+			Message: "must not match the schema of not",
+		})
+	}
+
+	return violations
+}
+
+func (s *schema) validateObject(field string, value map[string]interface{}, violations []Violation) []Violation {
+	for _, name := range s.required {
+		if _, ok := value[name]; !ok {
+			violations = append(violations, Violation{
+				Field:   join(field, name),
+				Message: "is required",
+				missing: true,
+			})
+		}
+	}
+
+	names := make([]string, 0, len(value))
+	for name := range value {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if property, ok := s.properties[name]; ok {
+			violations = property.validate(join(field, name), value[name], violations)
+		} else if s.additionalProperties != nil {
+			violations = s.additionalProperties.validate(join(field, name), value[name], violations)
+		} else if s.noAdditional {
+			violations = append(violations, Violation{
+				Field:   join(field, name),
+				Message: "is not allowed",
+			})
+		}
+	}
+
+	if s.minProperties != nil && len(value) < *s.minProperties {
+		violations = append(violations, Violation{
+			Field:   field,
+			Message: fmt.Sprintf("must have at least %d properties", *s.minProperties),
+		})
+	}
+	if s.maxProperties != nil && len(value) > *s.maxProperties {
+		violations = append(violations, Violation{
+			Field:   field,
+			Message: fmt.Sprintf("must have at most %d properties", *s.maxProperties),
+		})
+	}
+
+	return violations
+}
+
+func (s *schema) validateArray(field string, value []interface{}, violations []Violation) []Violation {
+	if s.items != nil {
+		for i, item := range value {
+			violations = s.items.validate(fmt.Sprintf("%s[%d]", field, i), item, violations)
+		}
+	}
+
+	if s.minItems != nil && len(value) < *s.minItems {
+		violations = append(violations, Violation{
+			Field:   field,
+			Message: fmt.Sprintf("must have at least %d items", *s.minItems),
+		})
+	}
+	if s.maxItems != nil && len(value) > *s.maxItems {
+		violations = append(violations, Violation{
+			Field:   field,
+			Message: fmt.Sprintf("must have at most %d items", *s.maxItems),
+		})
+	}
+	if s.uniqueItems {
+		for i := range value {
+			if containsValue(value[:i], value[i]) {
+				violations = append(violations, Violation{
+					Field:   field,
+					Message: "must not contain duplicate items",
+				})
+				break
+			}
+		}
+	}
+
+	return violations
+}
+
+func (s *schema) validateNumber(field string, value float64, violations []Violation) []Violation {
+	if s.minimum != nil {
+		if s.exclusiveMinimum && value <= *s.minimum {
+			violations = append(violations, Violation{
+				Field:   field,
+				Message: fmt.Sprintf("must be greater than %v", *s.minimum),
+			})
+		} else if value < *s.minimum {
+			violations = append(violations, Violation{
+				Field:   field,
+				Message: fmt.Sprintf("must be at least %v", *s.minimum),
+			})
+		}
+	}
+	if s.maximum != nil {
+		if s.exclusiveMaximum && value >= *s.maximum {
+			violations = append(violations, Violation{
+				Field:   field,
+				Message: fmt.Sprintf("must be less than %v", *s.maximum),
+			})
+		} else if value > *s.maximum {
+			violations = append(violations, Violation{
+				Field:   field,
+				Message: fmt.Sprintf("must be at most %v", *s.maximum),
+			})
+		}
+	}
+	if s.multipleOf != nil && *s.multipleOf > 0 {
+		quotient := value / *s.multipleOf
+		if math.Abs(quotient-math.Round(quotient)) > 1e-9 {
+			violations = append(violations, Violation{
+				Field:   field,
+				Message: fmt.Sprintf("must be a multiple of %v", *s.multipleOf),
+			})
+		}
+	}
+
+	return violations
+}
+
+func (s *schema) validateString(field string, value string, violations []Violation) []Violation {
+	length := len([]rune(value))
+	if s.minLength != nil && length < *s.minLength {
+		violations = append(violations, Violation{
+			Field:   field,
+			Message: fmt.Sprintf("must be at least %d characters long", *s.minLength),
+		})
+	}
+	if s.maxLength != nil && length > *s.maxLength {
+		violations = append(violations, Violation{
+			Field:   field,
+			Message: fmt.Sprintf("must be at most %d characters long", *s.maxLength),
+		})
+	}
+	if s.pattern != nil && !s.pattern.MatchString(value) {
+		violations = append(violations, Violation{
+			Field:   field,
+			Message: "must match the pattern " + s.pattern.String(),
+		})
+	}
+	// Unknown formats are not checked
+	if check, ok := formats[s.format]; ok && !check(value) {
+		violations = append(violations, Violation{
+			Field:   field,
+			Message: "must be a valid " + s.format,
+		})
+	}
+
+	return violations
+}
+
+func (s *schema) hasType(value interface{}) bool {
+	for _, t := range s.types {
+		switch value := value.(type) {
+		case nil:
+			if t == "null" {
+				return true
+			}
+		case bool:
+			if t == "boolean" {
+				return true
+			}
+		case float64:
+			if t == "number" || (t == "integer" && value == math.Trunc(value)) {
+				return true
+			}
+		case string:
+			if t == "string" {
+				return true
+			}
+		case []interface{}:
+			if t == "array" {
+				return true
+			}
+		case map[string]interface{}:
+			if t == "object" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// allowsType reports whether the schema accepts values of type t.
+func (s *schema) allowsType(t string) bool {
+	if len(s.types) == 0 {
+		return true
+	}
+	for _, allowed := range s.types {
+		if allowed == t {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *schema) matching(schemas []*schema, field string, value interface{}) int {
+	count := 0
+	for _, sub := range schemas {
+		if len(sub.validate(field, value, nil)) == 0 {
+			count++
+		}
+	}
+	return count
+}
+
+func containsValue(values []interface{}, value interface{}) bool {
+	for _, candidate := range values {
+		if reflect.DeepEqual(candidate, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func encode(value interface{}) string {
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+func join(field, name string) string {
+	if field == "" {
+		return name
+	}
+	return field + "." + name
+}
@@ -0,0 +1,280 @@
+package validate
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func compileSchema(t *testing.T, definition string, definitions map[string]string) *schema {
+	t.Helper()
+	c := newCompiler(func(ref string) (map[string]interface{}, error) {
+		definition, ok := definitions[ref]
+		if !ok {
+			return nil, fmt.Errorf("Unknown reference %s", ref)
+		}
+		return decodeObject(t, definition), nil
+	})
+	s, err := c.compile(decodeObject(t, definition))
+	if err != nil {
+		t.Fatalf("Could not compile %s: %s", definition, err)
+	}
+	return s
+}
+
+func decodeObject(t *testing.T, data string) map[string]interface{} {
+	t.Helper()
+	var object map[string]interface{}
+	if err := json.Unmarshal([]byte(data), &object); err != nil {
+		t.Fatalf("Invalid JSON %s: %s", data, err)
+	}
+	return object
+}
+
+// violationStrings returns nil if there are no violations.
+func violationStrings(violations []Violation) []string {
+	var strings []string
+	for _, violation := range violations {
+		strings = append(strings, violation.String())
+	}
+	return strings
+}
+
+func TestSchemaKeywords(t *testing.T) {
+	tests := []struct {
+		name       string
+		schema     string
+		value      string
+		violations []string
+	}{
+		{"type", `{"type": "string"}`, `"a"`, nil},
+		{"type mismatch", `{"type": "string"}`, `1`, []string{"v must be of type string"}},
+		{"type list", `{"type": ["string", "null"]}`, `null`, nil},
+		{"integer", `{"type": "integer"}`, `2`, nil},
+		{"integer fraction", `{"type": "integer"}`, `2.5`, []string{"v must be of type integer"}},
+		{"nullable", `{"type": "string", "nullable": true}`, `null`, nil},
+		{"not nullable", `{"type": "string"}`, `null`, []string{"v must be of type string"}},
+		{"enum", `{"enum": ["a", "b"]}`, `"b"`, nil},
+		{"enum mismatch", `{"enum": ["a", "b"]}`, `"c"`, []string{`v must be one of ["a","b"]`}},
+		{"const", `{"const": 3}`, `3`, nil},
+		{"const mismatch", `{"const": 3}`, `4`, []string{"v must be one of [3]"}},
+
+		{"properties", `{"properties": {"a": {"type": "string"}}}`, `{"a": 1}`, []string{"v.a must be of type string"}},
+		{"required", `{"required": ["a", "b"]}`, `{"a": 1}`, []string{"v.b is required"}},
+		{"additionalProperties false", `{"properties": {"a": {}}, "additionalProperties": false}`, `{"a": 1, "b": 2}`, []string{"v.b is not allowed"}},
+		{"additionalProperties schema", `{"additionalProperties": {"type": "number"}}`, `{"a": 1, "b": "x"}`, []string{"v.b must be of type number"}},
+		{"minProperties", `{"minProperties": 2}`, `{"a": 1}`, []string{"v must have at least 2 properties"}},
+		{"maxProperties", `{"maxProperties": 1}`, `{"a": 1, "b": 2}`, []string{"v must have at most 1 properties"}},
+
+		{"items", `{"items": {"type": "number"}}`, `[1, "a", 2]`, []string{"v[1] must be of type number"}},
+		{"minItems", `{"minItems": 2}`, `[1]`, []string{"v must have at least 2 items"}},
+		{"maxItems", `{"maxItems": 1}`, `[1, 2]`, []string{"v must have at most 1 items"}},
+		{"uniqueItems", `{"uniqueItems": true}`, `[{"a": 1}, {"a": 1}]`, []string{"v must not contain duplicate items"}},
+		{"uniqueItems distinct", `{"uniqueItems": true}`, `[1, 2]`, nil},
+
+		{"minimum", `{"minimum": 1}`, `1`, nil},
+		{"below minimum", `{"minimum": 1}`, `0.5`, []string{"v must be at least 1"}},
+		{"maximum", `{"maximum": 1}`, `1`, nil},
+		{"above maximum", `{"maximum": 1}`, `2`, []string{"v must be at most 1"}},
+		{"multipleOf", `{"multipleOf": 0.1}`, `0.3`, nil},
+		{"not multipleOf", `{"multipleOf": 2}`, `3`, []string{"v must be a multiple of 2"}},
+
+		{"minLength", `{"minLength": 2}`, `"é"`, []string{"v must be at least 2 characters long"}},
+		{"maxLength", `{"maxLength": 2}`, `"éé"`, nil},
+		{"above maxLength", `{"maxLength": 2}`, `"abc"`, []string{"v must be at most 2 characters long"}},
+		{"pattern", `{"pattern": "^a+$"}`, `"aa"`, nil},
+		{"pattern mismatch", `{"pattern": "^a+$"}`, `"ab"`, []string{"v must match the pattern ^a+$"}},
+		{"format date-time", `{"format": "date-time"}`, `"2017-01-02T03:04:05Z"`, nil},
+		{"format date", `{"format": "date"}`, `"2017-13-01"`, []string{"v must be a valid date"}},
+		{"format email", `{"format": "email"}`, `"a@"`, []string{"v must be a valid email"}},
+		{"format uuid", `{"format": "uuid"}`, `"123e4567-e89b-12d3-a456-426655440000"`, nil},
+		{"format ipv4", `{"format": "ipv4"}`, `"::1"`, []string{"v must be a valid ipv4"}},
+		{"format ipv6", `{"format": "ipv6"}`, `"::1"`, nil},
+		{"format uri", `{"format": "uri"}`, `"/relative"`, []string{"v must be a valid uri"}},
+		{"unknown format", `{"format": "color"}`, `"x"`, nil},
+		{"formats of strings only", `{"format": "date"}`, `1`, nil},
+
+		{"allOf", `{"allOf": [{"minimum": 1}, {"maximum": 2}]}`, `3`, []string{"v must be at most 2"}},
+		{"anyOf", `{"anyOf": [{"type": "string"}, {"type": "number"}]}`, `1`, nil},
+		{"anyOf mismatch", `{"anyOf": [{"type": "string"}, {"type": "number"}]}`, `true`, []string{"v must match at least one schema of anyOf"}},
+		{"oneOf", `{"oneOf": [{"minimum": 1}, {"maximum": 0}]}`, `2`, nil},
+		{"oneOf both", `{"oneOf": [{"minimum": 1}, {"maximum": 3}]}`, `2`, []string{"v must match exactly one schema of oneOf"}},
+		{"not", `{"not": {"type": "string"}}`, `"a"`, []string{"v must not match the schema of not"}},
+		{"not mismatch", `{"not": {"type": "string"}}`, `1`, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := compileSchema(t, test.schema, nil)
+			var value interface{}
+			if err := json.Unmarshal([]byte(test.value), &value); err != nil {
+				t.Fatal(err)
+			}
+
+			violations := violationStrings(s.validate("v", value, nil))
+			if !reflect.DeepEqual(violations, test.violations) {
+				t.Errorf("validate(%s) = %q, expected %q", test.value, violations, test.violations)
+			}
+		})
+	}
+}
+
+func TestSchemaExclusiveBounds(t *testing.T) {
+	tests := []struct {
+		name       string
+		schema     string
+		value      float64
+		violations []string
+	}{
+		// Draft 4 and OpenAPI 3.0 flag the minimum and maximum as exclusive
+		{"draft 4 minimum", `{"minimum": 1, "exclusiveMinimum": true}`, 1, []string{"v must be greater than 1"}},
+		{"draft 4 above minimum", `{"minimum": 1, "exclusiveMinimum": true}`, 1.5, nil},
+		{"draft 4 inclusive minimum", `{"minimum": 1, "exclusiveMinimum": false}`, 1, nil},
+		{"draft 4 maximum", `{"maximum": 1, "exclusiveMaximum": true}`, 1, []string{"v must be less than 1"}},
+		{"draft 4 below maximum", `{"maximum": 1, "exclusiveMaximum": true}`, 0.5, nil},
+		// Later drafts give the exclusive bounds as numbers
+		{"draft 6 minimum", `{"exclusiveMinimum": 1}`, 1, []string{"v must be greater than 1"}},
+		{"draft 6 above minimum", `{"exclusiveMinimum": 1}`, 1.5, nil},
+		{"draft 6 maximum", `{"exclusiveMaximum": 1}`, 1, []string{"v must be less than 1"}},
+		{"draft 6 below maximum", `{"exclusiveMaximum": 1}`, 0.5, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := compileSchema(t, test.schema, nil)
+			violations := violationStrings(s.validate("v", test.value, nil))
+			if !reflect.DeepEqual(violations, test.violations) {
+				t.Errorf("validate(%v) = %q, expected %q", test.value, violations, test.violations)
+			}
+		})
+	}
+}
+
+func TestSchemaRef(t *testing.T) {
+	definitions := map[string]string{
+		"#/definitions/node": `{
+			"type": "object",
+			"required": ["name"],
+			"properties": {
+				"name": {"type": "string"},
+				"children": {"type": "array", "items": {"$ref": "#/definitions/node"}}
+			}
+		}`,
+	}
+
+	tests := []struct {
+		name       string
+		value      string
+		violations []string
+	}{
+		{"leaf", `{"name": "a"}`, nil},
+		{"nested", `{"name": "a", "children": [{"name": "b", "children": [{"name": "c"}]}]}`, nil},
+		{"nested violation", `{"name": "a", "children": [{"name": "b", "children": [{"name": 1}]}]}`,
+			[]string{"v.children[0].children[0].name must be of type string"}},
+		{"nested missing", `{"name": "a", "children": [{}]}`, []string{"v.children[0].name is required"}},
+	}
+
+	s := compileSchema(t, `{"$ref": "#/definitions/node"}`, definitions)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var value interface{}
+			if err := json.Unmarshal([]byte(test.value), &value); err != nil {
+				t.Fatal(err)
+			}
+
+			violations := violationStrings(s.validate("v", value, nil))
+			if !reflect.DeepEqual(violations, test.violations) {
+				t.Errorf("validate(%s) = %q, expected %q", test.value, violations, test.violations)
+			}
+		})
+	}
+
+	t.Run("unresolved", func(t *testing.T) {
+		_, err := newCompiler(nil).compile(decodeObject(t, `{"$ref": "#/definitions/node"}`))
+		if err == nil {
+			t.Error("Expected an error for a reference outside of a document")
+		}
+	})
+}
+
+func TestParameters(t *testing.T) {
+	query := `{
+		"properties": {
+			"limit": {"type": "integer", "maximum": 100},
+			"ratio": {"type": "number"},
+			"active": {"type": "boolean"},
+			"name": {"type": "string"},
+			"cursor": {"type": ["null", "string"]},
+			"ids": {"type": "array", "items": {"type": "integer"}},
+			"tags": {"type": "array", "items": {"type": "string"}}
+		},
+		"additionalProperties": {"type": "integer"}
+	}`
+	path := `{
+		"properties": {
+			"id": {"type": "integer", "minimum": 1}
+		}
+	}`
+
+	tests := []struct {
+		name       string
+		schema     string
+		values     map[string][]string
+		expected   map[string]interface{}
+		violations []string
+	}{
+		{"integer", query, map[string][]string{"limit": {"10"}},
+			map[string]interface{}{"limit": 10.0}, nil},
+		{"integer bound", query, map[string][]string{"limit": {"101"}},
+			map[string]interface{}{"limit": 101.0}, []string{"query.limit must be at most 100"}},
+		{"integer unparsed", query, map[string][]string{"limit": {"ten"}},
+			map[string]interface{}{"limit": "ten"}, []string{"query.limit must be of type integer"}},
+		{"number", query, map[string][]string{"ratio": {"0.5"}},
+			map[string]interface{}{"ratio": 0.5}, nil},
+		{"boolean", query, map[string][]string{"active": {"true"}},
+			map[string]interface{}{"active": true}, nil},
+		{"boolean unparsed", query, map[string][]string{"active": {"yes"}},
+			map[string]interface{}{"active": "yes"}, []string{"query.active must be of type boolean"}},
+		{"string of digits", query, map[string][]string{"name": {"42"}},
+			map[string]interface{}{"name": "42"}, nil},
+		{"null", query, map[string][]string{"cursor": {""}},
+			map[string]interface{}{"cursor": nil}, nil},
+		{"first value of scalars", query, map[string][]string{"limit": {"1", "2"}},
+			map[string]interface{}{"limit": 1.0}, nil},
+		{"comma-separated array", query, map[string][]string{"ids": {"1,2"}},
+			map[string]interface{}{"ids": []interface{}{1.0, 2.0}}, nil},
+		{"repeated array", query, map[string][]string{"ids": {"1", "x"}},
+			map[string]interface{}{"ids": []interface{}{1.0, "x"}}, []string{"query.ids[1] must be of type integer"}},
+		{"string array", query, map[string][]string{"tags": {"1,b"}},
+			map[string]interface{}{"tags": []interface{}{"1", "b"}}, nil},
+		{"additional", query, map[string][]string{"page": {"3"}},
+			map[string]interface{}{"page": 3.0}, nil},
+		{"path", path, map[string][]string{"id": {"7"}},
+			map[string]interface{}{"id": 7.0}, nil},
+		{"path bound", path, map[string][]string{"id": {"0"}},
+			map[string]interface{}{"id": 0.0}, []string{"path.id must be at least 1"}},
+		{"path undeclared", path, map[string][]string{"other": {"7"}},
+			map[string]interface{}{"other": "7"}, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := compileSchema(t, test.schema, nil)
+			location := "query"
+			if test.schema == path {
+				location = "path"
+			}
+
+			object := parameters(s, test.values)
+			if !reflect.DeepEqual(object, test.expected) {
+				t.Errorf("parameters(%v) = %#v, expected %#v", test.values, object, test.expected)
+			}
+
+			violations := violationStrings(s.validate(location, object, nil))
+			if !reflect.DeepEqual(violations, test.violations) {
+				t.Errorf("validate(%v) = %q, expected %q", test.values, violations, test.violations)
+			}
+		})
+	}
+}
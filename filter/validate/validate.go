@@ -0,0 +1,363 @@
+package validate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+
+	"github.com/prizem-io/gateway/config"
+	"github.com/prizem-io/gateway/context"
+	ef "github.com/prizem-io/gateway/errorfactory"
+)
+
+type (
+	// Validator rejects requests whose body, query parameters, path
+	// parameters or headers do not match their JSON Schemas.
+	Validator struct {
+		PrioritySetting int `mapstructure:"priority"`
+	}
+
+	// validateConfig declares the schemas of a request inline or refers to
+	// an operation of an OpenAPI document.  Inline schemas replace those of
+	// the document for their location.
+	validateConfig struct {
+		// Body is the schema of JSON request bodies.  Empty bodies are only
+		// rejected if BodyRequired is set.
+		Body         map[string]interface{} `mapstructure:"body"`
+		BodyRequired bool                   `mapstructure:"bodyRequired"`
+		// Query, Path and Headers are object schemas whose properties are
+		// parameters.  Parameter values are converted to the types of
+		// their schemas, and arrays are comma separated.
+		Query   map[string]interface{} `mapstructure:"query"`
+		Path    map[string]interface{} `mapstructure:"path"`
+		Headers map[string]interface{} `mapstructure:"headers"`
+		// Responses are the schemas of JSON response bodies by status code,
+		// such as "200", by range, such as "2XX", or "default".  Statuses
+		// whose responses have no body map to no schema.
+		Responses map[string]map[string]interface{} `mapstructure:"responses"`
+		// ResponseMode is "report" to log and count responses that break
+		// their contract, "enforce" to also replace them with an error, or
+		// "off".  It defaults to "report".
+		ResponseMode string `mapstructure:"responseMode"`
+		// Document is the file name of an OpenAPI 2 or 3 document and
+		// OperationID the operation in it.
+		Document    string `mapstructure:"document"`
+		OperationID string `mapstructure:"operationId"`
+
+		body         *schema
+		bodyRequired bool
+		query        *schema
+		path         *schema
+		headers      *schema
+		responses    map[string]*schema
+	}
+
+	// policies are the schemas of every source of a request, all of which
+	// must accept it.
+	policies []validateConfig
+)
+
+func New() *Validator {
+	return &Validator{
+		PrioritySetting: -75,
+	}
+}
+
+func (*Validator) Name() string {
+	return "validate"
+}
+
+func (v *Validator) Priority() int {
+	return v.PrioritySetting
+}
+
+func (v *Validator) Initialize(config config.Configuration) error {
+	return config.UnmarshalKey("validate", v)
+}
+
+func (*Validator) DecodeConfig(input map[string]interface{}) (interface{}, error) {
+	var config validateConfig
+	err := mapstructure.Decode(input, &config)
+	if err != nil {
+		return nil, err
+	}
+
+	defs := &definitions{}
+	var resolve resolver
+	if config.Document != "" {
+		if config.OperationID == "" {
+			return nil, fmt.Errorf("operationId is required with document %s", config.Document)
+		}
+		doc, err := loadDocument(config.Document)
+		if err != nil {
+			return nil, err
+		}
+		defs, err = doc.operation(config.OperationID)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", config.Document, err)
+		}
+		resolve = doc.resolve
+	}
+
+	if config.Body != nil {
+		defs.body, defs.bodyRequired = config.Body, config.BodyRequired
+	}
+	if config.Query != nil {
+		defs.query = config.Query
+	}
+	if config.Path != nil {
+		defs.path = config.Path
+	}
+	if config.Headers != nil {
+		defs.headers = config.Headers
+	}
+	if config.Responses != nil {
+		defs.responses = config.Responses
+	}
+	config.bodyRequired = defs.bodyRequired
+
+	switch config.ResponseMode {
+	case "":
+		config.ResponseMode = reportMode
+	case reportMode, enforceMode, offMode:
+	default:
+		return nil, fmt.Errorf("Invalid response mode %q, expected report, enforce or off", config.ResponseMode)
+	}
+
+	c := newCompiler(resolve)
+	for _, location := range []struct {
+		name       string
+		definition map[string]interface{}
+		compiled   **schema
+	}{
+		{"body", defs.body, &config.body},
+		{"query", defs.query, &config.query},
+		{"path", defs.path, &config.path},
+		{"headers", defs.headers, &config.headers},
+	} {
+		if location.definition == nil {
+			continue
+		}
+		definition, err := normalize(location.definition)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", location.name, err)
+		}
+		*location.compiled, err = c.compile(definition)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", location.name, err)
+		}
+	}
+
+	if config.ResponseMode != offMode && len(defs.responses) > 0 {
+		config.responses = make(map[string]*schema, len(defs.responses))
+		for status, definition := range defs.responses {
+			if !validStatus(status) {
+				return nil, fmt.Errorf("responses: invalid status %q", status)
+			}
+			if definition == nil {
+				config.responses[status] = nil
+				continue
+			}
+			definition, err := normalize(definition)
+			if err != nil {
+				return nil, fmt.Errorf("responses.%s: %s", status, err)
+			}
+			config.responses[status], err = c.compile(definition)
+			if err != nil {
+				return nil, fmt.Errorf("responses.%s: %s", status, err)
+			}
+		}
+	}
+
+	return config, nil
+}
+
+// Combine keeps the schemas of each source, so that a request must match
+// those of its service as well as those of its operation.
+func (*Validator) Combine(configurations ...interface{}) (interface{}, error) {
+	combined := make(policies, 0, len(configurations))
+	for _, configuration := range configurations {
+		if policy, ok := configuration.(validateConfig); ok {
+			combined = append(combined, policy)
+		}
+	}
+	return combined, nil
+}
+
+func (*Validator) Evaluate(ctx context.Context, configuration interface{}) error {
+	combined, _ := configuration.(policies)
+
+	var violations []Violation
+	for i := range combined {
+		violations = combined[i].validate(ctx, violations)
+	}
+	if len(violations) == 0 {
+		err := ctx.Next()
+		if err != nil {
+			return err
+		}
+		return combined.validateResponse(ctx)
+	}
+
+	// The first invalid value is reported, or else the first missing one
+	reason, param := "missingParameter", violations[0].Field
+	for _, violation := range violations {
+		if !violation.missing {
+			reason, param = "invalidParameter", violation.Field
+			break
+		}
+	}
+
+	err := ef.New(ctx, reason, ef.Params{
+		"param": param,
+	})
+	err.Details = violations
+	return err
+}
+
+func (c *validateConfig) validate(ctx context.Context, violations []Violation) []Violation {
+	rq := ctx.Rq()
+
+	if c.body != nil {
+		violations = c.validateBody(rq, violations)
+	}
+	if c.query != nil {
+		values := map[string][]string{}
+		rq.URLParams(func(key, value string) {
+			values[key] = append(values[key], value)
+		})
+		violations = c.query.validate("query", parameters(c.query, values), violations)
+	}
+	if c.path != nil {
+		values := map[string][]string{}
+		rq.VisitParams(func(key, value string) {
+			values[key] = []string{value}
+		})
+		violations = c.path.validate("path", parameters(c.path, values), violations)
+	}
+	if c.headers != nil {
+		// Only the declared headers are validated, as matched
+		// case-insensitively
+		values := map[string][]string{}
+		for name := range c.headers.properties {
+			if value := rq.Header(name); value != "" {
+				values[name] = []string{value}
+			}
+		}
+		violations = c.headers.validate("headers", parameters(c.headers, values), violations)
+	}
+
+	return violations
+}
+
+func (c *validateConfig) validateBody(rq context.Request, violations []Violation) []Violation {
+	return validateJSON(c.body, c.bodyRequired, rq.Header("Content-Type"), rq.Body(), violations)
+}
+
+// validateJSON validates a JSON body against s.  Empty bodies are only
+// violations if the body is required.
+func validateJSON(s *schema, required bool, contentType string, body []byte, violations []Violation) []Violation {
+	if len(bytes.TrimSpace(body)) == 0 {
+		if required {
+			violations = append(violations, Violation{
+				Field:   "body",
+				Message: "is required",
+				missing: true,
+			})
+		}
+		return violations
+	}
+
+	if contentType != "" && !isJSON(contentType) {
+		return append(violations, Violation{
+			Field:   "body",
+			Message: "must have a JSON content type",
+		})
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return append(violations, Violation{
+			Field:   "body",
+			Message: "must be valid JSON",
+		})
+	}
+
+	return s.validate("body", value, violations)
+}
+
+// parameters converts parameter values to the types of the properties of s.
+func parameters(s *schema, values map[string][]string) map[string]interface{} {
+	object := make(map[string]interface{}, len(values))
+	for name, value := range values {
+		property := s.properties[name]
+		if property == nil {
+			property = s.additionalProperties
+		}
+		object[name] = convert(property, value)
+	}
+	return object
+}
+
+func convert(s *schema, values []string) interface{} {
+	if s == nil {
+		return values[0]
+	}
+
+	if len(s.types) > 0 && s.allowsType("array") {
+		items := []interface{}{}
+		for _, value := range values {
+			for _, item := range strings.Split(value, ",") {
+				items = append(items, convertValue(s.items, item))
+			}
+		}
+		return items
+	}
+
+	return convertValue(s, values[0])
+}
+
+// convertValue converts value to the first type of s that it parses as.
+// Values that do not parse remain strings and fail validation.
+func convertValue(s *schema, value string) interface{} {
+	if s == nil {
+		return value
+	}
+
+	for _, t := range s.types {
+		switch t {
+		case "integer", "number":
+			if number, err := strconv.ParseFloat(value, 64); err == nil {
+				return number
+			}
+		case "boolean":
+			if value == "true" || value == "false" {
+				return value == "true"
+			}
+		case "null":
+			if value == "" || value == "null" {
+				return nil
+			}
+		case "string":
+			return value
+		}
+	}
+	return value
+}
+
+// normalize converts a schema decoded from the configuration to the types
+// of encoding/json.
+func normalize(definition map[string]interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(definition)
+	if err != nil {
+		return nil, err
+	}
+
+	var normalized map[string]interface{}
+	err = json.Unmarshal(data, &normalized)
+	return normalized, err
+}
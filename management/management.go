@@ -0,0 +1,360 @@
+package management
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/prizem-io/gateway/admin"
+	"github.com/prizem-io/gateway/command"
+	"github.com/prizem-io/gateway/config"
+	"github.com/prizem-io/gateway/context"
+	ef "github.com/prizem-io/gateway/errorfactory"
+	"github.com/prizem-io/gateway/server"
+	"github.com/prizem-io/gateway/store"
+	"github.com/prizem-io/gateway/validation"
+)
+
+type (
+	managementConfig struct {
+		Enabled bool `mapstructure:"enabled"`
+		// Listen serves the API on a listener of its own when set.
+		Listen string `mapstructure:"listen"`
+		Prefix string `mapstructure:"prefix"`
+		// Actor is recorded in the auditable fields of written entities.
+		Actor string `mapstructure:"actor"`
+		// Name is the environment name reported by the summary.
+		Name string `mapstructure:"name"`
+	}
+
+	entityList struct {
+		Offset int           `json:"offset"`
+		Limit  int           `json:"limit"`
+		Count  int           `json:"count"`
+		Total  int           `json:"total"`
+		Items  []interface{} `json:"items"`
+	}
+)
+
+const (
+	basePath     = "/management/v1"
+	defaultLimit = 100
+)
+
+var (
+	_config = managementConfig{
+		Actor: "admin",
+		Name:  "default",
+	}
+	_store = NewMemoryStore()
+
+	// lock serializes writes with synchronization of the gateway configuration
+	lock sync.Mutex
+)
+
+// Initialize reads the "management" configuration.  Unless a prefix is
+// configured, the API is served under the admin prefix on the gateway
+// listener or at the base path of the specification on its own listener.
+func Initialize(configuration config.Configuration) error {
+	err := configuration.UnmarshalKey("management", &_config)
+	if err != nil {
+		return err
+	}
+
+	if _config.Prefix == "" {
+		_config.Prefix = basePath
+		if _config.Listen == "" {
+			_config.Prefix = admin.Prefix() + basePath
+		}
+	}
+
+	return nil
+}
+
+func Enabled() bool {
+	return _config.Enabled
+}
+
+// Listen returns the address of the dedicated management listener, if any.
+func Listen() string {
+	return _config.Listen
+}
+
+func SetStore(store Store) {
+	_store = store
+}
+
+// Routes registers the management API.  It is intended to be passed
+// to server.AddBuildRouterCallbacks or fasthttp.NewHandler.
+func Routes(router server.Router) {
+	prefix := _config.Prefix
+
+	for _, r := range resources {
+		router.GET(prefix+"/"+r.Name, admin.Protect(r.queryHandler))
+		router.POST(prefix+"/"+r.Name, admin.Protect(r.createHandler))
+		router.GET(prefix+"/"+r.Name+"/:id", admin.Protect(r.loadHandler))
+		router.PUT(prefix+"/"+r.Name+"/:id", admin.Protect(r.updateHandler))
+		router.DELETE(prefix+"/"+r.Name+"/:id", admin.Protect(r.deleteHandler))
+	}
+
+	consumers := resourcesByName["consumers"]
+	// The specification addresses a single consumer as /consumer/{id}
+	router.GET(prefix+"/consumer/:id", admin.Protect(consumers.loadHandler))
+	router.PUT(prefix+"/consumer/:id", admin.Protect(consumers.updateHandler))
+	router.DELETE(prefix+"/consumer/:id", admin.Protect(consumers.deleteHandler))
+
+	router.GET(prefix+"/consumers/:id/clients", admin.Protect(ConsumerClientsHandler))
+	router.GET(prefix+"/consumers/:id/developers", admin.Protect(ConsumerDevelopersHandler))
+	router.GET(prefix+"/consumers/:id/developers/:developerId", admin.Protect(ConsumerDeveloperHandler))
+	router.PUT(prefix+"/consumers/:id/developers/:developerId", admin.Protect(AddConsumerDeveloperHandler))
+	router.DELETE(prefix+"/consumers/:id/developers/:developerId", admin.Protect(RemoveConsumerDeveloperHandler))
+	router.GET(prefix+"/developers/:id/consumers", admin.Protect(DeveloperConsumersHandler))
+	router.GET(prefix+"/developers/:id/consumers/:consumerId", admin.Protect(DeveloperConsumerHandler))
+	router.PUT(prefix+"/developers/:id/consumers/:consumerId", admin.Protect(AddDeveloperConsumerHandler))
+	router.DELETE(prefix+"/developers/:id/consumers/:consumerId", admin.Protect(RemoveDeveloperConsumerHandler))
+
+	router.PUT(prefix+"/developers/:id/password", admin.Protect(passwordHandler("developers")))
+	router.PUT(prefix+"/users/:id/password", admin.Protect(passwordHandler("users")))
+	router.POST(prefix+"/developers/authenticate", admin.Protect(AuthenticateDeveloperHandler))
+
+	router.POST(prefix+"/services/import", admin.Protect(ImportServiceHandler))
+
+	router.GET(prefix+"/me/permissions", admin.Protect(PermissionsHandler))
+	router.GET(prefix+"/summary", admin.Protect(SummaryHandler))
+}
+
+func (r *resource) queryHandler(ctx context.Context) {
+	query := listQuery(ctx)
+	ctx.Rq().URLParams(func(key, value string) {
+		if key != "offset" && key != "limit" {
+			query.Filters[key] = append(query.Filters[key], strings.Split(value, "|")...)
+		}
+	})
+
+	entities, total, err := r.query(query)
+	if err != nil {
+		sendStoreError(ctx, r, "", err)
+		return
+	}
+
+	sendList(ctx, query, entities, total)
+}
+
+func (r *resource) loadHandler(ctx context.Context) {
+	id := ctx.Rq().Param("id")
+	entity, err := r.load(id)
+	if err != nil {
+		sendStoreError(ctx, r, id, err)
+		return
+	}
+
+	ctx.SendEntity(entity)
+}
+
+func (r *resource) createHandler(ctx context.Context) {
+	entity, problems, err := r.decode(ctx.Rq().Body(), nil)
+	if err != nil {
+		sendError(ctx, ef.New(ctx, "messageNotReadable"))
+		return
+	}
+	id := entityID(entity)
+
+	lock.Lock()
+	if _, err := r.load(id); err != ErrNotFound {
+		lock.Unlock()
+		if err != nil {
+			sendStoreError(ctx, r, id, err)
+		} else {
+			sendError(ctx, ef.New(ctx, "conflict"))
+		}
+		return
+	}
+
+	audit(entity, _config.Actor, true)
+	ok := r.write(ctx, id, entity, problems)
+	lock.Unlock()
+
+	if ok {
+		r.reload(id)
+		ctx.Rs().SetStatusCode(http.StatusCreated)
+		ctx.SendEntity(entity)
+	}
+}
+
+func (r *resource) updateHandler(ctx context.Context) {
+	id := ctx.Rq().Param("id")
+
+	lock.Lock()
+	existing, err := r.load(id)
+	if err != nil {
+		lock.Unlock()
+		sendStoreError(ctx, r, id, err)
+		return
+	}
+
+	entity, problems, err := r.decode(ctx.Rq().Body(), existing)
+	if err != nil {
+		lock.Unlock()
+		sendError(ctx, ef.New(ctx, "messageNotReadable"))
+		return
+	}
+
+	// Entities mirrored from the gateway configuration may not have an ID
+	setEntityID(entity, id)
+	audit(entity, _config.Actor, false)
+	ok := r.write(ctx, id, entity, problems)
+	lock.Unlock()
+
+	if ok {
+		r.reload(id)
+		ctx.SendEntity(entity)
+	}
+}
+
+func (r *resource) deleteHandler(ctx context.Context) {
+	id := ctx.Rq().Param("id")
+
+	lock.Lock()
+	existing, err := r.load(id)
+	if err != nil {
+		lock.Unlock()
+		sendStoreError(ctx, r, id, err)
+		return
+	}
+
+	ok := r.write(ctx, id, nil, nil)
+	lock.Unlock()
+
+	if ok {
+		r.reload(id)
+		ctx.SendEntity(existing)
+	}
+}
+
+// write validates and then saves entity, or deletes it if entity is nil.
+// It must be called while holding lock.  An error response is sent if the
+// write is rejected.
+func (r *resource) write(ctx context.Context, id string, entity interface{}, problems []validation.Problem) bool {
+	// Only check the gateway configuration once the entity itself is valid
+	if len(problems) == 0 {
+		problems = checkGatewayConfig(r, id, entity)
+	}
+	if len(problems) > 0 {
+		sendProblems(ctx, problems)
+		return false
+	}
+
+	var err error
+	if r.configField != "" {
+		err = markManaged(r)
+	}
+	if err == nil {
+		if entity != nil {
+			err = _store.Save(r.Name, id, entity)
+		} else {
+			err = _store.Delete(r.Name, id)
+			if err == nil {
+				err = removeLinks(r, id)
+			}
+		}
+	}
+	if err != nil {
+		sendStoreError(ctx, r, id, err)
+		return false
+	}
+
+	return true
+}
+
+// reload applies a write to every gateway when the resource is part of the
+// gateway configuration.  Resources that are read from the store are only
+// invalidated in case the gateways cache them.
+func (r *resource) reload(id string) {
+	if r.configField == "" {
+		return
+	}
+
+	if r.live() {
+		command.Publish("invalidate", command.Params{
+			"entity": r.EntityName,
+			"id":     id,
+		})
+		return
+	}
+
+	command.Publish("reload", command.Params{
+		"resource": r.Name,
+		"id":       id,
+	})
+}
+
+// listQuery returns a query for the page that the offset and limit
+// parameters of the request select.
+func listQuery(ctx context.Context) store.Query {
+	offset, err := ctx.Rq().URLParamInt("offset")
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+	limit, err := ctx.Rq().URLParamInt("limit")
+	if err != nil || limit <= 0 {
+		limit = defaultLimit
+	}
+
+	return store.Query{
+		Offset:  offset,
+		Limit:   limit,
+		Filters: map[string][]string{},
+	}
+}
+
+func sendList(ctx context.Context, query store.Query, entities []interface{}, total int) {
+	ctx.SendEntity(&entityList{
+		Offset: query.Offset,
+		Limit:  query.Limit,
+		Count:  len(entities),
+		Total:  total,
+		Items:  entities,
+	})
+}
+
+func sendStoreError(ctx context.Context, r *resource, id string, err error) {
+	if err == ErrNotFound {
+		sendError(ctx, ef.New(ctx, "notFound", ef.Params{
+			"entityName": r.EntityName,
+			"id":         id,
+		}))
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"resource": r.Name,
+		"id":       id,
+	}).Error("Management store error: " + err.Error())
+	sendError(ctx, ef.New(ctx, "internalError"))
+}
+
+func sendProblems(ctx context.Context, problems []validation.Problem) {
+	apiErr := *ef.New(ctx, "constraintViolation", ef.Params{
+		"message":          "The entity is invalid.",
+		"developerMessage": problems[0].Location + ": " + problems[0].Message,
+	})
+	apiErr.Details = problems
+	sendError(ctx, &apiErr)
+}
+
+func sendError(ctx context.Context, err *ef.APIError) {
+	ctx.Rs().SetStatusCode(err.Status)
+	ctx.SendEntity(err)
+}
+
+func decodeBody(ctx context.Context, value interface{}) bool {
+	err := json.Unmarshal(ctx.Rq().Body(), value)
+	if err != nil {
+		sendError(ctx, ef.New(ctx, "messageNotReadable"))
+		return false
+	}
+	return true
+}
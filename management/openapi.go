@@ -0,0 +1,116 @@
+package management
+
+import (
+	"net/http"
+
+	"github.com/prizem-io/gateway/config"
+	"github.com/prizem-io/gateway/context"
+	ef "github.com/prizem-io/gateway/errorfactory"
+	"github.com/prizem-io/gateway/openapi"
+	"github.com/prizem-io/gateway/store"
+	"github.com/prizem-io/gateway/validation"
+)
+
+type (
+	importResult struct {
+		Service *config.Service  `json:"service"`
+		Changes *openapi.Changes `json:"changes"`
+	}
+)
+
+// ImportServiceHandler converts the OpenAPI document in the body to a
+// service.  The service given by the "id" parameter, or else the service
+// with the converted name, is re-synced if it exists and otherwise the
+// service is created.  Nothing is written if "dryRun" is true.
+func ImportServiceHandler(ctx context.Context) {
+	r := resourcesByName["services"]
+	id := ctx.Rq().URLParam("id")
+
+	document, err := openapi.Parse(ctx.Rq().Body())
+	if err != nil {
+		sendError(ctx, ef.New(ctx, "messageNotReadable"))
+		return
+	}
+
+	lock.Lock()
+	var existing *config.Service
+	if id != "" {
+		entity, err := r.load(id)
+		if err != nil {
+			lock.Unlock()
+			sendStoreError(ctx, r, id, err)
+			return
+		}
+		existing = entity.(*config.Service)
+	}
+
+	// Errors of the store are told apart from those of the document
+	var findErr error
+	service, changes, err := openapi.Import(document, ctx.Rq().URLParam("name"), existing, func(name string) (*config.Service, error) {
+		existing, findErr = findServiceByName(r, name)
+		return existing, findErr
+	})
+	if findErr != nil {
+		lock.Unlock()
+		sendStoreError(ctx, r, "", findErr)
+		return
+	}
+	if err != nil {
+		lock.Unlock()
+		sendProblems(ctx, []validation.Problem{{
+			Severity: validation.SeverityError,
+			Location: "document",
+			Message:  err.Error(),
+		}})
+		return
+	}
+
+	created := existing == nil
+	if created {
+		setEntityID(service, "")
+	}
+	id = entityID(service)
+	audit(service, _config.Actor, created)
+
+	if ctx.Rq().URLParam("dryRun") == "true" {
+		lock.Unlock()
+		ctx.SendEntity(&importResult{
+			Service: service,
+			Changes: changes,
+		})
+		return
+	}
+
+	problems := validation.ValidateEntity(r.EntityName, service.ServiceUpdate)
+	ok := r.write(ctx, id, service, problems)
+	lock.Unlock()
+	if !ok {
+		return
+	}
+
+	r.reload(id)
+	if created {
+		ctx.Rs().SetStatusCode(http.StatusCreated)
+	}
+
+	ctx.SendEntity(&importResult{
+		Service: service,
+		Changes: changes,
+	})
+}
+
+func findServiceByName(r *resource, name string) (*config.Service, error) {
+	services, _, err := r.query(store.Query{
+		Filters: map[string][]string{"name": {name}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entity := range services {
+		if service := entity.(*config.Service); service.Name == name {
+			return service, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,360 @@
+package management
+
+import (
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/prizem-io/gateway/config"
+	"github.com/prizem-io/gateway/context"
+	ef "github.com/prizem-io/gateway/errorfactory"
+	"github.com/prizem-io/gateway/store"
+	"github.com/prizem-io/gateway/utils"
+	"github.com/prizem-io/gateway/validation"
+)
+
+type (
+	// consumerDeveloper associates a developer with a consumer.
+	consumerDeveloper struct {
+		ConsumerID  string `json:"consumerId"`
+		DeveloperID string `json:"developerId"`
+	}
+
+	passwordHash struct {
+		Hash string `json:"hash"`
+	}
+)
+
+const (
+	consumerDevelopers = "consumerDevelopers"
+	passwords          = "passwords"
+)
+
+var (
+	// linkFields maps the resources that links refer to to the field of
+	// the links that holds their IDs.
+	linkFields = map[string]string{
+		"consumers":  "consumerId",
+		"developers": "developerId",
+	}
+
+	dummyHash     []byte
+	dummyHashOnce sync.Once
+)
+
+// dummyPasswordHash returns the hash of a random password, made with the
+// cost of stored passwords.
+func dummyPasswordHash() []byte {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = bcrypt.GenerateFromPassword([]byte(utils.RandomString(32)), bcrypt.DefaultCost)
+	})
+	return dummyHash
+}
+
+func ConsumerClientsHandler(ctx context.Context) {
+	consumerID := ctx.Rq().Param("id")
+	if !exists(ctx, "consumers", consumerID) {
+		return
+	}
+
+	clients := resourcesByName["clients"]
+	query := listQuery(ctx)
+	query.Filters["consumerId"] = []string{consumerID}
+	entities, total, err := clients.query(query)
+	if err != nil {
+		sendStoreError(ctx, clients, "", err)
+		return
+	}
+
+	sendList(ctx, query, entities, total)
+}
+
+func ConsumerDevelopersHandler(ctx context.Context) {
+	sendLinked(ctx, "consumers", "consumerId", "developers", func(link *consumerDeveloper) (string, string) {
+		return link.ConsumerID, link.DeveloperID
+	})
+}
+
+func DeveloperConsumersHandler(ctx context.Context) {
+	sendLinked(ctx, "developers", "developerId", "consumers", func(link *consumerDeveloper) (string, string) {
+		return link.DeveloperID, link.ConsumerID
+	})
+}
+
+func ConsumerDeveloperHandler(ctx context.Context) {
+	consumerID, developerID := ctx.Rq().Param("id"), ctx.Rq().Param("developerId")
+	sendLink(ctx, consumerID, developerID, "developers", developerID)
+}
+
+func DeveloperConsumerHandler(ctx context.Context) {
+	developerID, consumerID := ctx.Rq().Param("id"), ctx.Rq().Param("consumerId")
+	sendLink(ctx, consumerID, developerID, "consumers", consumerID)
+}
+
+func AddConsumerDeveloperHandler(ctx context.Context) {
+	consumerID, developerID := ctx.Rq().Param("id"), ctx.Rq().Param("developerId")
+	addLink(ctx, consumerID, developerID, "developers", developerID)
+}
+
+func AddDeveloperConsumerHandler(ctx context.Context) {
+	developerID, consumerID := ctx.Rq().Param("id"), ctx.Rq().Param("consumerId")
+	addLink(ctx, consumerID, developerID, "consumers", consumerID)
+}
+
+func RemoveConsumerDeveloperHandler(ctx context.Context) {
+	removeLink(ctx, ctx.Rq().Param("id"), ctx.Rq().Param("developerId"))
+}
+
+func RemoveDeveloperConsumerHandler(ctx context.Context) {
+	removeLink(ctx, ctx.Rq().Param("consumerId"), ctx.Rq().Param("id"))
+}
+
+// passwordHandler sets the password of an entity in the named resource.
+// Only a bcrypt hash of the password is stored.
+func passwordHandler(resourceName string) func(context.Context) {
+	return func(ctx context.Context) {
+		id := ctx.Rq().Param("id")
+		if !exists(ctx, resourceName, id) {
+			return
+		}
+
+		var setPassword config.SetPassword
+		if !decodeBody(ctx, &setPassword) {
+			return
+		}
+		if problems := validation.ValidateEntity("password", setPassword); len(problems) > 0 {
+			sendProblems(ctx, problems)
+			return
+		}
+
+		hash, err := bcrypt.GenerateFromPassword([]byte(setPassword.Password), bcrypt.DefaultCost)
+		if err != nil {
+			sendError(ctx, ef.New(ctx, "internalError"))
+			return
+		}
+
+		err = _store.Save(passwords, resourceName+"|"+id, &passwordHash{Hash: string(hash)})
+		if err != nil {
+			sendStoreError(ctx, resourcesByName[resourceName], id, err)
+			return
+		}
+
+		ctx.SendEntity(&config.Result{Result: "success"})
+	}
+}
+
+// AuthenticateDeveloperHandler returns the developer
+// that matches the supplied username and password.
+func AuthenticateDeveloperHandler(ctx context.Context) {
+	var authentication config.Authentication
+	if !decodeBody(ctx, &authentication) {
+		return
+	}
+
+	developers := resourcesByName["developers"]
+	entities, _, err := developers.query(store.Query{
+		Filters: map[string][]string{"username": {authentication.Username}},
+	})
+	if err != nil {
+		sendStoreError(ctx, developers, "", err)
+		return
+	}
+
+	// Unknown usernames are compared against a dummy hash so that they
+	// take as long to reject as wrong passwords
+	var match *config.Developer
+	hash := dummyPasswordHash()
+	for _, entity := range entities {
+		developer := entity.(*config.Developer)
+		if !strings.EqualFold(developer.Username, authentication.Username) {
+			continue
+		}
+
+		var password passwordHash
+		if _store.Load(passwords, "developers|"+developer.ID, &password) == nil {
+			match = developer
+			hash = []byte(password.Hash)
+		}
+		break
+	}
+
+	if bcrypt.CompareHashAndPassword(hash, []byte(authentication.Password)) == nil && match != nil {
+		ctx.SendEntity(match)
+		return
+	}
+
+	sendError(ctx, ef.New(ctx, "invalidCredentials"))
+}
+
+// PermissionsHandler describes the permissions of the caller.
+// The admin token grants full access.
+func PermissionsHandler(ctx context.Context) {
+	ctx.SendEntity(&config.UserPermissions{
+		Name:          _config.Actor,
+		Administrator: true,
+		Permissions:   []string{},
+		AccessLevels:  map[string]string{},
+	})
+}
+
+func SummaryHandler(ctx context.Context) {
+	summary := config.Summary{
+		Name: _config.Name,
+	}
+
+	counts := map[string]*int64{
+		"consumers":  &summary.Consumers,
+		"developers": &summary.Developers,
+		"services":   &summary.Services,
+		"plans":      &summary.Plans,
+		"providers":  &summary.Providers,
+		"users":      &summary.Users,
+		"roles":      &summary.Roles,
+	}
+	for name, count := range counts {
+		r := resourcesByName[name]
+		_, total, err := r.query(store.Query{Limit: 1})
+		if err != nil {
+			sendStoreError(ctx, r, "", err)
+			return
+		}
+		*count = int64(total)
+	}
+
+	ctx.SendEntity(&summary)
+}
+
+// exists sends a not found response and returns false if the
+// entity with id does not exist in the named resource.
+func exists(ctx context.Context, resourceName, id string) bool {
+	r := resourcesByName[resourceName]
+	_, err := r.load(id)
+	if err != nil {
+		sendStoreError(ctx, r, id, err)
+		return false
+	}
+	return true
+}
+
+// sendLinked sends the entities of toName that are linked to the entity
+// of fromName whose ID is in field of the links.
+func sendLinked(ctx context.Context, fromName, field, toName string, ids func(*consumerDeveloper) (string, string)) {
+	id := ctx.Rq().Param("id")
+	if !exists(ctx, fromName, id) {
+		return
+	}
+
+	query := listQuery(ctx)
+	query.Filters[field] = []string{id}
+	links, total, err := _store.List(consumerDevelopers, query, func() interface{} {
+		return &consumerDeveloper{}
+	})
+	if err != nil {
+		sendStoreError(ctx, resourcesByName[fromName], id, err)
+		return
+	}
+
+	to := resourcesByName[toName]
+	entities := []interface{}{}
+	for _, link := range links {
+		fromID, toID := ids(link.(*consumerDeveloper))
+		if fromID != id {
+			continue
+		}
+
+		entity, err := to.load(toID)
+		if err == ErrNotFound {
+			// Links that were left when the entity was deleted
+			continue
+		}
+		if err != nil {
+			sendStoreError(ctx, to, toID, err)
+			return
+		}
+		entities = append(entities, entity)
+	}
+
+	sendList(ctx, query, entities, total)
+}
+
+// removeLinks deletes the links of the entity of r whose ID is id, so that
+// the linked lists do not count entities that no longer exist.
+func removeLinks(r *resource, id string) error {
+	field, ok := linkFields[r.Name]
+	if !ok {
+		return nil
+	}
+
+	links, _, err := _store.List(consumerDevelopers, store.Query{
+		Filters: map[string][]string{field: {id}},
+	}, func() interface{} {
+		return &consumerDeveloper{}
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, l := range links {
+		link := l.(*consumerDeveloper)
+		linkedID := link.ConsumerID
+		if r.Name == "developers" {
+			linkedID = link.DeveloperID
+		}
+		// Filters match case-insensitively
+		if linkedID != id {
+			continue
+		}
+		err = _store.Delete(consumerDevelopers, link.ConsumerID+"|"+link.DeveloperID)
+		if err != nil && err != ErrNotFound {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func sendLink(ctx context.Context, consumerID, developerID, resourceName, id string) {
+	var link consumerDeveloper
+	err := _store.Load(consumerDevelopers, consumerID+"|"+developerID, &link)
+	if err != nil {
+		sendStoreError(ctx, resourcesByName[resourceName], id, err)
+		return
+	}
+
+	r := resourcesByName[resourceName]
+	entity, err := r.load(id)
+	if err != nil {
+		sendStoreError(ctx, r, id, err)
+		return
+	}
+
+	ctx.SendEntity(entity)
+}
+
+func addLink(ctx context.Context, consumerID, developerID, resourceName, id string) {
+	if !exists(ctx, "consumers", consumerID) || !exists(ctx, "developers", developerID) {
+		return
+	}
+
+	err := _store.Save(consumerDevelopers, consumerID+"|"+developerID, &consumerDeveloper{
+		ConsumerID:  consumerID,
+		DeveloperID: developerID,
+	})
+	if err != nil {
+		sendStoreError(ctx, resourcesByName[resourceName], id, err)
+		return
+	}
+
+	sendLink(ctx, consumerID, developerID, resourceName, id)
+}
+
+func removeLink(ctx context.Context, consumerID, developerID string) {
+	err := _store.Delete(consumerDevelopers, consumerID+"|"+developerID)
+	if err != nil {
+		sendStoreError(ctx, resourcesByName["developers"], developerID, err)
+		return
+	}
+
+	ctx.SendEntity(&config.Result{Result: "success"})
+}
@@ -0,0 +1,204 @@
+package management
+
+import (
+	"reflect"
+
+	"github.com/prizem-io/gateway/config"
+	"github.com/prizem-io/gateway/store"
+)
+
+type (
+	// LiveStore is implemented by stores that the gateway reads from
+	// directly.  Collections that it serves are neither synchronized with
+	// the gateway configuration nor reloaded when written.
+	LiveStore interface {
+		Serves(collection string) bool
+	}
+
+	// repositoryStore keeps the collections that a store.Store has
+	// repositories for in that store and all others in fallback.
+	repositoryStore struct {
+		collections map[string]*repositoryCollection
+		live        map[string]bool
+		fallback    Store
+	}
+
+	repositoryCollection struct {
+		list   func(query store.Query) ([]interface{}, int, error)
+		load   func(id string) (interface{}, error)
+		save   func(entity interface{}) error
+		delete func(id string) error
+	}
+)
+
+// NewRepositoryStore returns a Store that writes consumers, permissions,
+// plans, plugins and services to s.  The first four are served to the
+// gateway by store.NewDataAccessor and are therefore live.
+func NewRepositoryStore(s store.Store, fallback Store) Store {
+	consumers, permissions, plans, plugins, services :=
+		s.Consumers(), s.Permissions(), s.Plans(), s.Plugins(), s.Services()
+
+	return &repositoryStore{
+		fallback: fallback,
+		live: map[string]bool{
+			"consumers":   true,
+			"permissions": true,
+			"plans":       true,
+			"plugins":     true,
+		},
+		collections: map[string]*repositoryCollection{
+			"consumers": {
+				list: func(query store.Query) ([]interface{}, int, error) {
+					list, total, err := consumers.ListConsumers(query)
+					entities := make([]interface{}, len(list))
+					for i, entity := range list {
+						entities[i] = entity
+					}
+					return entities, total, err
+				},
+				load: func(id string) (interface{}, error) {
+					return consumers.GetConsumer(id)
+				},
+				save: func(entity interface{}) error {
+					return consumers.SaveConsumer(entity.(*config.Consumer))
+				},
+				delete: consumers.DeleteConsumer,
+			},
+			"permissions": {
+				list: func(query store.Query) ([]interface{}, int, error) {
+					list, total, err := permissions.ListPermissions(query)
+					entities := make([]interface{}, len(list))
+					for i, entity := range list {
+						entities[i] = entity
+					}
+					return entities, total, err
+				},
+				load: func(id string) (interface{}, error) {
+					return permissions.GetPermission(id)
+				},
+				save: func(entity interface{}) error {
+					return permissions.SavePermission(entity.(*config.Permission))
+				},
+				delete: permissions.DeletePermission,
+			},
+			"plans": {
+				list: func(query store.Query) ([]interface{}, int, error) {
+					list, total, err := plans.ListPlans(query)
+					entities := make([]interface{}, len(list))
+					for i, entity := range list {
+						entities[i] = entity
+					}
+					return entities, total, err
+				},
+				load: func(id string) (interface{}, error) {
+					return plans.GetPlan(id)
+				},
+				save: func(entity interface{}) error {
+					return plans.SavePlan(entity.(*config.Plan))
+				},
+				delete: plans.DeletePlan,
+			},
+			"plugins": {
+				list: func(query store.Query) ([]interface{}, int, error) {
+					list, total, err := plugins.ListPlugins(query)
+					entities := make([]interface{}, len(list))
+					for i, entity := range list {
+						entities[i] = entity
+					}
+					return entities, total, err
+				},
+				load: func(id string) (interface{}, error) {
+					return plugins.GetPlugin(id)
+				},
+				save: func(entity interface{}) error {
+					return plugins.SavePlugin(entity.(*config.Plugin))
+				},
+				delete: plugins.DeletePlugin,
+			},
+			"services": {
+				list: func(query store.Query) ([]interface{}, int, error) {
+					list, total, err := services.ListServices(query)
+					entities := make([]interface{}, len(list))
+					for i, entity := range list {
+						entities[i] = entity
+					}
+					return entities, total, err
+				},
+				load: func(id string) (interface{}, error) {
+					return services.GetService(id)
+				},
+				save: func(entity interface{}) error {
+					return services.SaveService(entity.(*config.Service))
+				},
+				delete: services.DeleteService,
+			},
+		},
+	}
+}
+
+func (s *repositoryStore) Serves(collection string) bool {
+	return s.live[collection]
+}
+
+func (s *repositoryStore) List(collection string, query store.Query, newEntity func() interface{}) ([]interface{}, int, error) {
+	c, ok := s.collections[collection]
+	if !ok {
+		return s.fallback.List(collection, query, newEntity)
+	}
+
+	entities, total, err := c.list(query)
+	if err != nil {
+		return nil, 0, repositoryError(err)
+	}
+	return entities, total, nil
+}
+
+func (s *repositoryStore) Load(collection, id string, entity interface{}) error {
+	c, ok := s.collections[collection]
+	if !ok {
+		return s.fallback.Load(collection, id, entity)
+	}
+
+	loaded, err := c.load(id)
+	if err != nil {
+		return repositoryError(err)
+	}
+	reflect.ValueOf(entity).Elem().Set(reflect.ValueOf(loaded).Elem())
+
+	return nil
+}
+
+func (s *repositoryStore) Save(collection, id string, entity interface{}) error {
+	c, ok := s.collections[collection]
+	if !ok {
+		return s.fallback.Save(collection, id, entity)
+	}
+
+	// Repositories key entities by their ID, which entities
+	// mirrored from the gateway configuration may not have
+	setEntityID(entity, id)
+
+	return repositoryError(c.save(entity))
+}
+
+func (s *repositoryStore) Delete(collection, id string) error {
+	c, ok := s.collections[collection]
+	if !ok {
+		return s.fallback.Delete(collection, id)
+	}
+
+	return repositoryError(c.delete(id))
+}
+
+func repositoryError(err error) error {
+	if err == store.ErrNotFound {
+		return ErrNotFound
+	}
+	return err
+}
+
+// live reports whether the gateway reads r directly from the store.
+func (r *resource) live() bool {
+	live, ok := _store.(LiveStore)
+	return ok && live.Serves(r.Name)
+}
@@ -0,0 +1,192 @@
+package management
+
+import (
+	"encoding/json"
+	"reflect"
+	"time"
+
+	"github.com/satori/go.uuid"
+
+	"github.com/prizem-io/gateway/config"
+	"github.com/prizem-io/gateway/store"
+	"github.com/prizem-io/gateway/validation"
+)
+
+type (
+	// resource describes a collection served by the management API.
+	resource struct {
+		// Name is the path segment and store collection name.
+		Name string
+		// EntityName is used in error messages and problem locations.
+		EntityName string
+		entityType reflect.Type
+		// updateType is the embedded struct that clients may write.
+		// The whole entity is written when it is nil.
+		updateType reflect.Type
+		// configField is the GatewayConfig field that this collection
+		// is synchronized with, if any.
+		configField string
+	}
+)
+
+var (
+	auditableType = reflect.TypeOf(config.Auditable{})
+	entityType    = reflect.TypeOf(config.Entity{})
+
+	resources = []*resource{
+		newResource("consumers", "consumer", config.Consumer{}, config.ConsumerUpdate{}, "Consumers"),
+		newResource("clients", "client", config.Client{}, config.ClientUpdate{}, ""),
+		newResource("developers", "developer", config.Developer{}, config.DeveloperUpdate{}, ""),
+		newResource("logEntries", "log entry", config.LogEntry{}, config.LogEntryUpdate{}, ""),
+		newResource("messages", "message", config.Message{}, config.MessageUpdate{}, ""),
+		newResource("permissions", "permission", config.Permission{}, config.PermissionUpdate{}, "Permissions"),
+		newResource("plans", "plan", config.Plan{}, config.PlanUpdate{}, "Plans"),
+		newResource("plugins", "plugin", config.Plugin{}, config.PluginConfig{}, "Plugins"),
+		newResource("principalClaims", "principal claims", config.PrincipalClaims{}, config.PrincipalClaimsUpdate{}, ""),
+		newResource("principalProfiles", "principal profile", config.PrincipalProfile{}, config.PrincipalProfileUpdate{}, ""),
+		newResource("providers", "provider", config.Provider{}, config.ProviderUpdate{}, ""),
+		newResource("roles", "role", config.Role{}, config.RoleUpdate{}, ""),
+		newResource("services", "service", config.Service{}, config.ServiceUpdate{}, "Services"),
+		newResource("tokens", "token", config.Token{}, nil, ""),
+		newResource("users", "user", config.User{}, config.UserUpdate{}, ""),
+	}
+
+	resourcesByName = map[string]*resource{}
+)
+
+func init() {
+	for _, r := range resources {
+		resourcesByName[r.Name] = r
+	}
+}
+
+func newResource(name, entityName string, entity, update interface{}, configField string) *resource {
+	r := resource{
+		Name:        name,
+		EntityName:  entityName,
+		entityType:  reflect.TypeOf(entity),
+		configField: configField,
+	}
+	if update != nil {
+		r.updateType = reflect.TypeOf(update)
+	}
+	return &r
+}
+
+func (r *resource) newEntity() interface{} {
+	return reflect.New(r.entityType).Interface()
+}
+
+// list returns every entity of r.
+func (r *resource) list() ([]interface{}, error) {
+	entities, _, err := r.query(store.Query{})
+	return entities, err
+}
+
+// query returns the entities of r that q selects and the number that
+// match its filters.
+func (r *resource) query(q store.Query) ([]interface{}, int, error) {
+	return _store.List(r.Name, q, r.newEntity)
+}
+
+func (r *resource) load(id string) (interface{}, error) {
+	entity := r.newEntity()
+	err := _store.Load(r.Name, id, entity)
+	if err != nil {
+		return nil, err
+	}
+	return entity, nil
+}
+
+// decode builds a new entity from body.  When existing is provided, only
+// the fields of the update type are replaced.  The returned problems
+// list any required fields that are missing.
+func (r *resource) decode(body []byte, existing interface{}) (interface{}, []validation.Problem, error) {
+	entity := reflect.New(r.entityType)
+	if existing != nil {
+		entity.Elem().Set(reflect.ValueOf(existing).Elem())
+	}
+
+	if r.updateType == nil {
+		id := entityID(entity.Interface())
+		err := json.Unmarshal(body, entity.Interface())
+		if err != nil {
+			return nil, nil, err
+		}
+		if existing != nil || entityID(entity.Interface()) == "" {
+			setEntityID(entity.Interface(), id)
+		}
+		return entity.Interface(), nil, nil
+	}
+
+	update := reflect.New(r.updateType)
+	err := json.Unmarshal(body, update.Interface())
+	if err != nil {
+		return nil, nil, err
+	}
+	problems := validation.ValidateEntity(r.EntityName, update.Elem().Interface())
+
+	if field, ok := embeddedField(entity.Elem(), r.updateType); ok {
+		field.Set(update.Elem())
+	}
+
+	// Clients may choose the identifier of new entities
+	if existing == nil {
+		var requested config.Entity
+		json.Unmarshal(body, &requested)
+		setEntityID(entity.Interface(), requested.ID)
+	}
+
+	return entity.Interface(), problems, nil
+}
+
+// entityID returns the ID of entity.  Services defined in the gateway
+// configuration commonly omit their ID so the name is used instead.
+func entityID(entity interface{}) string {
+	value := reflect.ValueOf(entity).Elem()
+	if field, ok := embeddedField(value, entityType); ok {
+		if id := field.Interface().(config.Entity).ID; id != "" {
+			return id
+		}
+	}
+	if name := value.FieldByName("Name"); name.IsValid() && name.Kind() == reflect.String {
+		return name.String()
+	}
+	return ""
+}
+
+func setEntityID(entity interface{}, id string) {
+	if id == "" {
+		id = uuid.NewV4().String()
+	}
+	if field, ok := embeddedField(reflect.ValueOf(entity).Elem(), entityType); ok {
+		field.Set(reflect.ValueOf(config.Entity{ID: id}))
+	}
+}
+
+// audit records actor as the last modifier of entity and,
+// if created is set, its creator.
+func audit(entity interface{}, actor string, created bool) {
+	field, ok := embeddedField(reflect.ValueOf(entity).Elem(), auditableType)
+	if !ok {
+		return
+	}
+
+	auditable := field.Addr().Interface().(*config.Auditable)
+	now := time.Now().UTC()
+	if created {
+		auditable.CreatedBy = actor
+		auditable.CreatedDate = now
+	}
+	auditable.ModifiedBy = actor
+	auditable.ModifiedDate = now
+}
+
+func embeddedField(value reflect.Value, fieldType reflect.Type) (reflect.Value, bool) {
+	for i := 0; i < value.NumField(); i++ {
+		if value.Type().Field(i).Anonymous && value.Field(i).Type() == fieldType {
+			return value.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
@@ -0,0 +1,119 @@
+package management
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/prizem-io/gateway/store"
+)
+
+type (
+	// Store persists the entities managed through the management API.
+	// Entities are grouped into collections named after their resource.
+	Store interface {
+		// List returns the entities in collection that query selects
+		// and the number that match its filters.  newEntity returns a
+		// pointer to a new entity to decode into.
+		List(collection string, query store.Query, newEntity func() interface{}) ([]interface{}, int, error)
+		// Load decodes the entity with id into entity.  ErrNotFound
+		// is returned if it does not exist.
+		Load(collection, id string, entity interface{}) error
+		Save(collection, id string, entity interface{}) error
+		Delete(collection, id string) error
+	}
+
+	// memoryStore keeps entities in memory as JSON so that callers
+	// never share values with the store.
+	memoryStore struct {
+		mu          sync.RWMutex
+		collections map[string]map[string][]byte
+	}
+)
+
+var (
+	ErrNotFound = errors.New("Entity not found")
+)
+
+// NewMemoryStore returns a Store that does not outlive the process.
+func NewMemoryStore() Store {
+	return &memoryStore{
+		collections: map[string]map[string][]byte{},
+	}
+}
+
+func (s *memoryStore) List(collection string, query store.Query, newEntity func() interface{}) ([]interface{}, int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entities := s.collections[collection]
+	ids := make([]string, 0, len(entities))
+	for id := range entities {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	list := []interface{}{}
+	total := 0
+	for _, id := range ids {
+		entity := newEntity()
+		err := json.Unmarshal(entities[id], entity)
+		if err != nil {
+			return nil, 0, err
+		}
+		if !store.Matches(entity, query.Filters) {
+			continue
+		}
+		if query.Selects(total) {
+			list = append(list, entity)
+		}
+		total++
+	}
+
+	return list, total, nil
+}
+
+func (s *memoryStore) Load(collection, id string, entity interface{}) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	data, ok := s.collections[collection][id]
+	if !ok {
+		return ErrNotFound
+	}
+
+	return json.Unmarshal(data, entity)
+}
+
+func (s *memoryStore) Save(collection, id string, entity interface{}) error {
+	data, err := json.Marshal(entity)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entities, ok := s.collections[collection]
+	if !ok {
+		entities = map[string][]byte{}
+		s.collections[collection] = entities
+	}
+	entities[id] = data
+
+	return nil
+}
+
+func (s *memoryStore) Delete(collection, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entities := s.collections[collection]
+	if _, ok := entities[id]; !ok {
+		return ErrNotFound
+	}
+	delete(entities, id)
+
+	return nil
+}
@@ -0,0 +1,178 @@
+package management
+
+import (
+	"reflect"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/prizem-io/gateway/server"
+	"github.com/prizem-io/gateway/validation"
+)
+
+type (
+	// managedCollection marks a collection that has been written through
+	// the management API and is therefore served from the store.
+	managedCollection struct {
+		Since time.Time `json:"since"`
+	}
+)
+
+const managedCollections = "_managed"
+
+var (
+	// current is the last gateway configuration produced by
+	// SyncGatewayConfig.  Writes are validated against it.
+	current *server.GatewayConfig
+)
+
+// SyncGatewayConfig is a server.GatewayConfigProcessor that reconciles the
+// gateway configuration with the store.  Collections that have been written
+// through the management API replace their counterparts in gatewayConfig,
+// so later edits of those collections in the configuration source are
+// ignored; a warning is logged on each load that this happens.  All other
+// collections are mirrored into the store so that they can be read through
+// the API, and continue to follow the configuration source.
+// Collections that the gateway reads from a LiveStore are left untouched.
+func SyncGatewayConfig(gatewayConfig *server.GatewayConfig) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	for _, r := range resources {
+		if r.configField == "" || r.live() {
+			continue
+		}
+
+		field := reflect.ValueOf(gatewayConfig).Elem().FieldByName(r.configField)
+		marker, managed, err := loadManaged(r)
+		if err != nil {
+			return err
+		}
+
+		if managed {
+			if field.Len() > 0 {
+				log.WithFields(log.Fields{
+					"collection": r.Name,
+					"since":      marker.Since,
+				}).Warnf("The %s of the gateway configuration are ignored because they are managed through the management API", r.Name)
+			}
+
+			entities, err := r.list()
+			if err != nil {
+				return err
+			}
+			field.Set(entitySlice(field.Type(), entities, "", nil))
+			continue
+		}
+
+		err = mirror(r, field)
+		if err != nil {
+			return err
+		}
+	}
+
+	copied := *gatewayConfig
+	current = &copied
+
+	return nil
+}
+
+func mirror(r *resource, field reflect.Value) error {
+	stored, err := r.list()
+	if err != nil {
+		return err
+	}
+
+	ids := make(map[string]bool, field.Len())
+	for i := 0; i < field.Len(); i++ {
+		entity := field.Index(i).Addr().Interface()
+		id := entityID(entity)
+		ids[id] = true
+
+		err := _store.Save(r.Name, id, entity)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, entity := range stored {
+		id := entityID(entity)
+		if !ids[id] {
+			err := _store.Delete(r.Name, id)
+			if err == nil {
+				err = removeLinks(r, id)
+			}
+			if err != nil && err != ErrNotFound {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// loadManaged returns the marker of r and whether r is managed.
+func loadManaged(r *resource) (*managedCollection, bool, error) {
+	var marker managedCollection
+	err := _store.Load(managedCollections, r.Name, &marker)
+	if err == ErrNotFound {
+		return nil, false, nil
+	}
+	return &marker, err == nil, err
+}
+
+func markManaged(r *resource) error {
+	if _, managed, err := loadManaged(r); managed || err != nil {
+		return err
+	}
+	return _store.Save(managedCollections, r.Name, &managedCollection{
+		Since: time.Now().UTC(),
+	})
+}
+
+// checkGatewayConfig reports the problems that replacing the entity with id
+// by entity, or removing it if entity is nil, would introduce into the
+// current gateway configuration.
+func checkGatewayConfig(r *resource, id string, entity interface{}) []validation.Problem {
+	if r.configField == "" || r.live() || current == nil {
+		return nil
+	}
+
+	candidate := *current
+	field := reflect.ValueOf(&candidate).Elem().FieldByName(r.configField)
+	entities := make([]interface{}, field.Len())
+	for i := range entities {
+		entities[i] = field.Index(i).Addr().Interface()
+	}
+	field.Set(entitySlice(field.Type(), entities, id, entity))
+
+	existing := map[validation.Problem]bool{}
+	for _, problem := range validation.ValidateGatewayConfig(current) {
+		existing[problem] = true
+	}
+
+	problems := []validation.Problem{}
+	for _, problem := range validation.ValidateGatewayConfig(&candidate) {
+		if !existing[problem] {
+			problems = append(problems, problem)
+		}
+	}
+
+	return problems
+}
+
+// entitySlice builds a slice of sliceType from entity pointers, replacing
+// or appending the entity with id when one is given.
+func entitySlice(sliceType reflect.Type, entities []interface{}, id string, replacement interface{}) reflect.Value {
+	slice := reflect.MakeSlice(sliceType, 0, len(entities)+1)
+	for _, entity := range entities {
+		if id != "" && entityID(entity) == id {
+			continue
+		}
+		slice = reflect.Append(slice, reflect.ValueOf(entity).Elem())
+	}
+	if replacement != nil {
+		slice = reflect.Append(slice, reflect.ValueOf(replacement).Elem())
+	}
+	return slice
+}
@@ -45,7 +45,16 @@ func (d *CredentialDecoder) Type() string {
 
 func (d *CredentialDecoder) DecodeCredential(input map[string]interface{}) (interface{}, string, error) {
 	var credential OAuth2Credential
-	err := mapstructure.Decode(input, &credential)
+	// Interpolated values, such as enabled: ${FLAG}, are strings
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		WeaklyTypedInput: true,
+		Result:           &credential,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	err = decoder.Decode(input)
 	if err != nil {
 		return nil, "", err
 	}
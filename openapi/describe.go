@@ -0,0 +1,191 @@
+package openapi
+
+import (
+	"strings"
+
+	"github.com/prizem-io/gateway/authentication"
+	"github.com/prizem-io/gateway/config"
+)
+
+type (
+	// Specification is an OpenAPI 3 document describing the operations
+	// that the gateway exposes.
+	Specification struct {
+		OpenAPI    string                               `json:"openapi"`
+		Info       SpecificationInfo                    `json:"info"`
+		Servers    []SpecificationServer                `json:"servers,omitempty"`
+		Paths      map[string]map[string]*PathOperation `json:"paths"`
+		Components Components                           `json:"components"`
+	}
+
+	SpecificationInfo struct {
+		Title   string `json:"title"`
+		Version string `json:"version"`
+	}
+
+	SpecificationServer struct {
+		URL string `json:"url"`
+	}
+
+	PathOperation struct {
+		OperationID string                `json:"operationId"`
+		Summary     string                `json:"summary,omitempty"`
+		Tags        []string              `json:"tags,omitempty"`
+		Parameters  []Parameter           `json:"parameters,omitempty"`
+		Responses   map[string]Response   `json:"responses"`
+		Security    []map[string][]string `json:"security"`
+	}
+
+	Parameter struct {
+		Name     string            `json:"name"`
+		In       string            `json:"in"`
+		Required bool              `json:"required"`
+		Schema   map[string]string `json:"schema"`
+	}
+
+	Response struct {
+		Description string `json:"description"`
+	}
+
+	Components struct {
+		SecuritySchemes map[string]map[string]interface{} `json:"securitySchemes"`
+	}
+
+	// OperationFilter reports whether an operation should be described.
+	OperationFilter func(service *config.Service, operation *config.Operation) bool
+)
+
+// Describe builds an OpenAPI 3 document of the operations of services, as
+// exposed by the gateway, that include accepts.  Operations of services that
+// require authentication may be called with a credential accepted by any of
+// the registered authenticators.  Operations whose path ends in a catch-all
+// parameter are left out, as OpenAPI path parameters cannot span segments.
+func Describe(info SpecificationInfo, services []config.Service, include OperationFilter) *Specification {
+	specification := &Specification{
+		OpenAPI: "3.0.0",
+		Info:    info,
+		Paths:   map[string]map[string]*PathOperation{},
+		Components: Components{
+			SecuritySchemes: map[string]map[string]interface{}{},
+		},
+	}
+
+	authenticated := map[string][]string{}
+	for _, authenticator := range authentication.Authenticators() {
+		scheme := map[string]interface{}{
+			"type":   "http",
+			"scheme": "bearer",
+		}
+		if schemer, ok := authenticator.(authentication.SecuritySchemer); ok {
+			scheme = schemer.SecurityScheme()
+		}
+		specification.Components.SecuritySchemes[authenticator.Name()] = scheme
+		authenticated[authenticator.Name()] = []string{}
+	}
+
+	operationIDs := map[string]bool{}
+	for i := range services {
+		service := &services[i]
+
+		security := []map[string][]string{}
+		if service.AuthenticationType != config.AuthenticationTypeNone {
+			security = serviceSecurity(service, authenticated)
+		}
+
+		for j := range service.Operations {
+			operation := &service.Operations[j]
+			if include != nil && !include(service, operation) {
+				continue
+			}
+
+			path, parameters, ok := specificationPath(service, operation)
+			if !ok {
+				continue
+			}
+			methods, ok := specification.Paths[path]
+			if !ok {
+				methods = map[string]*PathOperation{}
+				specification.Paths[path] = methods
+			}
+
+			// Operation names are only unique within their service
+			operationID := operation.Name
+			if operationIDs[operationID] {
+				operationID = service.Name + upperFirst(operation.Name)
+			}
+			operationIDs[operationID] = true
+
+			tags := operation.Tags
+			if len(tags) == 0 {
+				tags = service.Tags
+			}
+			if len(tags) == 0 {
+				tags = []string{service.Name}
+			}
+
+			methods[strings.ToLower(operation.Method.String())] = &PathOperation{
+				OperationID: operationID,
+				Summary:     service.Name + "::" + operation.Name,
+				Tags:        tags,
+				Parameters:  parameters,
+				Responses: map[string]Response{
+					"default": {Description: "Response from the " + service.Name + " service"},
+				},
+				Security: security,
+			}
+		}
+	}
+
+	return specification
+}
+
+// serviceSecurity lists the security schemes of the authenticators of
+// service, or of all authenticators if the service does not name any.
+func serviceSecurity(service *config.Service, authenticated map[string][]string) []map[string][]string {
+	security := []map[string][]string{}
+	if len(service.Authenticators) > 0 {
+		for _, authenticator := range service.Authenticators {
+			if scopes, ok := authenticated[authenticator.Name]; ok {
+				security = append(security, map[string][]string{authenticator.Name: scopes})
+			}
+		}
+		return security
+	}
+
+	for _, authenticator := range authentication.Authenticators() {
+		name := authenticator.Name()
+		security = append(security, map[string][]string{name: authenticated[name]})
+	}
+	return security
+}
+
+// specificationPath converts the path that the gateway exposes for operation
+// to a templated OpenAPI path and lists its parameters.  It returns false if
+// the path has a catch-all parameter, which matches the rest of the path
+// including slashes and so cannot be described.
+func specificationPath(service *config.Service, operation *config.Operation) (string, []Parameter, bool) {
+	path := operation.URIPattern
+	if service.URIPrefix != nil {
+		path = *service.URIPrefix + path
+	}
+
+	parameters := []Parameter{}
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if len(segment) > 0 && segment[0] == '*' {
+			return "", nil, false
+		}
+		if len(segment) > 1 && segment[0] == ':' {
+			name := segment[1:]
+			segments[i] = "{" + name + "}"
+			parameters = append(parameters, Parameter{
+				Name:     name,
+				In:       "path",
+				Required: true,
+				Schema:   map[string]string{"type": "string"},
+			})
+		}
+	}
+
+	return strings.Join(segments, "/"), parameters, true
+}
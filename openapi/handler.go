@@ -0,0 +1,143 @@
+package openapi
+
+import (
+	"strings"
+
+	"github.com/prizem-io/gateway/authentication"
+	"github.com/prizem-io/gateway/authorization"
+	"github.com/prizem-io/gateway/config"
+	"github.com/prizem-io/gateway/context"
+	ef "github.com/prizem-io/gateway/errorfactory"
+	"github.com/prizem-io/gateway/server"
+)
+
+type (
+	openAPIConfig struct {
+		Enabled bool   `mapstructure:"enabled"`
+		Path    string `mapstructure:"path"`
+		Title   string `mapstructure:"title"`
+		Version string `mapstructure:"version"`
+		// ServerURL is the public URL of the gateway, if known.
+		ServerURL string `mapstructure:"serverUrl"`
+		// FilterByConsumer only describes the operations that the calling
+		// consumer, identified by its credential, is allowed to call.
+		FilterByConsumer bool `mapstructure:"filterByConsumer"`
+	}
+)
+
+var (
+	_config = openAPIConfig{
+		Path:    "/openapi.json",
+		Title:   "API Gateway",
+		Version: "1.0",
+	}
+
+	// anonymousService stands in for the requested service while
+	// the caller of the specification route is authenticated.
+	anonymousService = config.Service{
+		ServiceUpdate: config.ServiceUpdate{
+			AuthenticationType: config.AuthenticationTypeNone,
+		},
+	}
+)
+
+func Initialize(configuration config.Configuration) error {
+	return configuration.UnmarshalKey("openapi", &_config)
+}
+
+func Enabled() bool {
+	return _config.Enabled
+}
+
+// Routes registers the specification route.  It is intended to
+// be passed to server.AddBuildRouterCallbacks.
+func Routes(router server.Router) {
+	router.GET(_config.Path, SpecificationHandler)
+}
+
+// SpecificationHandler serves an OpenAPI 3 document of the operations of
+// the gateway, limited to those that the caller may call if so configured.
+func SpecificationHandler(ctx context.Context) {
+	gateway, ok := ctx.GetDataAccessor().(*server.Gateway)
+	if !ok {
+		sendError(ctx, ef.New(ctx, "internalError"))
+		return
+	}
+
+	var include OperationFilter
+	if _config.FilterByConsumer {
+		ctx.SetService(&anonymousService)
+		_, err := authentication.Authenticate(ctx)
+		if err != nil {
+			sendAPIError(ctx, err)
+			return
+		}
+		granted, err := authorization.Authorize(ctx)
+		if err != nil {
+			sendAPIError(ctx, err)
+			return
+		}
+		include = consumerFilter(ctx.Consumer() != nil, ctx.Identity() != nil, granted)
+	}
+
+	specification := Describe(SpecificationInfo{
+		Title:   _config.Title,
+		Version: _config.Version,
+	}, gateway.Services, include)
+	if _config.ServerURL != "" {
+		specification.Servers = []SpecificationServer{{URL: _config.ServerURL}}
+	}
+
+	ctx.SendEntity(specification)
+}
+
+// consumerFilter accepts the operations whose service the caller has
+// authenticated for as required by its authentication type and whose
+// permissions have all been granted.  Permission IDs are compared without
+// their action suffix, as in "orders:read".
+func consumerFilter(consumer, identity bool, granted []string) OperationFilter {
+	grantedIDs := make(map[string]bool, len(granted))
+	for _, id := range granted {
+		grantedIDs[permissionID(id)] = true
+	}
+
+	return func(service *config.Service, operation *config.Operation) bool {
+		switch service.AuthenticationType {
+		case config.AuthenticationTypeTwoLegged:
+			if !consumer {
+				return false
+			}
+		case config.AuthenticationTypeThreeLegged:
+			if !consumer || !identity {
+				return false
+			}
+		}
+		for _, id := range operation.PermissionIDs {
+			if !grantedIDs[permissionID(id)] {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// permissionID strips the action, if any, from id.
+func permissionID(id string) string {
+	if index := strings.IndexByte(id, ':'); index != -1 {
+		return id[0:index]
+	}
+	return id
+}
+
+func sendAPIError(ctx context.Context, err error) {
+	apiErr, ok := err.(*ef.APIError)
+	if !ok {
+		apiErr = ef.New(ctx, "internalError")
+	}
+	sendError(ctx, apiErr)
+}
+
+func sendError(ctx context.Context, err *ef.APIError) {
+	ctx.Rs().SetStatusCode(err.Status)
+	ctx.SendEntity(err)
+}
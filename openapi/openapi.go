@@ -0,0 +1,254 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+
+	"github.com/prizem-io/gateway/config"
+)
+
+type (
+	// Document holds the parts of an OpenAPI 2 (Swagger) or OpenAPI 3
+	// document that describe a service and its operations.
+	Document struct {
+		Swagger  string                                `json:"swagger"`
+		OpenAPI  string                                `json:"openapi"`
+		Info     info                                  `json:"info"`
+		Host     string                                `json:"host"`
+		BasePath string                                `json:"basePath"`
+		Schemes  []string                              `json:"schemes"`
+		Servers  []documentServer                      `json:"servers"`
+		Paths    map[string]map[string]json.RawMessage `json:"paths"`
+	}
+
+	info struct {
+		Title       string `json:"title"`
+		Description string `json:"description"`
+		Version     string `json:"version"`
+	}
+
+	documentServer struct {
+		URL       string                    `json:"url"`
+		Variables map[string]serverVariable `json:"variables"`
+	}
+
+	serverVariable struct {
+		Default string `json:"default"`
+	}
+
+	operation struct {
+		OperationID string   `json:"operationId"`
+		Tags        []string `json:"tags"`
+	}
+)
+
+var (
+	// methods are the operations of a path item, in the order
+	// in which they are converted.
+	methods = []string{"get", "put", "post", "delete", "options", "head", "patch"}
+
+	pathParam  = regexp.MustCompile(`^\{([^{}]+)\}$`)
+	identifier = regexp.MustCompile(`[^A-Za-z0-9]+`)
+)
+
+// Parse reads an OpenAPI 2 or 3 document in either JSON or YAML.
+func Parse(data []byte) (*Document, error) {
+	data, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var document Document
+	err = json.Unmarshal(data, &document)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case strings.HasPrefix(document.Swagger, "2."):
+	case strings.HasPrefix(document.OpenAPI, "3."):
+	default:
+		return nil, fmt.Errorf("Unsupported document version, expected swagger 2.x or openapi 3.x")
+	}
+
+	return &document, nil
+}
+
+// Service converts the document to a service named name, or after the title
+// of the document if name is empty.  Operations whose paths cannot be
+// routed by the gateway are left out and reported in skipped.
+func (d *Document) Service(name string) (service *config.Service, skipped []string, err error) {
+	if name == "" {
+		name = lowerCamel(d.Info.Title)
+	}
+	if name == "" {
+		return nil, nil, fmt.Errorf("A service name is required when the document has no title")
+	}
+
+	scheme, hostname, basePath, err := d.upstream()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	service = &config.Service{}
+	service.Name = name
+	service.DefaultVersion = "v1"
+	service.AuthenticationType = config.AuthenticationTypeNone
+	service.Backend = &config.PluginConfig{Name: "http"}
+	service.Operations = []config.Operation{}
+	if d.Info.Description != "" {
+		description := d.Info.Description
+		service.Description = &description
+	}
+	if hostname != "" {
+		service.Hostnames = []string{hostname}
+	}
+	if scheme != "" {
+		service.Scheme = &scheme
+	}
+	if basePath != "" {
+		service.ContextRoot = &basePath
+	}
+
+	paths := make([]string, 0, len(d.Paths))
+	for path := range d.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		pathItem := d.Paths[path]
+		for _, method := range methods {
+			raw, ok := pathItem[method]
+			if !ok {
+				continue
+			}
+
+			var o operation
+			err := json.Unmarshal(raw, &o)
+			if err != nil {
+				return nil, nil, fmt.Errorf("Could not read operation %s %s: %s", strings.ToUpper(method), path, err)
+			}
+
+			uriPattern, err := routerPath(path)
+			if err != nil {
+				skipped = append(skipped, fmt.Sprintf("%s %s: %s", strings.ToUpper(method), path, err))
+				continue
+			}
+
+			operationName := o.OperationID
+			if operationName == "" {
+				operationName = generatedName(method, path)
+			}
+
+			service.Operations = append(service.Operations, config.Operation{
+				Name:          operationName,
+				Method:        config.Method(strings.ToUpper(method)),
+				URIPattern:    uriPattern,
+				PermissionIDs: []string{},
+				Claims:        []config.ClaimEntry{},
+				Filters:       []config.PluginConfig{},
+				Tags:          o.Tags,
+			})
+		}
+	}
+
+	return service, skipped, nil
+}
+
+// upstream returns the scheme, hostname and base path of the first server
+// of an OpenAPI 3 document or of the host of a Swagger document.
+func (d *Document) upstream() (scheme, hostname, basePath string, err error) {
+	if d.Swagger != "" {
+		for _, s := range d.Schemes {
+			// Prefer https when the upstream supports it
+			if scheme == "" || s == "https" {
+				scheme = s
+			}
+		}
+		return scheme, d.Host, trimBasePath(d.BasePath), nil
+	}
+
+	if len(d.Servers) == 0 {
+		return "", "", "", nil
+	}
+
+	rawURL := d.Servers[0].URL
+	for name, variable := range d.Servers[0].Variables {
+		rawURL = strings.Replace(rawURL, "{"+name+"}", variable.Default, -1)
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", "", "", fmt.Errorf("Invalid server URL %q: %s", rawURL, err)
+	}
+	// Relative URLs are relative to where the document is served, which
+	// is unknown here
+	if u.Host == "" {
+		return "", "", "", fmt.Errorf("Server URL %q is relative, an absolute URL is required", rawURL)
+	}
+
+	return u.Scheme, u.Host, trimBasePath(u.Path), nil
+}
+
+// routerPath converts a templated OpenAPI path to the named parameter syntax
+// of the router.  Parameters must make up a whole path segment.
+func routerPath(path string) (string, error) {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if match := pathParam.FindStringSubmatch(segment); match != nil {
+			segments[i] = ":" + match[1]
+		} else if strings.ContainsAny(segment, "{}") {
+			return "", fmt.Errorf("parameters must make up a whole path segment")
+		}
+	}
+
+	return strings.Join(segments, "/"), nil
+}
+
+// generatedName names an operation without an operationId after its method
+// and path, as in getPetsByPetId for GET /pets/{petId}.
+func generatedName(method, path string) string {
+	name := method
+	for _, segment := range strings.Split(path, "/") {
+		if match := pathParam.FindStringSubmatch(segment); match != nil {
+			name += "By" + upperFirst(lowerCamel(match[1]))
+		} else {
+			name += upperFirst(lowerCamel(segment))
+		}
+	}
+	return name
+}
+
+func lowerCamel(value string) string {
+	words := identifier.Split(value, -1)
+	result := ""
+	for _, word := range words {
+		if word == "" {
+			continue
+		}
+		if result == "" {
+			result = strings.ToLower(word[:1]) + word[1:]
+		} else {
+			result += upperFirst(word)
+		}
+	}
+	return result
+}
+
+func upperFirst(value string) string {
+	if value == "" {
+		return value
+	}
+	return strings.ToUpper(value[:1]) + value[1:]
+}
+
+func trimBasePath(basePath string) string {
+	return strings.TrimRight(basePath, "/")
+}
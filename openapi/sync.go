@@ -0,0 +1,126 @@
+package openapi
+
+import (
+	"reflect"
+
+	"github.com/prizem-io/gateway/config"
+)
+
+type (
+	// Changes lists the operations, by name, that Sync added, updated
+	// or removed, along with those that were skipped during conversion.
+	Changes struct {
+		Added   []string `json:"added"`
+		Updated []string `json:"updated"`
+		Removed []string `json:"removed"`
+		Skipped []string `json:"skipped"`
+	}
+
+	// FindService returns the service named name, or nil if there is none.
+	FindService func(name string) (*config.Service, error)
+)
+
+// Import converts document to a service and syncs it with existing or, if
+// that is nil, with the service that find returns for the name of the
+// conversion.  The service takes the name of existing when name is empty.
+func Import(document *Document, name string, existing *config.Service, find FindService) (*config.Service, *Changes, error) {
+	if name == "" && existing != nil {
+		name = existing.Name
+	}
+	imported, skipped, err := document.Service(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if existing == nil && find != nil {
+		existing, err = find(imported.Name)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	service, changes := Sync(existing, imported)
+	changes.Skipped = append(changes.Skipped, skipped...)
+
+	return service, changes, nil
+}
+
+// Sync applies an imported service to an existing one.  The upstream
+// location and the operations follow the import.  Everything that the
+// document does not describe, such as filters, permissions, claims and
+// backends, is kept, including on operations matched by name or, failing
+// that, by method and URI pattern.  Operations that are no longer in the
+// import are removed.
+func Sync(existing, imported *config.Service) (*config.Service, *Changes) {
+	changes := &Changes{
+		Added:   []string{},
+		Updated: []string{},
+		Removed: []string{},
+		Skipped: []string{},
+	}
+
+	if existing == nil {
+		for _, operation := range imported.Operations {
+			changes.Added = append(changes.Added, operation.Name)
+		}
+		return imported, changes
+	}
+
+	synced := *existing
+	if len(imported.Hostnames) > 0 {
+		synced.Hostnames = imported.Hostnames
+	}
+	if imported.Scheme != nil {
+		synced.Scheme = imported.Scheme
+	}
+	if imported.ContextRoot != nil {
+		synced.ContextRoot = imported.ContextRoot
+	}
+	if imported.Description != nil {
+		synced.Description = imported.Description
+	}
+
+	matched := make([]bool, len(existing.Operations))
+	synced.Operations = make([]config.Operation, 0, len(imported.Operations))
+	for _, operation := range imported.Operations {
+		i := matchOperation(existing.Operations, matched, &operation)
+		if i == -1 {
+			synced.Operations = append(synced.Operations, operation)
+			changes.Added = append(changes.Added, operation.Name)
+			continue
+		}
+
+		matched[i] = true
+		updated := existing.Operations[i]
+		updated.Name = operation.Name
+		updated.Method = operation.Method
+		updated.URIPattern = operation.URIPattern
+		updated.Tags = operation.Tags
+		if !reflect.DeepEqual(updated, existing.Operations[i]) {
+			changes.Updated = append(changes.Updated, operation.Name)
+		}
+		synced.Operations = append(synced.Operations, updated)
+	}
+
+	for i, operation := range existing.Operations {
+		if !matched[i] {
+			changes.Removed = append(changes.Removed, operation.Name)
+		}
+	}
+
+	return &synced, changes
+}
+
+func matchOperation(operations []config.Operation, matched []bool, operation *config.Operation) int {
+	for i := range operations {
+		if !matched[i] && operations[i].Name == operation.Name {
+			return i
+		}
+	}
+	for i := range operations {
+		if !matched[i] && operations[i].Method == operation.Method && operations[i].URIPattern == operation.URIPattern {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,381 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+)
+
+type (
+	// gatewayConfigLoader reads one or more gateway configuration documents,
+	// following include directives, and merges them into a single GatewayConfig.
+	gatewayConfigLoader struct {
+		config  GatewayConfig
+		sources []string
+		visited map[string]bool
+		origins map[string]string
+		seen    map[string]int
+		errors  []string
+	}
+
+	// ConfigLoadError reports every problem encountered while loading
+	// a split gateway configuration.
+	ConfigLoadError struct {
+		Problems []string
+	}
+)
+
+var configExtensions = []string{".yaml", ".yml", ".json"}
+
+// LoadGatewayConfig reads the gateway configuration at configLocation.
+// The location may be a file, a URL, a directory or a glob pattern.
+// Directories are expanded to the YAML and JSON files they contain.
+// Each document may list other documents to load in an "include" section;
+// relative includes are resolved against the including document.
+// Environment variable and secret references in the string values of
+// each document are interpolated after it is parsed.
+func LoadGatewayConfig(configLocation string) (*GatewayConfig, error) {
+	gatewayConfig, _, err := readGatewayConfig(configLocation)
+	return gatewayConfig, err
+}
+
+// GatewayConfigSources returns the files and URLs that make up the
+// gateway configuration at configLocation.
+func GatewayConfigSources(configLocation string) ([]string, error) {
+	_, sources, err := readGatewayConfig(configLocation)
+	return sources, err
+}
+
+func readGatewayConfig(configLocation string) (*GatewayConfig, []string, error) {
+	loader := gatewayConfigLoader{
+		visited: map[string]bool{},
+		origins: map[string]string{},
+		seen:    map[string]int{},
+	}
+
+	locations, err := expandConfigLocation(configLocation)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(locations) == 0 {
+		return nil, nil, fmt.Errorf("No gateway configuration found at %s", configLocation)
+	}
+
+	for _, location := range locations {
+		loader.load(location)
+	}
+
+	if len(loader.errors) > 0 {
+		return nil, loader.sources, &ConfigLoadError{Problems: loader.errors}
+	}
+
+	return &loader.config, loader.sources, nil
+}
+
+func (e *ConfigLoadError) Error() string {
+	return strings.Join(e.Problems, "\n")
+}
+
+func (l *gatewayConfigLoader) load(location string) {
+	// Includes such as "a/../b.yaml" and "b.yaml" are the same file
+	if !isRemoteLocation(location) {
+		location = filepath.Clean(location)
+	}
+	if l.visited[location] {
+		return
+	}
+	l.visited[location] = true
+	l.sources = append(l.sources, location)
+
+	data, err := readConfigLocation(location)
+	if err != nil {
+		l.errors = append(l.errors, fmt.Sprintf("%s: %s", location, err))
+		return
+	}
+
+	// Documents are parsed before they are interpolated so that only
+	// string values are replaced
+	document, err := parseConfigDocument(location, data)
+	if err != nil {
+		l.errors = append(l.errors, fmt.Sprintf("%s: %s", location, err))
+		return
+	}
+
+	l.merge(location, data, document)
+
+	for _, include := range document.Include {
+		includeLocation, err := resolveInclude(location, include)
+		if err != nil {
+			l.errors = append(l.errors, fmt.Sprintf("%s: %s", location, err))
+			continue
+		}
+
+		locations, err := expandConfigLocation(includeLocation)
+		if err != nil {
+			l.errors = append(l.errors, fmt.Sprintf("%s: %s", location, err))
+			continue
+		}
+		if len(locations) == 0 {
+			l.errors = append(l.errors, fmt.Sprintf("%s: include %q did not match any files", location, include))
+		}
+		for _, location := range locations {
+			l.load(location)
+		}
+	}
+}
+
+func (l *gatewayConfigLoader) merge(location string, data []byte, document *GatewayConfig) {
+	for _, consumer := range document.Consumers {
+		if l.register(location, data, "consumer", "id", consumer.ID) {
+			l.config.Consumers = append(l.config.Consumers, consumer)
+		}
+	}
+
+	for _, credential := range document.Credentials {
+		id, _ := getCredentialField(credential, "id")
+		if l.register(location, data, "credential", "id", id) {
+			l.config.Credentials = append(l.config.Credentials, credential)
+		}
+	}
+
+	for _, permission := range document.Permissions {
+		if l.register(location, data, "permission", "id", permission.ID) {
+			l.config.Permissions = append(l.config.Permissions, permission)
+		}
+	}
+
+	for _, plan := range document.Plans {
+		if l.register(location, data, "plan", "id", plan.ID) {
+			l.config.Plans = append(l.config.Plans, plan)
+		}
+	}
+
+	for _, plugin := range document.Plugins {
+		if l.register(location, data, "plugin", "id", plugin.ID) {
+			l.config.Plugins = append(l.config.Plugins, plugin)
+		}
+	}
+
+	for _, service := range document.Services {
+		// Services are commonly defined without an ID so fall back to the name
+		key, value := "id", service.ID
+		if value == "" {
+			key, value = "name", service.Name
+		}
+		if l.register(location, data, "service", key, value) {
+			l.config.Services = append(l.config.Services, service)
+		}
+	}
+}
+
+// register records where an entity was defined and reports whether
+// it is the first definition of that entity.
+func (l *gatewayConfigLoader) register(location string, data []byte, entityType, key, id string) bool {
+	// Entities without an identifier cannot conflict
+	if id == "" {
+		return true
+	}
+
+	// Count occurrences so that repeated definitions within
+	// the same document are attributed to the correct line
+	occurrence := location + "|" + key + "|" + id
+	l.seen[occurrence]++
+
+	origin := location
+	if line := findLine(data, key, id, l.seen[occurrence]); line > 0 {
+		origin = fmt.Sprintf("%s:%d", location, line)
+	}
+
+	lookup := entityType + "|" + id
+	if first, ok := l.origins[lookup]; ok {
+		l.errors = append(l.errors, fmt.Sprintf(
+			"%s: duplicate %s %s %q (first defined at %s)",
+			origin, entityType, key, id, first))
+		return false
+	}
+
+	l.origins[lookup] = origin
+	return true
+}
+
+// findLine returns the line number of the nth line in data that assigns
+// value to key, or 0 if it cannot be found.
+func findLine(data []byte, key, value string, nth int) int {
+	pattern, err := regexp.Compile(`^\s*-?\s*["']?` + regexp.QuoteMeta(key) +
+		`["']?\s*:\s*["']?` + regexp.QuoteMeta(value) + `["']?\s*,?\s*$`)
+	if err != nil {
+		return 0
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	line := 0
+	for scanner.Scan() {
+		line++
+		if pattern.Match(scanner.Bytes()) {
+			nth--
+			if nth == 0 {
+				return line
+			}
+		}
+	}
+
+	return 0
+}
+
+// parseConfigDocument parses the YAML or JSON document data and
+// interpolates its string values before they are decoded, so that
+// expressions may also provide numbers and booleans.
+func parseConfigDocument(location string, data []byte) (*GatewayConfig, error) {
+	var document GatewayConfig
+	var err error
+	isJSON := filepath.Ext(location) == ".json"
+	if !bytes.Contains(data, interpolationStart) {
+		if isJSON {
+			err = json.Unmarshal(data, &document)
+		} else {
+			err = yaml.Unmarshal(data, &document)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &document, nil
+	}
+
+	if !isJSON {
+		if data, err = yaml.YAMLToJSON(data); err != nil {
+			return nil, err
+		}
+	}
+	var tree interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err = decoder.Decode(&tree); err != nil {
+		return nil, err
+	}
+
+	tree, err = interpolateValues(tree, reflect.TypeOf(document))
+	if err != nil {
+		return nil, err
+	}
+	if data, err = json.Marshal(tree); err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+	return &document, nil
+}
+
+func readConfigLocation(location string) ([]byte, error) {
+	if !isRemoteLocation(location) {
+		return ioutil.ReadFile(location)
+	}
+
+	resp, err := http.Get(location)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Unexpected status code %d", resp.StatusCode)
+	}
+
+	return ioutil.ReadAll(resp.Body)
+}
+
+// expandConfigLocation turns a directory or glob pattern into the
+// sorted list of configuration files that it refers to.
+func expandConfigLocation(location string) ([]string, error) {
+	if isRemoteLocation(location) {
+		return []string{location}, nil
+	}
+
+	if strings.ContainsAny(location, "*?[") {
+		matches, err := filepath.Glob(location)
+		if err != nil {
+			return nil, err
+		}
+
+		// Patterns such as "services/*" may match directories
+		files := make([]string, 0, len(matches))
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil {
+				return nil, err
+			}
+			if !info.IsDir() {
+				files = append(files, match)
+			}
+		}
+		sort.Strings(files)
+		return files, nil
+	}
+
+	info, err := os.Stat(location)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		return []string{location}, nil
+	}
+
+	files := []string{}
+	for _, ext := range configExtensions {
+		matches, err := filepath.Glob(filepath.Join(location, "*"+ext))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	sort.Strings(files)
+
+	return files, nil
+}
+
+func resolveInclude(location, include string) (string, error) {
+	if isRemoteLocation(include) {
+		return include, nil
+	}
+
+	if isRemoteLocation(location) {
+		base, err := url.Parse(location)
+		if err != nil {
+			return "", err
+		}
+		ref, err := url.Parse(include)
+		if err != nil {
+			return "", err
+		}
+		return base.ResolveReference(ref).String(), nil
+	}
+
+	if filepath.IsAbs(include) {
+		return include, nil
+	}
+
+	return filepath.Join(filepath.Dir(location), include), nil
+}
+
+func isConfigFile(name string) bool {
+	ext := filepath.Ext(name)
+	for _, configExt := range configExtensions {
+		if ext == configExt {
+			return true
+		}
+	}
+	return false
+}
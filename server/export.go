@@ -0,0 +1,244 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ghodss/yaml"
+
+	"github.com/prizem-io/gateway/config"
+)
+
+type (
+	// CredentialEncoder may be implemented by a CredentialDecoder to convert
+	// a decoded credential back to the form that it decodes.  Credentials
+	// of decoders that do not implement it are encoded from their
+	// mapstructure, or else json, field names.
+	CredentialEncoder interface {
+		EncodeCredential(credential interface{}) (map[string]interface{}, error)
+	}
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// ExportGatewayConfig converts gateway back to the configuration that it
+// was processed from.  Entities are ordered by ID, plugins by name with
+// the default instance of each first, and services by name, so that
+// gateways processed from equivalent configurations export identical
+// configurations.  Entities served by a
+// DataAccessor are not part of the export.
+func ExportGatewayConfig(gateway *Gateway) (*GatewayConfig, error) {
+	gatewayConfig := GatewayConfig{}
+
+	for _, id := range sortedKeys(gateway.Consumers) {
+		gatewayConfig.Consumers = append(gatewayConfig.Consumers, *gateway.Consumers[id])
+	}
+
+	for _, id := range sortedKeys(gateway.Credentials) {
+		credential, err := EncodeCredential(gateway.Credentials[id])
+		if err != nil {
+			return nil, fmt.Errorf("Could not export credential %s: %s", id, err)
+		}
+		gatewayConfig.Credentials = append(gatewayConfig.Credentials, credential)
+	}
+
+	for _, id := range sortedKeys(gateway.Permissions) {
+		gatewayConfig.Permissions = append(gatewayConfig.Permissions, *gateway.Permissions[id])
+	}
+
+	for _, id := range sortedKeys(gateway.Plans) {
+		gatewayConfig.Plans = append(gatewayConfig.Plans, *gateway.Plans[id])
+	}
+
+	// Every instance of a plugin is exported, not only the default one,
+	// which is first so that it remains the default when processed again
+	pluginIDs := sortedKeys(gateway.PluginsByID)
+	for _, name := range sortedKeys(gateway.Plugins) {
+		defaultPlugin := gateway.Plugins[name]
+		gatewayConfig.Plugins = append(gatewayConfig.Plugins, *defaultPlugin)
+		for _, id := range pluginIDs {
+			if plugin := gateway.PluginsByID[id]; plugin.Name == name && plugin != defaultPlugin {
+				gatewayConfig.Plugins = append(gatewayConfig.Plugins, *plugin)
+			}
+		}
+	}
+
+	gatewayConfig.Services = make([]config.Service, len(gateway.Services))
+	copy(gatewayConfig.Services, gateway.Services)
+	sort.SliceStable(gatewayConfig.Services, func(i, j int) bool {
+		return gatewayConfig.Services[i].Name < gatewayConfig.Services[j].Name
+	})
+
+	return &gatewayConfig, nil
+}
+
+// EncodeCredential converts a decoded credential back to its raw form
+// using the encoder of its type, if there is one.
+func EncodeCredential(credential interface{}) (map[string]interface{}, error) {
+	encoded, ok := encodeValue(reflect.ValueOf(credential)).(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("Unsupported credential %T", credential)
+	}
+
+	credentialType, _ := encoded["type"].(string)
+	if encoder, ok := _credentialDecoders[credentialType].(CredentialEncoder); ok {
+		return encoder.EncodeCredential(credential)
+	}
+
+	return encoded, nil
+}
+
+// MarshalGatewayConfig writes gatewayConfig as "json" or "yaml" and returns
+// the SHA-256 hash of its canonical JSON form, which does not depend on
+// the format.  Fields that are not set are left out.
+func MarshalGatewayConfig(gatewayConfig *GatewayConfig, format string) ([]byte, string, error) {
+	data, err := json.Marshal(gatewayConfig)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var fields interface{}
+	err = json.Unmarshal(data, &fields)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Maps are marshalled in key order
+	canonical, err := json.Marshal(withoutNulls(fields))
+	if err != nil {
+		return nil, "", err
+	}
+	sum := sha256.Sum256(canonical)
+	hash := "sha256:" + hex.EncodeToString(sum[:])
+
+	switch format {
+	case "json":
+		return canonical, hash, nil
+	case "yaml", "":
+		data, err := yaml.JSONToYAML(canonical)
+		return data, hash, err
+	}
+
+	return nil, "", fmt.Errorf("Unknown format %q, expected json or yaml", format)
+}
+
+// encodeValue converts structs to maps keyed by their mapstructure or json
+// field names, flattening squashed fields and omitting nil values and
+// zero dates.
+func encodeValue(value reflect.Value) interface{} {
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if value.IsNil() {
+			return nil
+		}
+		return encodeValue(value.Elem())
+	case reflect.Struct:
+		if value.Type() == timeType {
+			// Credentials are decoded without time conversions, so only
+			// dates that were set are kept
+			if value.Interface().(time.Time).IsZero() {
+				return nil
+			}
+			return value.Interface()
+		}
+		encoded := map[string]interface{}{}
+		encodeFields(value, encoded)
+		return encoded
+	case reflect.Map:
+		if value.IsNil() {
+			return nil
+		}
+		encoded := make(map[string]interface{}, value.Len())
+		for _, key := range value.MapKeys() {
+			encoded[fmt.Sprint(key.Interface())] = encodeValue(value.MapIndex(key))
+		}
+		return encoded
+	case reflect.Slice:
+		if value.IsNil() {
+			return nil
+		}
+		encoded := make([]interface{}, value.Len())
+		for i := range encoded {
+			encoded[i] = encodeValue(value.Index(i))
+		}
+		return encoded
+	}
+
+	return value.Interface()
+}
+
+func encodeFields(value reflect.Value, encoded map[string]interface{}) {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name, squash := fieldName(field)
+		if name == "-" {
+			continue
+		}
+		if squash || (field.Anonymous && field.Type.Kind() == reflect.Struct) {
+			encodeFields(value.Field(i), encoded)
+			continue
+		}
+
+		if fieldValue := encodeValue(value.Field(i)); fieldValue != nil {
+			encoded[name] = fieldValue
+		}
+	}
+}
+
+func fieldName(field reflect.StructField) (string, bool) {
+	name, squash := "", false
+	for _, tag := range []string{"mapstructure", "json"} {
+		parts := strings.Split(field.Tag.Get(tag), ",")
+		for _, option := range parts[1:] {
+			if option == "squash" {
+				squash = true
+			}
+		}
+		if name == "" {
+			name = parts[0]
+		}
+	}
+	if name == "" {
+		name = strings.ToLower(field.Name[:1]) + field.Name[1:]
+	}
+	return name, squash
+}
+
+// withoutNulls removes null fields from maps decoded from JSON.
+func withoutNulls(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, field := range value {
+			if field == nil {
+				delete(value, key)
+			} else {
+				value[key] = withoutNulls(field)
+			}
+		}
+	case []interface{}:
+		for i, item := range value {
+			value[i] = withoutNulls(item)
+		}
+	}
+	return value
+}
+
+// sortedKeys returns the keys of a map with string keys in order.
+func sortedKeys(m interface{}) []string {
+	keys := []string{}
+	for _, key := range reflect.ValueOf(m).MapKeys() {
+		keys = append(keys, key.String())
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -166,29 +166,36 @@ func (ctx *FastHttpRequest) RequestPathBytes(escape bool) []byte {
 	return ctx.RequestCtx.RequestURI()
 }
 
-// RequestIP gets just the Remote Address from the client.
+// RequestIP returns the IP address of the client.  The addresses that
+// proxies forward for are only honored from trusted proxies.
 func (ctx *FastHttpRequest) RequestIP() string {
+	return server.ClientIP(
+		ctx.peerIP(),
+		string(ctx.RequestCtx.Request.Header.Peek("Forwarded")),
+		string(ctx.RequestCtx.Request.Header.Peek("X-Forwarded-For")))
+}
+
+// peerIP returns the IP address of the connection.
+func (ctx *FastHttpRequest) peerIP() string {
 	if ip, _, err := net.SplitHostPort(strings.TrimSpace(ctx.RequestCtx.RemoteAddr().String())); err == nil {
 		return ip
 	}
 	return ""
 }
 
-// RemoteAddr is like RequestIP but it checks for proxy servers also, tries to get the real client's request IP
+// Time returns when the request started to be served
+func (ctx *FastHttpRequest) Time() time.Time {
+	return ctx.RequestCtx.Time()
+}
+
+// RemoteAddr is like RequestIP but it also honors the X-Real-Ip header of
+// trusted proxies
 func (ctx *FastHttpRequest) RemoteAddr() string {
-	header := string(ctx.RequestCtx.Request.Header.Peek("X-Real-Ip"))
-	realIP := strings.TrimSpace(header)
-	if realIP != "" {
-		return realIP
-	}
-	realIP = string(ctx.RequestCtx.Request.Header.Peek("X-Forwarded-For"))
-	idx := strings.IndexByte(realIP, ',')
-	if idx >= 0 {
-		realIP = realIP[0:idx]
-	}
-	realIP = strings.TrimSpace(realIP)
-	if realIP != "" {
-		return realIP
+	if server.IsTrustedProxy(net.ParseIP(ctx.peerIP())) {
+		realIP := strings.TrimSpace(string(ctx.RequestCtx.Request.Header.Peek("X-Real-Ip")))
+		if realIP != "" {
+			return realIP
+		}
 	}
 	return ctx.RequestIP()
 }
@@ -559,6 +566,10 @@ func (ctx *FastHttpResponse) SetConnectionClose() {
 	ctx.Response.SetConnectionClose()
 }
 
+func (ctx *FastHttpResponse) StatusCode() int {
+	return ctx.Response.StatusCode()
+}
+
 func (ctx *FastHttpResponse) SetStatusCode(status int) {
 	ctx.Response.SetStatusCode(status)
 }
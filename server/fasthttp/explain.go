@@ -0,0 +1,225 @@
+package fasthttp
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/buaazp/fasthttprouter"
+	"github.com/valyala/fasthttp"
+
+	"github.com/prizem-io/gateway/authentication"
+	"github.com/prizem-io/gateway/authorization"
+	"github.com/prizem-io/gateway/backend"
+	ef "github.com/prizem-io/gateway/errorfactory"
+	"github.com/prizem-io/gateway/filter"
+	"github.com/prizem-io/gateway/identity"
+	"github.com/prizem-io/gateway/server"
+)
+
+type (
+	// ExplainRequest describes a request to run through the gateway
+	// without invoking any filters or upstreams.
+	ExplainRequest struct {
+		Method  string            `json:"method"`
+		Host    string            `json:"host"`
+		Path    string            `json:"path"`
+		Headers map[string]string `json:"headers"`
+		// Credential is an access token sent as a bearer token
+		// unless an Authorization header is already provided
+		Credential string `json:"credential,omitempty"`
+	}
+
+	// Explanation reports each decision the gateway would make for an ExplainRequest.
+	Explanation struct {
+		Request       ExplainRequest    `json:"request"`
+		Service       string            `json:"service,omitempty"`
+		Operation     string            `json:"operation,omitempty"`
+		Params        map[string]string `json:"params,omitempty"`
+		Authenticator string            `json:"authenticator,omitempty"`
+		CredentialID  string            `json:"credentialId,omitempty"`
+		Consumer      string            `json:"consumer,omitempty"`
+		Plan          string            `json:"plan,omitempty"`
+		Permissions   []string          `json:"permissions,omitempty"`
+		Claims        identity.Claims   `json:"claims,omitempty"`
+		Filters       []ExplainedFilter `json:"filters,omitempty"`
+		Backend       string            `json:"backend,omitempty"`
+		Target        string            `json:"target,omitempty"`
+		Status        int               `json:"status"`
+		Error         interface{}       `json:"error,omitempty"`
+	}
+
+	ExplainedFilter struct {
+		Name          string      `json:"name"`
+		Priority      int         `json:"priority"`
+		Configuration interface{} `json:"configuration,omitempty"`
+		Sources       []string    `json:"sources,omitempty"`
+	}
+)
+
+const explainRouteKey = "__explainRoute"
+
+// Explain matches request against the routes of gateway and runs the
+// authentication and authorization steps to resolve the consumer, plan,
+// permissions and filter chain.  Filters and upstreams are never invoked.
+func Explain(gateway *server.Gateway, request ExplainRequest) (explanation *Explanation) {
+	if request.Method == "" {
+		request.Method = "GET"
+	}
+
+	explanation = &Explanation{
+		Request: request,
+		Status:  http.StatusOK,
+	}
+
+	var req fasthttp.Request
+	req.Header.SetMethod(request.Method)
+	req.SetRequestURI(request.Path)
+	if request.Host != "" {
+		req.Header.SetHost(request.Host)
+	}
+	for key, value := range request.Headers {
+		req.Header.Set(key, value)
+	}
+	if request.Credential != "" && len(req.Header.Peek("Authorization")) == 0 {
+		req.Header.Set("Authorization", "Bearer "+request.Credential)
+	}
+
+	var frc fasthttp.RequestCtx
+	frc.Init(&req, nil, nil)
+
+	ctx := AcquireFastHttpContext(&frc, "consumer")
+	ctx.SetDataAccessor(gateway)
+	ctx.SetDryRun(true)
+	defer func() {
+		// Authenticators may panic when their datastores are unavailable
+		if rcv := recover(); rcv != nil {
+			explanation.fail(http.StatusInternalServerError, fmt.Errorf("%v", rcv))
+		}
+		ctx.Reset()
+		ReleaseFastHttpContext(ctx)
+	}()
+
+	route := lookupRoute(gateway, &frc)
+	if route == nil {
+		explanation.fail(http.StatusNotFound, fmt.Errorf("No operation matches %s %s", request.Method, request.Path))
+		return
+	}
+
+	ctx.SetService(route.Service)
+	ctx.SetOperation(route.Operation)
+	explanation.Service = route.Service.Name
+	explanation.Operation = route.Operation.Name
+	explanation.Params = map[string]string{}
+	ctx.rq.VisitParams(func(key, value string) {
+		if key != explainRouteKey {
+			explanation.Params[key] = value
+		}
+	})
+
+	authenticator, err := authentication.Authenticate(ctx)
+	if authenticator != nil {
+		explanation.Authenticator = authenticator.Name()
+	}
+	if credential := ctx.Credential(); credential != nil {
+		explanation.CredentialID = credential.ID
+	}
+	if consumer := ctx.Consumer(); consumer != nil {
+		explanation.Consumer = consumer.ID
+	}
+	if plan := ctx.Plan(); plan != nil {
+		explanation.Plan = plan.ID
+	}
+	if err != nil {
+		explanation.fail(http.StatusUnauthorized, err)
+		return
+	}
+
+	permissions, err := authorization.Authorize(ctx)
+	explanation.Permissions = permissions
+	explanation.Claims = ctx.Claims()
+	if err != nil {
+		explanation.fail(http.StatusForbidden, err)
+		return
+	}
+
+	executions, err := filter.Executions(ctx)
+	if err != nil {
+		explanation.fail(http.StatusInternalServerError, err)
+		return
+	}
+	explanation.Filters = make([]ExplainedFilter, 0, len(executions))
+	for i := range executions {
+		execution := &executions[i]
+		configuration, applies, err := execution.Resolve(ctx)
+		if err != nil {
+			explanation.fail(http.StatusInternalServerError, err)
+			return
+		}
+		if !applies {
+			continue
+		}
+		explanation.Filters = append(explanation.Filters, ExplainedFilter{
+			Name:          execution.Filter.Name(),
+			Priority:      execution.Filter.Priority(),
+			Configuration: configuration,
+			Sources:       execution.Sources,
+		})
+	}
+
+	backendName := route.Service.Backend.Name
+	handler, err := backend.GetHandler(ctx, &backendName)
+	if err != nil {
+		explanation.fail(http.StatusInternalServerError, err)
+		return
+	}
+	explanation.Backend = handler.Name()
+	if resolver, ok := handler.(backend.TargetResolver); ok {
+		explanation.Target = resolver.Target(ctx)
+	}
+
+	return
+}
+
+func (e *Explanation) fail(status int, err error) {
+	if apiErr, ok := err.(*ef.APIError); ok {
+		e.Status = apiErr.Status
+		e.Error = apiErr
+		return
+	}
+	e.Status = status
+	e.Error = err.Error()
+}
+
+// lookupRoute builds a router that records the matched operation
+// instead of handling the request.
+func lookupRoute(gateway *server.Gateway, frc *fasthttp.RequestCtx) *operationRoute {
+	router := fasthttprouter.New()
+
+	for j := range gateway.Services {
+		service := &gateway.Services[j]
+
+		for i := range service.Operations {
+			operation := &service.Operations[i]
+			source, target := operationPaths(service, operation)
+
+			route := &operationRoute{
+				Gateway:   gateway,
+				Service:   service,
+				Operation: operation,
+				Path:      target,
+			}
+			checkRoute(router, operation.Method.String(), source, func(frc *fasthttp.RequestCtx) {
+				frc.SetUserValue(explainRouteKey, route)
+			})
+		}
+	}
+
+	handler, _ := router.Lookup(string(frc.Method()), string(frc.Path()), frc)
+	if handler == nil {
+		return nil
+	}
+	handler(frc)
+
+	route, _ := frc.UserValue(explainRouteKey).(*operationRoute)
+	return route
+}
@@ -2,6 +2,8 @@ package fasthttp
 
 import (
 	"bytes"
+	"fmt"
+	"strings"
 	"sync/atomic"
 	"unsafe"
 
@@ -11,6 +13,7 @@ import (
 
 	"github.com/prizem-io/gateway/config"
 	ef "github.com/prizem-io/gateway/errorfactory"
+	"github.com/prizem-io/gateway/filter"
 	"github.com/prizem-io/gateway/server"
 )
 
@@ -26,6 +29,13 @@ type (
 		Operation *config.Operation
 		Path      string
 	}
+
+	// preflightRoute answers CORS preflight requests for the operations of
+	// a path, by method.
+	preflightRoute struct {
+		Gateway    *server.Gateway
+		Operations map[string]*operationRoute
+	}
 )
 
 var (
@@ -39,12 +49,27 @@ func LoadGatewayRouter() error {
 		return err
 	}
 
-	LoadRouter(gateway)
-
-	return nil
+	return LoadRouter(gateway)
 }
 
-func LoadRouter(gateway *server.Gateway) {
+// LoadRouter builds the router for gateway and swaps it in.  If the routes
+// of gateway conflict, an error is returned and the current router is kept.
+func LoadRouter(gateway *server.Gateway) (err error) {
+	if errs := CheckRoutes(gateway.Services); len(errs) > 0 {
+		messages := make([]string, len(errs))
+		for i, err := range errs {
+			messages[i] = err.Error()
+		}
+		return fmt.Errorf("Conflicting routes: %s", strings.Join(messages, "; "))
+	}
+
+	// Routes added by callbacks may still conflict
+	defer func() {
+		if rcv := recover(); rcv != nil {
+			err = fmt.Errorf("Could not build router: %v", rcv)
+		}
+	}()
+
 	router := fasthttprouter.New()
 	BuildFastHttpRouter(router, gateway)
 
@@ -57,8 +82,30 @@ func LoadRouter(gateway *server.Gateway) {
 	router.MethodNotAllowed = methodNotAllowed
 	router.PanicHandler = internalError
 
+	// Filters are compiled last so that the current router keeps its
+	// chains if building fails
+	filter.Compile(gateway.Services)
+
 	f := router.Handler
 	atomic.StorePointer(&fastHttpRouterHandler, unsafe.Pointer(&f))
+	return nil
+}
+
+// NewHandler returns a request handler for the routes registered by
+// callbacks.  It is used to serve routes on a listener of their own.
+func NewHandler(callbacks ...server.BuildRouterCallback) fasthttp.RequestHandler {
+	router := fasthttprouter.New()
+
+	pr := &fastHttpRouter{router: router}
+	for _, callback := range callbacks {
+		callback(pr)
+	}
+	router.NotFound = notFound
+	router.HandleMethodNotAllowed = true
+	router.MethodNotAllowed = methodNotAllowed
+	router.PanicHandler = internalError
+
+	return router.Handler
 }
 
 func Serve(ctx *fasthttp.RequestCtx) {
@@ -68,45 +115,120 @@ func Serve(ctx *fasthttp.RequestCtx) {
 }
 
 func BuildFastHttpRouter(router *fasthttprouter.Router, gateway *server.Gateway) {
+	preflights := map[string]*preflightRoute{}
+	paths := []string{}
+
 	for j := range gateway.Services {
 		service := &gateway.Services[j]
 
 		for i := range service.Operations {
 			operation := &service.Operations[i]
-			sourceSize := len(operation.URIPattern)
-			targetSize := len(operation.URIPattern)
-			if service.ContextRoot != nil {
-				targetSize += len(*service.ContextRoot)
-			}
-			if service.URIPrefix != nil {
-				sourceSize += len(*service.URIPrefix)
-				targetSize += len(*service.URIPrefix)
-			}
+			source, target := operationPaths(service, operation)
 
-			sourceBuffer := bytes.NewBuffer(make([]byte, 0, sourceSize))
-			targetBuffer := bytes.NewBuffer(make([]byte, 0, targetSize))
-			if service.ContextRoot != nil {
-				targetBuffer.WriteString(*service.ContextRoot)
-			}
-			if service.URIPrefix != nil {
-				sourceBuffer.WriteString(*service.URIPrefix)
-				targetBuffer.WriteString(*service.URIPrefix)
-			}
-			sourceBuffer.WriteString(operation.URIPattern)
-			targetBuffer.WriteString(operation.URIPattern)
-
-			route := operationRoute{
+			route := &operationRoute{
 				Gateway:   gateway,
 				Service:   service,
 				Operation: operation,
-				Path:      targetBuffer.String(),
+				Path:      target,
 			}
 			router.Handle(
 				operation.Method.String(),
-				sourceBuffer.String(),
+				source,
 				route.handleRouter)
+
+			preflight, ok := preflights[source]
+			if !ok {
+				preflight = &preflightRoute{
+					Gateway:    gateway,
+					Operations: map[string]*operationRoute{},
+				}
+				preflights[source] = preflight
+				paths = append(paths, source)
+			}
+			preflight.Operations[operation.Method.String()] = route
 		}
 	}
+
+	if !server.PreflightEnabled() {
+		return
+	}
+
+	for _, path := range paths {
+		preflight := preflights[path]
+		if _, ok := preflight.Operations["OPTIONS"]; ok {
+			continue
+		}
+
+		// Paths whose parameters are named differently for different
+		// methods cannot share a route
+		err := checkRoute(router, "OPTIONS", path, preflight.handleRouter)
+		if err != nil {
+			log.Warnf("Preflight requests to %s are not answered: %v", path, err)
+		}
+	}
+}
+
+// CheckRoutes registers every operation of services with a throwaway router
+// and returns an error for each route that would cause the router to panic.
+func CheckRoutes(services []config.Service) []error {
+	router := fasthttprouter.New()
+	errs := []error{}
+
+	for j := range services {
+		service := &services[j]
+
+		for i := range service.Operations {
+			operation := &service.Operations[i]
+			source, _ := operationPaths(service, operation)
+
+			err := checkRoute(router, operation.Method.String(), source, notFound)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s::%s %s %s: %v",
+					service.Name, operation.Name, operation.Method.String(), source, err))
+			}
+		}
+	}
+
+	return errs
+}
+
+func checkRoute(router *fasthttprouter.Router, method, path string, handle fasthttp.RequestHandler) (err error) {
+	defer func() {
+		if rcv := recover(); rcv != nil {
+			err = fmt.Errorf("%v", rcv)
+		}
+	}()
+
+	router.Handle(method, path, handle)
+	return nil
+}
+
+// operationPaths returns the path that the gateway exposes for operation
+// and the path that is requested from the upstream.
+func operationPaths(service *config.Service, operation *config.Operation) (string, string) {
+	sourceSize := len(operation.URIPattern)
+	targetSize := len(operation.URIPattern)
+	if service.ContextRoot != nil {
+		targetSize += len(*service.ContextRoot)
+	}
+	if service.URIPrefix != nil {
+		sourceSize += len(*service.URIPrefix)
+		targetSize += len(*service.URIPrefix)
+	}
+
+	sourceBuffer := bytes.NewBuffer(make([]byte, 0, sourceSize))
+	targetBuffer := bytes.NewBuffer(make([]byte, 0, targetSize))
+	if service.ContextRoot != nil {
+		targetBuffer.WriteString(*service.ContextRoot)
+	}
+	if service.URIPrefix != nil {
+		sourceBuffer.WriteString(*service.URIPrefix)
+		targetBuffer.WriteString(*service.URIPrefix)
+	}
+	sourceBuffer.WriteString(operation.URIPattern)
+	targetBuffer.WriteString(operation.URIPattern)
+
+	return sourceBuffer.String(), targetBuffer.String()
 }
 
 func (o *operationRoute) handleRouter(frc *fasthttp.RequestCtx) {
@@ -119,6 +241,24 @@ func (o *operationRoute) handleRouter(frc *fasthttp.RequestCtx) {
 	ReleaseFastHttpContext(ctx)
 }
 
+// handleRouter answers a preflight request as the operation of the method
+// in its Access-Control-Request-Method header.
+func (p *preflightRoute) handleRouter(frc *fasthttp.RequestCtx) {
+	route, ok := p.Operations[string(frc.Request.Header.Peek("Access-Control-Request-Method"))]
+	if !ok || len(frc.Request.Header.Peek("Origin")) == 0 {
+		methodNotAllowed(frc)
+		return
+	}
+
+	ctx := AcquireFastHttpContext(frc, "consumer")
+	ctx.SetDataAccessor(p.Gateway)
+	ctx.SetService(route.Service)
+	ctx.SetOperation(route.Operation)
+	server.ServePreflight(ctx)
+	ctx.Reset()
+	ReleaseFastHttpContext(ctx)
+}
+
 func notFound(frc *fasthttp.RequestCtx) {
 	ctx := AcquireFastHttpContext(frc, "consumer")
 	err := ef.New(ctx, "notFound")
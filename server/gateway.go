@@ -1,18 +1,13 @@
 package server
 
 import (
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"net/http"
-	"path/filepath"
-	"strings"
 
 	log "github.com/Sirupsen/logrus"
-	"github.com/ghodss/yaml"
 
 	"github.com/prizem-io/gateway/backend"
 	"github.com/prizem-io/gateway/config"
+	"github.com/prizem-io/gateway/context"
 	"github.com/prizem-io/gateway/filter"
 )
 
@@ -23,13 +18,24 @@ type CredentialDecoder interface {
 
 type ConfigDecoder func(name string, config map[string]interface{}) (interface{}, error)
 
+// PluginInstanceAccessor looks up plugin instances by ID.  It may be
+// implemented by the accessor passed to SetDataAccessor.
+type PluginInstanceAccessor interface {
+	GetPluginInstance(id string) (*config.Plugin, error)
+}
+
+// GatewayConfigProcessor amends the gateway configuration after it
+// is read and before it is processed into a Gateway.
+type GatewayConfigProcessor func(gatewayConfig *GatewayConfig) error
+
 type GatewayConfig struct {
-	Consumers   []config.Consumer        `yaml:"consumers"`
-	Credentials []map[string]interface{} `yaml:"credentials"`
-	Permissions []config.Permission      `yaml:"permissions"`
-	Plans       []config.Plan            `yaml:"plans"`
-	Plugins     []config.Plugin          `yaml:"plugin"`
-	Services    []config.Service         `yaml:"services"`
+	Include     []string                 `json:"include,omitempty" yaml:"include"`
+	Consumers   []config.Consumer        `json:"consumers,omitempty" yaml:"consumers"`
+	Credentials []map[string]interface{} `json:"credentials,omitempty" yaml:"credentials"`
+	Permissions []config.Permission      `json:"permissions,omitempty" yaml:"permissions"`
+	Plans       []config.Plan            `json:"plans,omitempty" yaml:"plans"`
+	Plugins     []config.Plugin          `json:"plugins,omitempty" yaml:"plugin"`
+	Services    []config.Service         `json:"services,omitempty" yaml:"services"`
 }
 
 type Gateway struct {
@@ -39,12 +45,20 @@ type Gateway struct {
 	CredentialsByClient map[string]interface{}
 	Permissions         map[string]*config.Permission
 	Plans               map[string]*config.Plan
-	Plugins             map[string]*config.Plugin
+	// Plugins holds the default instance of each plugin, which is
+	// the first one configured.
+	Plugins     map[string]*config.Plugin
+	PluginsByID map[string]*config.Plugin
+
+	// accessor serves entity lookups in place of the maps above
+	accessor context.DataAccessor
 }
 
 var (
 	_credentialDecoders = map[string]CredentialDecoder{}
 	_configDecoders     = []ConfigDecoder{}
+	_configProcessors   = []GatewayConfigProcessor{}
+	_dataAccessor       context.DataAccessor
 )
 
 func AddCredentialDecoders(decoders ...CredentialDecoder) {
@@ -53,11 +67,30 @@ func AddCredentialDecoders(decoders ...CredentialDecoder) {
 	}
 }
 
+func GetCredentialDecoder(credentialType string) (CredentialDecoder, bool) {
+	decoder, ok := _credentialDecoders[credentialType]
+	return decoder, ok
+}
+
 func AddConfigDecoders(decoders ...ConfigDecoder) {
 	_configDecoders = append(_configDecoders, decoders...)
 }
 
+func AddGatewayConfigProcessors(processors ...GatewayConfigProcessor) {
+	_configProcessors = append(_configProcessors, processors...)
+}
+
+// SetDataAccessor serves consumer, credential, permission, plan and plugin
+// lookups from accessor rather than from the gateway configuration.
+// It applies to gateways processed after it is called.
+func SetDataAccessor(accessor context.DataAccessor) {
+	_dataAccessor = accessor
+}
+
 func (g *Gateway) GetPlugin(name string) (*config.Plugin, error) {
+	if g.accessor != nil {
+		return g.accessor.GetPlugin(name)
+	}
 	plugin, ok := g.Plugins[name]
 	if !ok {
 		return nil, fmt.Errorf("Could not find plugin: %s", name)
@@ -65,7 +98,26 @@ func (g *Gateway) GetPlugin(name string) (*config.Plugin, error) {
 	return plugin, nil
 }
 
+// GetPluginInstance returns the plugin instance with the given ID.
+func (g *Gateway) GetPluginInstance(id string) (*config.Plugin, error) {
+	if g.accessor != nil {
+		instances, ok := g.accessor.(PluginInstanceAccessor)
+		if !ok {
+			return nil, fmt.Errorf("Could not find plugin instance: %s", id)
+		}
+		return instances.GetPluginInstance(id)
+	}
+	plugin, ok := g.PluginsByID[id]
+	if !ok {
+		return nil, fmt.Errorf("Could not find plugin instance: %s", id)
+	}
+	return plugin, nil
+}
+
 func (g *Gateway) GetConsumer(id string) (*config.Consumer, error) {
+	if g.accessor != nil {
+		return g.accessor.GetConsumer(id)
+	}
 	consumer, ok := g.Consumers[id]
 	if !ok {
 		return nil, fmt.Errorf("Could not find consumer: %s", id)
@@ -74,6 +126,9 @@ func (g *Gateway) GetConsumer(id string) (*config.Consumer, error) {
 }
 
 func (g *Gateway) GetCredential(id string) (interface{}, error) {
+	if g.accessor != nil {
+		return g.accessor.GetCredential(id)
+	}
 	credential, ok := g.Credentials[id]
 	if !ok {
 		return nil, fmt.Errorf("Could not find credential: %s", id)
@@ -82,6 +137,9 @@ func (g *Gateway) GetCredential(id string) (interface{}, error) {
 }
 
 func (g *Gateway) FindCredential(credentialType, clientId string) (interface{}, error) {
+	if g.accessor != nil {
+		return g.accessor.FindCredential(credentialType, clientId)
+	}
 	key := credentialType + "|" + clientId
 	credential, ok := g.CredentialsByClient[key]
 	if !ok {
@@ -91,6 +149,9 @@ func (g *Gateway) FindCredential(credentialType, clientId string) (interface{},
 }
 
 func (g *Gateway) GetPlan(id string) (*config.Plan, error) {
+	if g.accessor != nil {
+		return g.accessor.GetPlan(id)
+	}
 	plan, ok := g.Plans[id]
 	if !ok {
 		return nil, fmt.Errorf("Could not find consumer: %s", id)
@@ -99,6 +160,9 @@ func (g *Gateway) GetPlan(id string) (*config.Plan, error) {
 }
 
 func (g *Gateway) GetPermission(id string) (*config.Permission, error) {
+	if g.accessor != nil {
+		return g.accessor.GetPermission(id)
+	}
 	permission, ok := g.Permissions[id]
 	if !ok {
 		return nil, fmt.Errorf("Could not find consumer: %s", id)
@@ -107,49 +171,51 @@ func (g *Gateway) GetPermission(id string) (*config.Permission, error) {
 }
 
 func LoadGateway(configLocation string) (*Gateway, error) {
-	gatewayConfig := LoadGatewayConfig(configLocation)
-	return ProcessGatewayConfig(gatewayConfig)
-}
-
-func LoadGatewayConfig(configLocation string) *GatewayConfig {
-	var data []byte
-	var err error
-	gatewayConfig := GatewayConfig{}
-
-	if strings.HasPrefix(configLocation, "http://") ||
-		strings.HasPrefix(configLocation, "https://") {
-		resp, err := http.Get(configLocation)
-		if err == nil {
-			defer resp.Body.Close()
-			data, err = ioutil.ReadAll(resp.Body)
-		}
-	} else {
-		data, err = ioutil.ReadFile(configLocation)
-	}
-	if err != nil { // Handle errors reading the config file
-		panic(fmt.Errorf("Fatal error reading config file: %s \n", err))
-	}
-
-	if filepath.Ext(configLocation) == ".json" {
-		err = json.Unmarshal(data, &gatewayConfig)
-	} else {
-		err = yaml.Unmarshal(data, &gatewayConfig)
+	gatewayConfig, err := LoadGatewayConfig(configLocation)
+	if err != nil {
+		return nil, err
 	}
-	if err != nil { // Handle errors reading the config file
-		panic(fmt.Errorf("Fatal error reading config file: %s \n", err))
+	for _, processor := range _configProcessors {
+		err = processor(gatewayConfig)
+		if err != nil {
+			return nil, err
+		}
 	}
-
-	return &gatewayConfig
+	return ProcessGatewayConfig(gatewayConfig)
 }
 
 func ProcessGatewayConfig(gatewayConfig *GatewayConfig) (*Gateway, error) {
 	var gateway Gateway
+	gateway.accessor = _dataAccessor
 	operationCount := 0
 	gateway.Services = gatewayConfig.Services
 
+	// Plugins are processed first so that the other entities
+	// can reference their instances
+	gateway.Plugins = make(map[string]*config.Plugin, len(gatewayConfig.Plugins))
+	gateway.PluginsByID = make(map[string]*config.Plugin, len(gatewayConfig.Plugins))
+	for i := range gatewayConfig.Plugins {
+		plugin := &gatewayConfig.Plugins[i]
+		err := HandlePluginConfig(plugin)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := gateway.Plugins[plugin.Name]; !ok {
+			gateway.Plugins[plugin.Name] = plugin
+		}
+		if plugin.ID != "" {
+			gateway.PluginsByID[plugin.ID] = plugin
+		}
+	}
+
 	for i := 0; i < len(gateway.Services); i++ {
 		service := &gateway.Services[i]
-		err := handleConfigurations(service.Filters)
+		err := HandleConfigurations(&gateway, service.Filters)
+		if err != nil {
+			return nil, err
+		}
+
+		err = HandleAuthenticatorConfigurations(&gateway, service.Authenticators)
 		if err != nil {
 			return nil, err
 		}
@@ -163,7 +229,7 @@ func ProcessGatewayConfig(gatewayConfig *GatewayConfig) (*Gateway, error) {
 
 		for j := 0; j < len(service.Operations); j++ {
 			operation := &service.Operations[j]
-			err := handleConfigurations(operation.Filters)
+			err := HandleConfigurations(&gateway, operation.Filters)
 			if err != nil {
 				return nil, err
 			}
@@ -181,12 +247,13 @@ func ProcessGatewayConfig(gatewayConfig *GatewayConfig) (*Gateway, error) {
 	}
 
 	gateway.Consumers = make(map[string]*config.Consumer, len(gatewayConfig.Consumers))
-	for _, consumer := range gatewayConfig.Consumers {
-		err := handleConfigurations(consumer.Filters)
+	for i := range gatewayConfig.Consumers {
+		consumer := &gatewayConfig.Consumers[i]
+		err := HandleConfigurations(&gateway, consumer.Filters)
 		if err != nil {
 			return nil, err
 		}
-		gateway.Consumers[consumer.ID] = &consumer
+		gateway.Consumers[consumer.ID] = consumer
 	}
 
 	gateway.Credentials = make(map[string]interface{}, len(gatewayConfig.Credentials))
@@ -220,38 +287,19 @@ func ProcessGatewayConfig(gatewayConfig *GatewayConfig) (*Gateway, error) {
 	}
 
 	gateway.Permissions = make(map[string]*config.Permission, len(gatewayConfig.Permissions))
-	for _, permission := range gatewayConfig.Permissions {
-		gateway.Permissions[permission.ID] = &permission
+	for i := range gatewayConfig.Permissions {
+		permission := &gatewayConfig.Permissions[i]
+		gateway.Permissions[permission.ID] = permission
 	}
 
 	gateway.Plans = make(map[string]*config.Plan, len(gatewayConfig.Plans))
-	for _, plan := range gatewayConfig.Plans {
-		err := handleConfigurations(plan.Filters)
+	for i := range gatewayConfig.Plans {
+		plan := &gatewayConfig.Plans[i]
+		err := HandleConfigurations(&gateway, plan.Filters)
 		if err != nil {
 			return nil, err
 		}
-		gateway.Plans[plan.ID] = &plan
-	}
-
-	gateway.Plugins = make(map[string]*config.Plugin, len(gatewayConfig.Plugins))
-	for _, plugin := range gatewayConfig.Plugins {
-		for _, decoder := range _configDecoders {
-			config, err := decoder(plugin.Name, plugin.Properties)
-			if err != nil {
-				return nil, err
-			}
-			if config != nil {
-				plugin.Config = config
-				continue
-			}
-		}
-		if filter.HasFilter(plugin.Name) {
-			err := plugin.HandleConfig(filter.GetConfig)
-			if err != nil {
-				return nil, err
-			}
-		}
-		gateway.Plugins[plugin.Name] = &plugin
+		gateway.Plans[plan.ID] = plan
 	}
 
 	log.WithFields(log.Fields{
@@ -261,7 +309,7 @@ func ProcessGatewayConfig(gatewayConfig *GatewayConfig) (*Gateway, error) {
 		"credentials": len(gateway.Credentials),
 		"permissions": len(gateway.Permissions),
 		"plans":       len(gateway.Plans),
-		"plugins":     len(gateway.Plugins),
+		"plugins":     len(gatewayConfig.Plugins),
 	}).Info("Processed gateway configuration succeeded")
 
 	return &gateway, nil
@@ -280,9 +328,36 @@ func getCredentialField(cred map[string]interface{}, key string) (string, bool)
 	return value, true
 }
 
-func handleConfigurations(configs []config.PluginConfig) error {
+// HandlePluginConfig decodes the configuration of plugin using
+// the registered config decoders or the filter of the same name.
+func HandlePluginConfig(plugin *config.Plugin) error {
+	conf, err := decodePluginConfig(&plugin.PluginConfig)
+	if err != nil {
+		return err
+	}
+	plugin.Config = conf
+	return nil
+}
+
+// HandleConfigurations decodes the configuration of each filter in configs.
+// Plugin instances that are referenced are looked up in instances.
+func HandleConfigurations(instances PluginInstanceAccessor, configs []config.PluginConfig) error {
+	for i := 0; i < len(configs); i++ {
+		err := handleConfiguration(instances, &configs[i], filter.GetConfig)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// HandleAuthenticatorConfigurations decodes the configuration of each
+// authenticator in configs.  Plugin instances that are referenced are
+// looked up in instances.
+func HandleAuthenticatorConfigurations(instances PluginInstanceAccessor, configs []config.PluginConfig) error {
 	for i := 0; i < len(configs); i++ {
-		err := configs[i].HandleConfig(filter.GetConfig)
+		err := handleConfiguration(instances, &configs[i], decodePluginConfig)
 		if err != nil {
 			return err
 		}
@@ -290,3 +365,61 @@ func handleConfigurations(configs []config.PluginConfig) error {
 
 	return nil
 }
+
+// handleConfiguration decodes the configuration of c with decode after
+// applying the plugin instance that it references, if any.
+func handleConfiguration(instances PluginInstanceAccessor, c *config.PluginConfig, decode func(*config.PluginConfig) (interface{}, error)) error {
+	if c.PluginID == nil {
+		return c.HandleConfig(decode)
+	}
+
+	if instances == nil {
+		return fmt.Errorf("Could not find plugin instance: %s", *c.PluginID)
+	}
+	plugin, err := instances.GetPluginInstance(*c.PluginID)
+	if err != nil {
+		return err
+	}
+
+	if c.Name == "" {
+		c.Name = plugin.Name
+	} else if c.Name != plugin.Name {
+		return fmt.Errorf("Plugin instance %s is a %s plugin, not %s", *c.PluginID, plugin.Name, c.Name)
+	}
+
+	properties := make(map[string]interface{}, len(plugin.Properties)+len(c.Properties))
+	for key, value := range plugin.Properties {
+		properties[key] = value
+	}
+	for key, value := range c.Properties {
+		properties[key] = value
+	}
+
+	conf, err := decode(&config.PluginConfig{
+		Name:       c.Name,
+		Properties: properties,
+	})
+	if err != nil {
+		return err
+	}
+	c.Config = conf
+	return nil
+}
+
+func decodePluginConfig(c *config.PluginConfig) (interface{}, error) {
+	var decoded interface{}
+	for _, decoder := range _configDecoders {
+		conf, err := decoder(c.Name, c.Properties)
+		if err != nil {
+			return nil, err
+		}
+		if conf != nil {
+			decoded = conf
+		}
+	}
+	if filter.HasFilter(c.Name) {
+		return filter.GetConfig(c)
+	}
+
+	return decoded, nil
+}
@@ -0,0 +1,303 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type (
+	// SecretProvider resolves references of the form ${scheme:reference}
+	// found in the gateway configuration.  This allows sensitive values such
+	// as a credential's clientSecret to be stored outside of the configuration.
+	SecretProvider interface {
+		Scheme() string
+		Resolve(reference string) (string, error)
+	}
+
+	envSecretProvider  struct{}
+	fileSecretProvider struct{}
+)
+
+var (
+	_secretProviders = map[string]SecretProvider{
+		"env":  envSecretProvider{},
+		"file": fileSecretProvider{},
+	}
+
+	interpolationStart  = []byte("${")
+	interpolationEscape = []byte("$${")
+
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+)
+
+func AddSecretProviders(providers ...SecretProvider) {
+	for _, provider := range providers {
+		_secretProviders[provider.Scheme()] = provider
+	}
+}
+
+// interpolateValues replaces expressions in the string values of tree,
+// a document decoded with json.Number numbers, before it is decoded into
+// target.  Keys and the structure of the document are never changed, so a
+// value cannot introduce new entries.  Values of number and boolean fields
+// of target are converted from the resolved strings.  Values whose type
+// target does not declare, such as plugin properties and credentials,
+// remain strings, so that a secret of digits is not read as a number.
+func interpolateValues(tree interface{}, target reflect.Type) (interface{}, error) {
+	var problems []string
+	tree = interpolateValue(tree, target, "", &problems)
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return nil, fmt.Errorf("%s", strings.Join(problems, ", "))
+	}
+	return tree, nil
+}
+
+func interpolateValue(value interface{}, target reflect.Type, path string, problems *[]string) interface{} {
+	for target != nil && target.Kind() == reflect.Ptr {
+		target = target.Elem()
+	}
+
+	switch value := value.(type) {
+	case map[string]interface{}:
+		var fields map[string]reflect.Type
+		if target != nil && target.Kind() == reflect.Struct {
+			fields = map[string]reflect.Type{}
+			jsonFields(target, fields)
+		}
+		for key, elem := range value {
+			var elemTarget reflect.Type
+			if fields != nil {
+				elemTarget = fields[key]
+			} else if target != nil && target.Kind() == reflect.Map {
+				elemTarget = target.Elem()
+			}
+			value[key] = interpolateValue(elem, elemTarget, joinPath(path, key), problems)
+		}
+	case []interface{}:
+		var elemTarget reflect.Type
+		if target != nil && (target.Kind() == reflect.Slice || target.Kind() == reflect.Array) {
+			elemTarget = target.Elem()
+		}
+		for i, elem := range value {
+			value[i] = interpolateValue(elem, elemTarget, fmt.Sprintf("%s[%d]", path, i), problems)
+		}
+	case json.Number, bool:
+		// As yaml.Unmarshal does for string fields
+		if target != nil && target.Kind() == reflect.String {
+			return fmt.Sprint(value)
+		}
+	case string:
+		if !strings.Contains(value, "${") {
+			return value
+		}
+		interpolated, err := interpolate(value)
+		if err != nil {
+			*problems = append(*problems, fmt.Sprintf("%s: %s", path, err))
+			return value
+		}
+		converted, err := convertInterpolated(interpolated, target)
+		if err != nil {
+			*problems = append(*problems, fmt.Sprintf("%s: %s", path, err))
+			return value
+		}
+		return converted
+	}
+
+	return value
+}
+
+// convertInterpolated converts the interpolated value to the kind of
+// target.  Types that decode themselves are given the string.
+func convertInterpolated(value string, target reflect.Type) (interface{}, error) {
+	if target == nil || reflect.PtrTo(target).Implements(jsonUnmarshalerType) {
+		return value, nil
+	}
+
+	switch target.Kind() {
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a boolean", value)
+		}
+		return b, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		number, ok := jsonNumber(value)
+		if !ok {
+			return nil, fmt.Errorf("%q is not a number", value)
+		}
+		return number, nil
+	}
+	return value, nil
+}
+
+func jsonNumber(value string) (json.Number, bool) {
+	if _, err := strconv.ParseFloat(value, 64); err != nil || !json.Valid([]byte(value)) {
+		return "", false
+	}
+	return json.Number(value), true
+}
+
+// jsonFields adds the types of the fields of the struct type t by their
+// JSON names, including those of embedded structs.
+func jsonFields(t reflect.Type, fields map[string]reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" && field.Anonymous {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				jsonFields(embedded, fields)
+			}
+			continue
+		}
+		if field.PkgPath != "" || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = field.Type
+	}
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// interpolate replaces the following expressions in value:
+//
+//	${NAME}               the value of the environment variable NAME
+//	${NAME:-default}      the value of NAME, or default if it is unset or empty
+//	${file:/path}         the contents of the file at /path
+//	${scheme:reference}   the value resolved by the SecretProvider for scheme
+//
+// $${ is written out as a literal ${.
+func interpolate(value string) (string, error) {
+	if !strings.Contains(value, "${") {
+		return value, nil
+	}
+
+	var buffer bytes.Buffer
+	var problems []string
+	data := []byte(value)
+	buffer.Grow(len(data))
+
+	for len(data) > 0 {
+		index := bytes.Index(data, interpolationStart)
+		if index == -1 {
+			buffer.Write(data)
+			break
+		}
+
+		// Escaped expression
+		if index > 0 && bytes.HasPrefix(data[index-1:], interpolationEscape) {
+			buffer.Write(data[:index-1])
+			buffer.Write(interpolationStart)
+			data = data[index+len(interpolationStart):]
+			continue
+		}
+
+		buffer.Write(data[:index])
+		data = data[index+len(interpolationStart):]
+
+		end := bytes.IndexByte(data, '}')
+		if end == -1 {
+			problems = append(problems, "unterminated ${ expression")
+			break
+		}
+
+		expression := string(data[:end])
+		data = data[end+1:]
+
+		resolved, err := resolveExpression(expression)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		buffer.WriteString(resolved)
+	}
+
+	if len(problems) > 0 {
+		return "", fmt.Errorf("%s", strings.Join(problems, ", "))
+	}
+
+	return buffer.String(), nil
+}
+
+func resolveExpression(expression string) (string, error) {
+	index := strings.IndexByte(expression, ':')
+
+	// ${NAME}
+	if index == -1 {
+		value, ok := os.LookupEnv(expression)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", expression)
+		}
+		return value, nil
+	}
+
+	// ${NAME:-default}
+	if strings.HasPrefix(expression[index:], ":-") {
+		value := os.Getenv(expression[:index])
+		if value == "" {
+			value = expression[index+2:]
+		}
+		return value, nil
+	}
+
+	// ${scheme:reference}
+	scheme := expression[:index]
+	provider, ok := _secretProviders[scheme]
+	if !ok {
+		return "", fmt.Errorf("unknown secret provider %q", scheme)
+	}
+
+	value, err := provider.Resolve(expression[index+1:])
+	if err != nil {
+		return "", fmt.Errorf("could not resolve ${%s}: %s", expression, err)
+	}
+
+	return value, nil
+}
+
+func (envSecretProvider) Scheme() string {
+	return "env"
+}
+
+func (envSecretProvider) Resolve(reference string) (string, error) {
+	value, ok := os.LookupEnv(reference)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", reference)
+	}
+	return value, nil
+}
+
+func (fileSecretProvider) Scheme() string {
+	return "file"
+}
+
+func (fileSecretProvider) Resolve(reference string) (string, error) {
+	data, err := ioutil.ReadFile(reference)
+	if err != nil {
+		return "", err
+	}
+
+	// Secrets are commonly written with a trailing newline
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
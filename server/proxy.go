@@ -0,0 +1,88 @@
+package server
+
+import (
+	"net"
+	"strings"
+
+	"github.com/prizem-io/gateway/utils"
+)
+
+var trustedProxies []*net.IPNet
+
+// SetTrustedProxies sets the CIDR ranges of the reverse proxies in front of
+// the gateway.  The X-Forwarded-For, Forwarded and X-Real-Ip headers are
+// only honored for requests from these ranges.
+func SetTrustedProxies(cidrs []string) error {
+	networks, err := utils.ParseNetworks(cidrs)
+	if err != nil {
+		return err
+	}
+
+	trustedProxies = networks
+	return nil
+}
+
+// IsTrustedProxy reports whether ip is in a trusted proxy range.
+func IsTrustedProxy(ip net.IP) bool {
+	return ip != nil && utils.ContainsIP(trustedProxies, ip)
+}
+
+// ClientIP returns the address of the client of a request from peer.  If
+// peer is a trusted proxy, the addresses that it forwarded for are walked
+// from the nearest, and the first that is not a trusted proxy is the
+// client.  forwarded is the Forwarded header, which is preferred, and
+// forwardedFor the X-Forwarded-For header.
+func ClientIP(peer, forwarded, forwardedFor string) string {
+	if !IsTrustedProxy(net.ParseIP(peer)) {
+		return peer
+	}
+
+	var hops []string
+	if forwarded != "" {
+		hops = forwardedFors(forwarded)
+	} else if forwardedFor != "" {
+		for _, hop := range strings.Split(forwardedFor, ",") {
+			hops = append(hops, strings.TrimSpace(hop))
+		}
+	}
+
+	client := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(hops[i])
+		if ip == nil {
+			// Obfuscated or unknown addresses end the trusted chain
+			break
+		}
+		client = ip.String()
+		if !IsTrustedProxy(ip) {
+			break
+		}
+	}
+	return client
+}
+
+// forwardedFors returns the "for" addresses of a Forwarded header, as in
+// `for=192.0.2.60;proto=http, for="[2001:db8:cafe::17]:4711"`, without
+// quotes and ports.
+func forwardedFors(forwarded string) []string {
+	var hops []string
+	for _, element := range strings.Split(forwarded, ",") {
+		for _, pair := range strings.Split(element, ";") {
+			pair = strings.TrimSpace(pair)
+			if len(pair) < 4 || !strings.EqualFold(pair[:4], "for=") {
+				continue
+			}
+
+			value := strings.Trim(pair[4:], `"`)
+			if strings.HasPrefix(value, "[") {
+				if end := strings.IndexByte(value, ']'); end != -1 {
+					value = value[1:end]
+				}
+			} else if host, _, err := net.SplitHostPort(value); err == nil {
+				value = host
+			}
+			hops = append(hops, value)
+		}
+	}
+	return hops
+}
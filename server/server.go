@@ -16,6 +16,7 @@ var (
 	processingHandlers = []ProcessingHandler{}
 	successHandlers    = []PostProcessingHandler{}
 	errorHandlers      = []PostProcessingHandler{}
+	preflightHandler   ProcessingHandler
 )
 
 func Initialize(config config.Configuration) {
@@ -34,17 +35,34 @@ func SetErrorHandlers(_handlers ...PostProcessingHandler) {
 	errorHandlers = _handlers
 }
 
+// AddSuccessHandlers adds handlers to those set by SetSuccessHandlers.
+func AddSuccessHandlers(_handlers ...PostProcessingHandler) {
+	successHandlers = append(successHandlers, _handlers...)
+}
+
+// AddErrorHandlers adds handlers to those set by SetErrorHandlers.
+func AddErrorHandlers(_handlers ...PostProcessingHandler) {
+	errorHandlers = append(errorHandlers, _handlers...)
+}
+
+// SetPreflightHandler sets the handler of CORS preflight requests, which
+// are OPTIONS requests to paths that have no OPTIONS operation.  Such
+// requests are not allowed if it is nil.
+func SetPreflightHandler(handler ProcessingHandler) {
+	preflightHandler = handler
+}
+
+// PreflightEnabled reports whether a preflight handler is set.
+func PreflightEnabled() bool {
+	return preflightHandler != nil
+}
+
 func Serve(ctx context.Context) {
 	err := invokeProcessingHandlers(ctx, processingHandlers)
 
 	// Send error payload
 	if err != nil {
-		if apiErr, found := err.(*ef.APIError); found {
-			ctx.Rs().SetStatusCode(apiErr.Status)
-		} else {
-			ctx.Rs().SetStatusCode(500)
-		}
-		ctx.SendEntity(err)
+		sendError(ctx, err)
 	}
 
 	// Invoke post processing hanlders, if available
@@ -55,6 +73,29 @@ func Serve(ctx context.Context) {
 	}
 }
 
+// ServePreflight answers a CORS preflight request for the operation of
+// ctx, which is the operation of the method that the request precedes.
+func ServePreflight(ctx context.Context) {
+	if preflightHandler == nil {
+		sendError(ctx, ef.New(ctx, "methodNotAllowed"))
+		return
+	}
+
+	err := preflightHandler(ctx)
+	if err != nil {
+		sendError(ctx, err)
+	}
+}
+
+func sendError(ctx context.Context, err error) {
+	if apiErr, found := err.(*ef.APIError); found {
+		ctx.Rs().SetStatusCode(apiErr.Status)
+	} else {
+		ctx.Rs().SetStatusCode(500)
+	}
+	ctx.SendEntity(err)
+}
+
 func invokeProcessingHandlers(ctx context.Context, handlers []ProcessingHandler) error {
 	for _, handler := range handlers {
 		err := handler(ctx)
@@ -0,0 +1,305 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/fsnotify/fsnotify"
+)
+
+type (
+	// ConfigWatcher observes a gateway configuration location, including any
+	// files it includes, and invokes a callback whenever its contents change.
+	// Local files are watched using filesystem notifications (falling back to
+	// polling if unavailable) and HTTP(S) locations are polled using
+	// conditional requests.
+	ConfigWatcher struct {
+		location string
+		interval time.Duration
+		debounce time.Duration
+		onChange func()
+
+		mu           sync.Mutex
+		timer        *time.Timer
+		stop         chan struct{}
+		refresh      chan struct{}
+		once         sync.Once
+		etag         string
+		lastModified string
+		digest       string
+		sources      map[string]bool
+		fingerprint  string
+	}
+)
+
+const (
+	defaultWatchInterval = 10 * time.Second
+	defaultWatchDebounce = 500 * time.Millisecond
+)
+
+// WatchGatewayConfig starts watching configLocation.  onChange is called,
+// at most once per debounce period, after the configuration has changed.
+func WatchGatewayConfig(configLocation string, interval, debounce time.Duration, onChange func()) (*ConfigWatcher, error) {
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+	if debounce <= 0 {
+		debounce = defaultWatchDebounce
+	}
+
+	w := &ConfigWatcher{
+		location: configLocation,
+		interval: interval,
+		debounce: debounce,
+		onChange: onChange,
+		stop:     make(chan struct{}),
+		refresh:  make(chan struct{}, 1),
+	}
+
+	if isRemoteLocation(configLocation) {
+		// Prime the validators so the first poll does not trigger a reload
+		w.pollRemote()
+		go w.poll(w.pollRemote)
+		return w, nil
+	}
+
+	w.fingerprint = w.statLocal()
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.WithFields(log.Fields{
+			"location": configLocation,
+		}).Warn("Could not create filesystem watcher, falling back to polling: " + err.Error())
+		go w.poll(w.pollLocal)
+		return w, nil
+	}
+
+	err = w.addDirectories(watcher)
+	if err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	go w.watchLocal(watcher)
+	return w, nil
+}
+
+// Close stops watching the configuration location.
+func (w *ConfigWatcher) Close() {
+	w.once.Do(func() {
+		close(w.stop)
+		w.mu.Lock()
+		if w.timer != nil {
+			w.timer.Stop()
+		}
+		w.mu.Unlock()
+	})
+}
+
+// addDirectories watches the parent directory of every configuration source
+// so that editors and deployment tools that replace files atomically, as well
+// as new files added to a configuration directory, are still detected.
+func (w *ConfigWatcher) addDirectories(watcher *fsnotify.Watcher) error {
+	directories := map[string]bool{
+		w.locationDirectory(): true,
+	}
+	for source := range w.sources {
+		directories[filepath.Dir(source)] = true
+	}
+
+	for directory := range directories {
+		err := watcher.Add(directory)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *ConfigWatcher) watchLocal(watcher *fsnotify.Watcher) {
+	defer watcher.Close()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-w.refresh:
+			// Includes may have changed so pick up any new directories
+			w.statLocal()
+			err := w.addDirectories(watcher)
+			if err != nil {
+				log.WithFields(log.Fields{
+					"location": w.location,
+				}).Warn("Error watching gateway configuration: " + err.Error())
+			}
+		case event := <-watcher.Events:
+			if !w.isSource(event.Name) {
+				continue
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename|fsnotify.Remove) != 0 {
+				w.changed()
+			}
+		case err := <-watcher.Errors:
+			log.WithFields(log.Fields{
+				"location": w.location,
+			}).Warn("Error watching gateway configuration: " + err.Error())
+		}
+	}
+}
+
+// isSource reports whether name is, or could become, part of the configuration.
+func (w *ConfigWatcher) isSource(name string) bool {
+	name = filepath.Clean(name)
+	if w.sources[name] {
+		return true
+	}
+
+	if strings.ContainsAny(w.location, "*?[") {
+		matched, _ := filepath.Match(w.location, name)
+		return matched
+	}
+
+	return filepath.Dir(name) == filepath.Clean(w.location) && isConfigFile(name)
+}
+
+func (w *ConfigWatcher) locationDirectory() string {
+	if strings.ContainsAny(w.location, "*?[") {
+		return filepath.Dir(w.location)
+	}
+
+	info, err := os.Stat(w.location)
+	if err == nil && info.IsDir() {
+		return w.location
+	}
+
+	return filepath.Dir(w.location)
+}
+
+func (w *ConfigWatcher) poll(check func() bool) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			if check() {
+				w.changed()
+			}
+		}
+	}
+}
+
+// statLocal refreshes the set of configuration sources and returns
+// a fingerprint of their modification times and sizes.
+func (w *ConfigWatcher) statLocal() string {
+	sources, _ := GatewayConfigSources(w.location)
+	w.sources = make(map[string]bool, len(sources))
+
+	var buffer bytes.Buffer
+	for _, source := range sources {
+		w.sources[filepath.Clean(source)] = true
+
+		info, err := os.Stat(source)
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(&buffer, "%s|%d|%d\n", source, info.ModTime().UnixNano(), info.Size())
+	}
+
+	return buffer.String()
+}
+
+func (w *ConfigWatcher) pollLocal() bool {
+	fingerprint := w.statLocal()
+	changed := fingerprint != w.fingerprint
+	w.fingerprint = fingerprint
+	return changed
+}
+
+func (w *ConfigWatcher) pollRemote() bool {
+	req, err := http.NewRequest("GET", w.location, nil)
+	if err != nil {
+		return false
+	}
+	if w.etag != "" {
+		req.Header.Set("If-None-Match", w.etag)
+	}
+	if w.lastModified != "" {
+		req.Header.Set("If-Modified-Since", w.lastModified)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"location": w.location,
+		}).Warn("Error polling gateway configuration: " + err.Error())
+		return false
+	}
+	defer resp.Body.Close()
+
+	// 304 Not Modified or an error response
+	if resp.StatusCode != http.StatusOK {
+		return false
+	}
+
+	// Compare a digest of the content in case the server
+	// does not honor the conditional request headers
+	hash := sha256.New()
+	_, err = io.Copy(hash, resp.Body)
+	if err != nil {
+		return false
+	}
+	digest := hex.EncodeToString(hash.Sum(nil))
+
+	primed := w.digest != ""
+	changed := digest != w.digest
+	w.etag = resp.Header.Get("ETag")
+	w.lastModified = resp.Header.Get("Last-Modified")
+	w.digest = digest
+
+	return primed && changed
+}
+
+// changed debounces bursts of change notifications into a single callback.
+func (w *ConfigWatcher) changed() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	w.timer = time.AfterFunc(w.debounce, func() {
+		select {
+		case <-w.stop:
+			return
+		default:
+		}
+
+		log.WithFields(log.Fields{
+			"location": w.location,
+		}).Info("Gateway configuration changed")
+		w.onChange()
+
+		select {
+		case w.refresh <- struct{}{}:
+		default:
+		}
+	})
+}
+
+func isRemoteLocation(location string) bool {
+	return strings.HasPrefix(location, "http://") ||
+		strings.HasPrefix(location, "https://")
+}
@@ -0,0 +1,143 @@
+package store
+
+import (
+	"fmt"
+
+	"github.com/prizem-io/gateway/config"
+	"github.com/prizem-io/gateway/context"
+	"github.com/prizem-io/gateway/server"
+)
+
+type (
+	// NotFoundError is returned by the DataAccessor for entities that are
+	// not in the store.  Its message matches the errors returned by
+	// server.Gateway for missing entities.
+	NotFoundError struct {
+		EntityName string
+		ID         string
+	}
+
+	// dataAccessor serves context.DataAccessor lookups from a Store and
+	// decodes filter and plugin configurations as the gateway does when
+	// processing its configuration.
+	dataAccessor struct {
+		store Store
+	}
+)
+
+// NewDataAccessor returns a context.DataAccessor backed by s.
+// It is intended to be passed to server.SetDataAccessor.
+func NewDataAccessor(s Store) context.DataAccessor {
+	return &dataAccessor{
+		store: s,
+	}
+}
+
+func (a *dataAccessor) GetPlugin(name string) (*config.Plugin, error) {
+	plugin, err := a.store.Plugins().FindPlugin(name)
+	if err != nil {
+		return nil, lookupError("plugin", name, err)
+	}
+
+	err = server.HandlePluginConfig(plugin)
+	if err != nil {
+		return nil, err
+	}
+
+	return plugin, nil
+}
+
+func (a *dataAccessor) GetPluginInstance(id string) (*config.Plugin, error) {
+	plugin, err := a.store.Plugins().GetPlugin(id)
+	if err != nil {
+		return nil, lookupError("plugin instance", id, err)
+	}
+
+	err = server.HandlePluginConfig(plugin)
+	if err != nil {
+		return nil, err
+	}
+
+	return plugin, nil
+}
+
+func (a *dataAccessor) GetConsumer(id string) (*config.Consumer, error) {
+	consumer, err := a.store.Consumers().GetConsumer(id)
+	if err != nil {
+		return nil, lookupError("consumer", id, err)
+	}
+
+	err = server.HandleConfigurations(a, consumer.Filters)
+	if err != nil {
+		return nil, err
+	}
+
+	return consumer, nil
+}
+
+func (a *dataAccessor) GetCredential(id string) (interface{}, error) {
+	credential, err := a.store.Credentials().GetCredential(id)
+	if err != nil {
+		return nil, lookupError("credential", id, err)
+	}
+
+	return decodeCredential(credential)
+}
+
+func (a *dataAccessor) FindCredential(credentialType, clientID string) (interface{}, error) {
+	credential, err := a.store.Credentials().FindCredential(credentialType, clientID)
+	if err != nil {
+		return nil, lookupError("credential", credentialType+": "+clientID, err)
+	}
+
+	return decodeCredential(credential)
+}
+
+func (a *dataAccessor) GetPlan(id string) (*config.Plan, error) {
+	plan, err := a.store.Plans().GetPlan(id)
+	if err != nil {
+		return nil, lookupError("plan", id, err)
+	}
+
+	err = server.HandleConfigurations(a, plan.Filters)
+	if err != nil {
+		return nil, err
+	}
+
+	return plan, nil
+}
+
+func (a *dataAccessor) GetPermission(id string) (*config.Permission, error) {
+	permission, err := a.store.Permissions().GetPermission(id)
+	if err != nil {
+		return nil, lookupError("permission", id, err)
+	}
+
+	return permission, nil
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("Could not find %s: %s", e.EntityName, e.ID)
+}
+
+func decodeCredential(credential map[string]interface{}) (interface{}, error) {
+	credentialType := CredentialType(credential)
+	decoder, ok := server.GetCredentialDecoder(credentialType)
+	if !ok {
+		return nil, fmt.Errorf("Unknown credential type: %s", credentialType)
+	}
+
+	decoded, _, err := decoder.DecodeCredential(credential)
+	return decoded, err
+}
+
+// lookupError converts ErrNotFound into a NotFoundError.
+func lookupError(entityName, id string, err error) error {
+	if err == ErrNotFound {
+		return &NotFoundError{
+			EntityName: entityName,
+			ID:         id,
+		}
+	}
+	return err
+}
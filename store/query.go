@@ -0,0 +1,69 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Query selects the entities that a List method returns: those that
+// match every filter, ordered by ID, starting at Offset.  A Limit of 0
+// or less selects every match.
+type Query struct {
+	Offset int
+	Limit  int
+	// Filters map JSON field names to values, any of which may match the
+	// field case-insensitively.  Array fields match if any item matches.
+	Filters map[string][]string
+}
+
+// Selects reports whether the match at index is within the page of q.
+func (q Query) Selects(index int) bool {
+	return index >= q.Offset && (q.Limit <= 0 || index < q.Offset+q.Limit)
+}
+
+// Matches reports whether entity satisfies every filter.
+func Matches(entity interface{}, filters map[string][]string) bool {
+	if len(filters) == 0 {
+		return true
+	}
+
+	data, err := json.Marshal(entity)
+	if err != nil {
+		return false
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return false
+	}
+
+	for name, values := range filters {
+		if !matchesField(fields[name], values) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func matchesField(field interface{}, values []string) bool {
+	switch f := field.(type) {
+	case nil:
+		return false
+	case []interface{}:
+		for _, item := range f {
+			if matchesField(item, values) {
+				return true
+			}
+		}
+		return false
+	default:
+		str := fmt.Sprint(f)
+		for _, value := range values {
+			if strings.EqualFold(str, value) {
+				return true
+			}
+		}
+		return false
+	}
+}
@@ -0,0 +1,532 @@
+package redis
+
+import (
+	"bytes"
+
+	"github.com/go-redis/redis"
+	"gopkg.in/vmihailenco/msgpack.v2"
+
+	"github.com/prizem-io/gateway/config"
+	"github.com/prizem-io/gateway/store"
+)
+
+type (
+	redisClient interface {
+		Get(key string) *redis.StringCmd
+		ZRange(key string, start, stop int64) *redis.StringSliceCmd
+		ZCard(key string) *redis.IntCmd
+		HGet(key, field string) *redis.StringCmd
+		TxPipelined(fn func(redis.Pipeliner) error) ([]redis.Cmder, error)
+	}
+
+	// RedisStore persists entities as msgpack.  Each entity is stored under
+	// <prefix><collection>:<id> and its ID is added to a sorted set named
+	// <prefix><collection> so that entities can be listed in ID order.
+	// Secondary lookups use a hash named <prefix><collection>.index that
+	// maps a key to an ID.  An entity and its keys are written in a single
+	// MULTI transaction.
+	RedisStore struct {
+		redis  redisClient
+		prefix string
+	}
+
+	consumerRepository   struct{ *RedisStore }
+	credentialRepository struct{ *RedisStore }
+	permissionRepository struct{ *RedisStore }
+	planRepository       struct{ *RedisStore }
+	pluginRepository     struct{ *RedisStore }
+	serviceRepository    struct{ *RedisStore }
+)
+
+const (
+	DefaultPrefix = "prizem:"
+
+	// listBatchSize is the number of IDs read at a time while filtering
+	listBatchSize = 500
+)
+
+func New(redis redisClient, prefix string) *RedisStore {
+	return &RedisStore{
+		redis:  redis,
+		prefix: prefix,
+	}
+}
+
+func (s *RedisStore) Consumers() store.ConsumerRepository {
+	return consumerRepository{s}
+}
+
+func (s *RedisStore) Credentials() store.CredentialRepository {
+	return credentialRepository{s}
+}
+
+func (s *RedisStore) Permissions() store.PermissionRepository {
+	return permissionRepository{s}
+}
+
+func (s *RedisStore) Plans() store.PlanRepository {
+	return planRepository{s}
+}
+
+func (s *RedisStore) Plugins() store.PluginRepository {
+	return pluginRepository{s}
+}
+
+func (s *RedisStore) Services() store.ServiceRepository {
+	return serviceRepository{s}
+}
+
+// Consumers
+
+func (r consumerRepository) GetConsumer(id string) (*config.Consumer, error) {
+	var consumer config.Consumer
+	err := r.get("consumers", id, &consumer)
+	if err != nil {
+		return nil, err
+	}
+	return &consumer, nil
+}
+
+func (r consumerRepository) ListConsumers(query store.Query) ([]*config.Consumer, int, error) {
+	consumers := []*config.Consumer{}
+	total, err := r.list("consumers", query, func(id string) (interface{}, error) {
+		return r.GetConsumer(id)
+	}, func(entity interface{}) {
+		consumers = append(consumers, entity.(*config.Consumer))
+	})
+	return consumers, total, err
+}
+
+func (r consumerRepository) SaveConsumer(consumer *config.Consumer) error {
+	return r.save("consumers", consumer.ID, consumer, nil)
+}
+
+func (r consumerRepository) DeleteConsumer(id string) error {
+	return r.delete("consumers", id, nil)
+}
+
+// Credentials
+
+func (r credentialRepository) GetCredential(id string) (map[string]interface{}, error) {
+	data, err := r.redis.Get(r.key("credentials", id)).Bytes()
+	if err == redis.Nil {
+		return nil, store.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeMap(data)
+}
+
+func (r credentialRepository) FindCredential(credentialType, clientID string) (map[string]interface{}, error) {
+	id, err := r.lookup("credentials", credentialType+"|"+clientID)
+	if err != nil {
+		return nil, err
+	}
+	return r.GetCredential(id)
+}
+
+func (r credentialRepository) ListCredentials(query store.Query) ([]map[string]interface{}, int, error) {
+	credentials := []map[string]interface{}{}
+	total, err := r.list("credentials", query, func(id string) (interface{}, error) {
+		return r.GetCredential(id)
+	}, func(entity interface{}) {
+		credentials = append(credentials, entity.(map[string]interface{}))
+	})
+	return credentials, total, err
+}
+
+func (r credentialRepository) SaveCredential(clientID string, credential map[string]interface{}) error {
+	id := store.CredentialID(credential)
+	client := store.CredentialType(credential) + "|" + clientID
+
+	previous, err := r.redis.HGet(r.prefix+"credentials.clients", id).Result()
+	if err != nil && err != redis.Nil {
+		return err
+	}
+
+	return r.save("credentials", id, credential, func(pipe redis.Pipeliner) {
+		if previous != "" && previous != client {
+			r.unindex(pipe, "credentials", previous)
+		}
+		// The client is recorded so that the index can be cleaned up later
+		pipe.HSet(r.prefix+"credentials.clients", id, client)
+		r.index(pipe, "credentials", client, id)
+	})
+}
+
+func (r credentialRepository) DeleteCredential(id string) error {
+	client, err := r.redis.HGet(r.prefix+"credentials.clients", id).Result()
+	if err != nil && err != redis.Nil {
+		return err
+	}
+
+	return r.delete("credentials", id, func(pipe redis.Pipeliner) {
+		if client != "" {
+			r.unindex(pipe, "credentials", client)
+		}
+		pipe.HDel(r.prefix+"credentials.clients", id)
+	})
+}
+
+// Permissions
+
+func (r permissionRepository) GetPermission(id string) (*config.Permission, error) {
+	var permission config.Permission
+	err := r.get("permissions", id, &permission)
+	if err != nil {
+		return nil, err
+	}
+	return &permission, nil
+}
+
+func (r permissionRepository) ListPermissions(query store.Query) ([]*config.Permission, int, error) {
+	permissions := []*config.Permission{}
+	total, err := r.list("permissions", query, func(id string) (interface{}, error) {
+		return r.GetPermission(id)
+	}, func(entity interface{}) {
+		permissions = append(permissions, entity.(*config.Permission))
+	})
+	return permissions, total, err
+}
+
+func (r permissionRepository) SavePermission(permission *config.Permission) error {
+	return r.save("permissions", permission.ID, permission, nil)
+}
+
+func (r permissionRepository) DeletePermission(id string) error {
+	return r.delete("permissions", id, nil)
+}
+
+// Plans
+
+func (r planRepository) GetPlan(id string) (*config.Plan, error) {
+	var plan config.Plan
+	err := r.get("plans", id, &plan)
+	if err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
+func (r planRepository) ListPlans(query store.Query) ([]*config.Plan, int, error) {
+	plans := []*config.Plan{}
+	total, err := r.list("plans", query, func(id string) (interface{}, error) {
+		return r.GetPlan(id)
+	}, func(entity interface{}) {
+		plans = append(plans, entity.(*config.Plan))
+	})
+	return plans, total, err
+}
+
+func (r planRepository) SavePlan(plan *config.Plan) error {
+	return r.save("plans", plan.ID, plan, nil)
+}
+
+func (r planRepository) DeletePlan(id string) error {
+	return r.delete("plans", id, nil)
+}
+
+// Plugins
+
+func (r pluginRepository) GetPlugin(id string) (*config.Plugin, error) {
+	var plugin config.Plugin
+	err := r.get("plugins", id, &plugin)
+	if err != nil {
+		return nil, err
+	}
+	return &plugin, nil
+}
+
+func (r pluginRepository) FindPlugin(name string) (*config.Plugin, error) {
+	id, err := r.lookup("plugins", name)
+	if err != nil {
+		return nil, err
+	}
+	return r.GetPlugin(id)
+}
+
+func (r pluginRepository) ListPlugins(query store.Query) ([]*config.Plugin, int, error) {
+	plugins := []*config.Plugin{}
+	total, err := r.list("plugins", query, func(id string) (interface{}, error) {
+		return r.GetPlugin(id)
+	}, func(entity interface{}) {
+		plugins = append(plugins, entity.(*config.Plugin))
+	})
+	return plugins, total, err
+}
+
+func (r pluginRepository) SavePlugin(plugin *config.Plugin) error {
+	existing, err := r.GetPlugin(plugin.ID)
+	if err != nil && err != store.ErrNotFound {
+		return err
+	}
+
+	instances, err := r.instances(plugin.Name, plugin.ID)
+	if err != nil {
+		return err
+	}
+	instances = append(instances, plugin)
+
+	var previous []*config.Plugin
+	renamed := existing != nil && existing.Name != plugin.Name
+	if renamed {
+		previous, err = r.instances(existing.Name, plugin.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return r.save("plugins", plugin.ID, plugin, func(pipe redis.Pipeliner) {
+		if renamed {
+			r.indexDefault(pipe, existing.Name, previous)
+		}
+		r.indexDefault(pipe, plugin.Name, instances)
+	})
+}
+
+func (r pluginRepository) DeletePlugin(id string) error {
+	plugin, err := r.GetPlugin(id)
+	if err != nil {
+		return err
+	}
+
+	remaining, err := r.instances(plugin.Name, id)
+	if err != nil {
+		return err
+	}
+
+	return r.delete("plugins", id, func(pipe redis.Pipeliner) {
+		r.indexDefault(pipe, plugin.Name, remaining)
+	})
+}
+
+// instances returns the stored instances of the plugin named name, other
+// than the one with the ID except.
+func (r pluginRepository) instances(name, except string) ([]*config.Plugin, error) {
+	plugins := []*config.Plugin{}
+	_, err := r.list("plugins", store.Query{
+		Filters: map[string][]string{"name": {name}},
+	}, func(id string) (interface{}, error) {
+		return r.GetPlugin(id)
+	}, func(entity interface{}) {
+		plugin := entity.(*config.Plugin)
+		// Filters match case-insensitively
+		if plugin.Name == name && plugin.ID != except {
+			plugins = append(plugins, plugin)
+		}
+	})
+	return plugins, err
+}
+
+// indexDefault points the index entry of name at the default instance of
+// plugins, or removes it if there are none.
+func (r pluginRepository) indexDefault(pipe redis.Pipeliner, name string, plugins []*config.Plugin) {
+	if len(plugins) == 0 {
+		r.unindex(pipe, "plugins", name)
+		return
+	}
+	r.index(pipe, "plugins", name, store.DefaultPlugin(plugins).ID)
+}
+
+// Services
+
+func (r serviceRepository) GetService(id string) (*config.Service, error) {
+	var service config.Service
+	err := r.get("services", id, &service)
+	if err != nil {
+		return nil, err
+	}
+	return &service, nil
+}
+
+func (r serviceRepository) ListServices(query store.Query) ([]*config.Service, int, error) {
+	services := []*config.Service{}
+	total, err := r.list("services", query, func(id string) (interface{}, error) {
+		return r.GetService(id)
+	}, func(entity interface{}) {
+		services = append(services, entity.(*config.Service))
+	})
+	return services, total, err
+}
+
+func (r serviceRepository) SaveService(service *config.Service) error {
+	return r.save("services", service.ID, service, nil)
+}
+
+func (r serviceRepository) DeleteService(id string) error {
+	return r.delete("services", id, nil)
+}
+
+func (s *RedisStore) key(collection, id string) string {
+	return s.prefix + collection + ":" + id
+}
+
+func (s *RedisStore) get(collection, id string, entity interface{}) error {
+	data, err := s.redis.Get(s.key(collection, id)).Bytes()
+	if err == redis.Nil {
+		return store.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	return msgpack.Unmarshal(data, entity)
+}
+
+// list passes the entities of collection that query selects to add and
+// returns the number that match its filters.  Without filters, only the
+// page is read; otherwise the collection is read in batches and each
+// entity is matched.
+func (s *RedisStore) list(collection string, query store.Query, load func(id string) (interface{}, error), add func(entity interface{})) (int, error) {
+	if len(query.Filters) == 0 {
+		total, err := s.redis.ZCard(s.prefix + collection).Result()
+		if err != nil {
+			return 0, err
+		}
+
+		stop := int64(-1)
+		if query.Limit > 0 {
+			stop = int64(query.Offset + query.Limit - 1)
+		}
+		ids, err := s.redis.ZRange(s.prefix+collection, int64(query.Offset), stop).Result()
+		if err != nil {
+			return 0, err
+		}
+
+		for _, id := range ids {
+			entity, err := load(id)
+			// The entity may have been deleted since the IDs were read
+			if err == store.ErrNotFound {
+				continue
+			}
+			if err != nil {
+				return 0, err
+			}
+			add(entity)
+		}
+
+		return int(total), nil
+	}
+
+	total := 0
+	for start := int64(0); ; start += listBatchSize {
+		ids, err := s.redis.ZRange(s.prefix+collection, start, start+listBatchSize-1).Result()
+		if err != nil {
+			return 0, err
+		}
+
+		for _, id := range ids {
+			entity, err := load(id)
+			if err == store.ErrNotFound {
+				continue
+			}
+			if err != nil {
+				return 0, err
+			}
+			if !store.Matches(entity, query.Filters) {
+				continue
+			}
+			if query.Selects(total) {
+				add(entity)
+			}
+			total++
+		}
+
+		if len(ids) < listBatchSize {
+			return total, nil
+		}
+	}
+}
+
+// save writes entity and adds it to the set of collection.  Any index
+// changes that indexes queues on pipe are made in the same transaction.
+func (s *RedisStore) save(collection, id string, entity interface{}, indexes func(pipe redis.Pipeliner)) error {
+	data, err := msgpack.Marshal(entity)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.redis.TxPipelined(func(pipe redis.Pipeliner) error {
+		pipe.Set(s.key(collection, id), data, 0)
+		// Equal scores order the set lexicographically by ID
+		pipe.ZAdd(s.prefix+collection, redis.Z{Score: 0, Member: id})
+		if indexes != nil {
+			indexes(pipe)
+		}
+		return nil
+	})
+	return err
+}
+
+// delete removes the entity with id and its index entries in a single
+// transaction.
+func (s *RedisStore) delete(collection, id string, indexes func(pipe redis.Pipeliner)) error {
+	var deleted *redis.IntCmd
+	_, err := s.redis.TxPipelined(func(pipe redis.Pipeliner) error {
+		deleted = pipe.Del(s.key(collection, id))
+		pipe.ZRem(s.prefix+collection, id)
+		if indexes != nil {
+			indexes(pipe)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if deleted.Val() == 0 {
+		return store.ErrNotFound
+	}
+
+	return nil
+}
+
+func (s *RedisStore) index(pipe redis.Pipeliner, collection, lookup, id string) {
+	pipe.HSet(s.prefix+collection+".index", lookup, id)
+}
+
+func (s *RedisStore) unindex(pipe redis.Pipeliner, collection, lookup string) {
+	pipe.HDel(s.prefix+collection+".index", lookup)
+}
+
+func (s *RedisStore) lookup(collection, lookup string) (string, error) {
+	id, err := s.redis.HGet(s.prefix+collection+".index", lookup).Result()
+	if err == redis.Nil {
+		return "", store.ErrNotFound
+	}
+	return id, err
+}
+
+// decodeMap decodes a msgpack map with string keys, including any nested
+// maps, so that it can be passed to mapstructure.
+func decodeMap(data []byte) (map[string]interface{}, error) {
+	decoder := msgpack.NewDecoder(bytes.NewReader(data))
+	decoder.DecodeMapFunc = func(d *msgpack.Decoder) (interface{}, error) {
+		n, err := d.DecodeMapLen()
+		if err != nil {
+			return nil, err
+		}
+
+		m := make(map[string]interface{}, n)
+		for i := 0; i < n; i++ {
+			key, err := d.DecodeString()
+			if err != nil {
+				return nil, err
+			}
+			value, err := d.DecodeInterface()
+			if err != nil {
+				return nil, err
+			}
+			m[key] = value
+		}
+		return m, nil
+	}
+
+	value, err := decoder.DecodeInterface()
+	if err != nil {
+		return nil, err
+	}
+	m, _ := value.(map[string]interface{})
+	return m, nil
+}
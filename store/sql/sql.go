@@ -0,0 +1,606 @@
+package sql
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/prizem-io/gateway/config"
+	"github.com/prizem-io/gateway/store"
+)
+
+type (
+	// SQLStore persists entities with database/sql.  Each entity is stored
+	// as JSON in the data column of its table alongside the columns that
+	// it is looked up or filtered by.
+	SQLStore struct {
+		db *sql.DB
+		// numbered is set for drivers that use $1 style placeholders
+		numbered bool
+		upsert   upsertStyle
+	}
+
+	// upsertStyle is the statement a driver inserts or updates a row with.
+	upsertStyle int
+
+	consumerRepository   struct{ *SQLStore }
+	credentialRepository struct{ *SQLStore }
+	permissionRepository struct{ *SQLStore }
+	planRepository       struct{ *SQLStore }
+	pluginRepository     struct{ *SQLStore }
+	serviceRepository    struct{ *SQLStore }
+)
+
+const (
+	// updateThenInsert is used for drivers whose upsert is unknown
+	updateThenInsert upsertStyle = iota
+	onConflict
+	onDuplicateKey
+)
+
+var (
+	numberedDrivers = map[string]bool{
+		"postgres":         true,
+		"pgx":              true,
+		"cloudsqlpostgres": true,
+	}
+
+	upsertStyles = map[string]upsertStyle{
+		"postgres":         onConflict,
+		"pgx":              onConflict,
+		"cloudsqlpostgres": onConflict,
+		"sqlite3":          onConflict,
+		"sqlite":           onConflict,
+		"mysql":            onDuplicateKey,
+	}
+
+	// filterColumns maps the JSON fields that entities can be filtered by
+	// to the columns of each table that hold them.
+	filterColumns = map[string]map[string]string{
+		"consumers": {
+			"id":     "id",
+			"name":   "name",
+			"planId": "plan_id",
+		},
+		"credentials": {
+			"id":          "id",
+			"type":        "type",
+			"subjectType": "subject_type",
+			"subjectId":   "subject_id",
+		},
+		"permissions": {"id": "id", "name": "name"},
+		"plans":       {"id": "id", "name": "name"},
+		"plugins":     {"id": "id", "name": "name"},
+		"services":    {"id": "id", "name": "name"},
+	}
+
+	schema = []string{
+		`CREATE TABLE IF NOT EXISTS consumers (
+			id VARCHAR(255) NOT NULL PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			plan_id VARCHAR(255),
+			data TEXT NOT NULL)`,
+		`CREATE TABLE IF NOT EXISTS credentials (
+			id VARCHAR(255) NOT NULL PRIMARY KEY,
+			type VARCHAR(64) NOT NULL,
+			client_id VARCHAR(255) NOT NULL,
+			subject_type VARCHAR(64),
+			subject_id VARCHAR(255),
+			data TEXT NOT NULL,
+			UNIQUE (type, client_id))`,
+		`CREATE TABLE IF NOT EXISTS permissions (
+			id VARCHAR(255) NOT NULL PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			type INTEGER,
+			scope INTEGER,
+			data TEXT NOT NULL)`,
+		`CREATE TABLE IF NOT EXISTS plans (
+			id VARCHAR(255) NOT NULL PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			data TEXT NOT NULL)`,
+		`CREATE TABLE IF NOT EXISTS plugins (
+			id VARCHAR(255) NOT NULL PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			data TEXT NOT NULL)`,
+		`CREATE TABLE IF NOT EXISTS services (
+			id VARCHAR(255) NOT NULL PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			data TEXT NOT NULL)`,
+	}
+)
+
+// New returns a store for db.  driverName is the name db was opened
+// with and determines the placeholder style of queries.
+func New(db *sql.DB, driverName string) *SQLStore {
+	return &SQLStore{
+		db:       db,
+		numbered: numberedDrivers[driverName],
+		upsert:   upsertStyles[driverName],
+	}
+}
+
+// CreateSchema creates any tables that do not exist.
+func (s *SQLStore) CreateSchema() error {
+	for _, statement := range schema {
+		_, err := s.db.Exec(statement)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SQLStore) Consumers() store.ConsumerRepository {
+	return consumerRepository{s}
+}
+
+func (s *SQLStore) Credentials() store.CredentialRepository {
+	return credentialRepository{s}
+}
+
+func (s *SQLStore) Permissions() store.PermissionRepository {
+	return permissionRepository{s}
+}
+
+func (s *SQLStore) Plans() store.PlanRepository {
+	return planRepository{s}
+}
+
+func (s *SQLStore) Plugins() store.PluginRepository {
+	return pluginRepository{s}
+}
+
+func (s *SQLStore) Services() store.ServiceRepository {
+	return serviceRepository{s}
+}
+
+// Consumers
+
+func (r consumerRepository) GetConsumer(id string) (*config.Consumer, error) {
+	var consumer config.Consumer
+	err := r.get("consumers", "id", id, &consumer)
+	if err != nil {
+		return nil, err
+	}
+	return &consumer, nil
+}
+
+func (r consumerRepository) ListConsumers(query store.Query) ([]*config.Consumer, int, error) {
+	entities, total, err := r.list("consumers", query, func() interface{} {
+		return &config.Consumer{}
+	})
+	consumers := make([]*config.Consumer, len(entities))
+	for i, entity := range entities {
+		consumers[i] = entity.(*config.Consumer)
+	}
+	return consumers, total, err
+}
+
+func (r consumerRepository) SaveConsumer(consumer *config.Consumer) error {
+	return r.save("consumers", consumer.ID, consumer,
+		[]string{"name", "plan_id"},
+		consumer.Name, nullString(consumer.PlanID))
+}
+
+func (r consumerRepository) DeleteConsumer(id string) error {
+	return r.delete("consumers", id)
+}
+
+// Credentials
+
+func (r credentialRepository) GetCredential(id string) (map[string]interface{}, error) {
+	var credential map[string]interface{}
+	err := r.get("credentials", "id", id, &credential)
+	if err != nil {
+		return nil, err
+	}
+	return credential, nil
+}
+
+func (r credentialRepository) FindCredential(credentialType, clientID string) (map[string]interface{}, error) {
+	var credential map[string]interface{}
+	row := r.db.QueryRow(r.rebind("SELECT data FROM credentials WHERE type = ? AND client_id = ?"),
+		credentialType, clientID)
+	err := scanData(row, &credential)
+	if err != nil {
+		return nil, err
+	}
+	return credential, nil
+}
+
+func (r credentialRepository) ListCredentials(query store.Query) ([]map[string]interface{}, int, error) {
+	entities, total, err := r.list("credentials", query, func() interface{} {
+		return &map[string]interface{}{}
+	})
+	credentials := make([]map[string]interface{}, len(entities))
+	for i, entity := range entities {
+		credentials[i] = *entity.(*map[string]interface{})
+	}
+	return credentials, total, err
+}
+
+func (r credentialRepository) SaveCredential(clientID string, credential map[string]interface{}) error {
+	subjectType, _ := credential["subjectType"].(string)
+	subjectID, _ := credential["subjectId"].(string)
+	return r.save("credentials", store.CredentialID(credential), credential,
+		[]string{"type", "client_id", "subject_type", "subject_id"},
+		store.CredentialType(credential), clientID, subjectType, subjectID)
+}
+
+func (r credentialRepository) DeleteCredential(id string) error {
+	return r.delete("credentials", id)
+}
+
+// Permissions
+
+func (r permissionRepository) GetPermission(id string) (*config.Permission, error) {
+	var permission config.Permission
+	err := r.get("permissions", "id", id, &permission)
+	if err != nil {
+		return nil, err
+	}
+	return &permission, nil
+}
+
+func (r permissionRepository) ListPermissions(query store.Query) ([]*config.Permission, int, error) {
+	entities, total, err := r.list("permissions", query, func() interface{} {
+		return &config.Permission{}
+	})
+	permissions := make([]*config.Permission, len(entities))
+	for i, entity := range entities {
+		permissions[i] = entity.(*config.Permission)
+	}
+	return permissions, total, err
+}
+
+func (r permissionRepository) SavePermission(permission *config.Permission) error {
+	return r.save("permissions", permission.ID, permission,
+		[]string{"name", "type", "scope"},
+		permission.Name, permission.Type.Int(), permission.Scope.Int())
+}
+
+func (r permissionRepository) DeletePermission(id string) error {
+	return r.delete("permissions", id)
+}
+
+// Plans
+
+func (r planRepository) GetPlan(id string) (*config.Plan, error) {
+	var plan config.Plan
+	err := r.get("plans", "id", id, &plan)
+	if err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
+func (r planRepository) ListPlans(query store.Query) ([]*config.Plan, int, error) {
+	entities, total, err := r.list("plans", query, func() interface{} {
+		return &config.Plan{}
+	})
+	plans := make([]*config.Plan, len(entities))
+	for i, entity := range entities {
+		plans[i] = entity.(*config.Plan)
+	}
+	return plans, total, err
+}
+
+func (r planRepository) SavePlan(plan *config.Plan) error {
+	return r.save("plans", plan.ID, plan, []string{"name"}, plan.Name)
+}
+
+func (r planRepository) DeletePlan(id string) error {
+	return r.delete("plans", id)
+}
+
+// Plugins
+
+func (r pluginRepository) GetPlugin(id string) (*config.Plugin, error) {
+	var plugin config.Plugin
+	err := r.get("plugins", "id", id, &plugin)
+	if err != nil {
+		return nil, err
+	}
+	return &plugin, nil
+}
+
+func (r pluginRepository) FindPlugin(name string) (*config.Plugin, error) {
+	entities, err := r.query(r.rebind("SELECT data FROM plugins WHERE name = ? ORDER BY id"),
+		[]interface{}{name}, func() interface{} {
+			return &config.Plugin{}
+		}, func(entity interface{}) bool {
+			// Some databases compare names case-insensitively
+			return entity.(*config.Plugin).Name == name
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	plugins := make([]*config.Plugin, len(entities))
+	for i, entity := range entities {
+		plugins[i] = entity.(*config.Plugin)
+	}
+	plugin := store.DefaultPlugin(plugins)
+	if plugin == nil {
+		return nil, store.ErrNotFound
+	}
+	return plugin, nil
+}
+
+func (r pluginRepository) ListPlugins(query store.Query) ([]*config.Plugin, int, error) {
+	entities, total, err := r.list("plugins", query, func() interface{} {
+		return &config.Plugin{}
+	})
+	plugins := make([]*config.Plugin, len(entities))
+	for i, entity := range entities {
+		plugins[i] = entity.(*config.Plugin)
+	}
+	return plugins, total, err
+}
+
+func (r pluginRepository) SavePlugin(plugin *config.Plugin) error {
+	return r.save("plugins", plugin.ID, plugin, []string{"name"}, plugin.Name)
+}
+
+func (r pluginRepository) DeletePlugin(id string) error {
+	return r.delete("plugins", id)
+}
+
+// Services
+
+func (r serviceRepository) GetService(id string) (*config.Service, error) {
+	var service config.Service
+	err := r.get("services", "id", id, &service)
+	if err != nil {
+		return nil, err
+	}
+	return &service, nil
+}
+
+func (r serviceRepository) ListServices(query store.Query) ([]*config.Service, int, error) {
+	entities, total, err := r.list("services", query, func() interface{} {
+		return &config.Service{}
+	})
+	services := make([]*config.Service, len(entities))
+	for i, entity := range entities {
+		services[i] = entity.(*config.Service)
+	}
+	return services, total, err
+}
+
+func (r serviceRepository) SaveService(service *config.Service) error {
+	return r.save("services", service.ID, service, []string{"name"}, service.Name)
+}
+
+func (r serviceRepository) DeleteService(id string) error {
+	return r.delete("services", id)
+}
+
+func (s *SQLStore) get(table, column, value string, entity interface{}) error {
+	row := s.db.QueryRow(s.rebind("SELECT data FROM "+table+" WHERE "+column+" = ?"), value)
+	return scanData(row, entity)
+}
+
+// list returns the entities of table that query selects and the number
+// that match its filters.  Filters on columns of the table are applied by
+// the database and any others to the decoded entities.
+func (s *SQLStore) list(table string, query store.Query, newEntity func() interface{}) ([]interface{}, int, error) {
+	where, args, remaining := filterClause(table, query.Filters)
+
+	// Only the database can page through matches when it applies
+	// every filter
+	if len(remaining) == 0 && query.Limit > 0 {
+		var total int
+		err := s.db.QueryRow(s.rebind("SELECT COUNT(*) FROM "+table+where), args...).Scan(&total)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		entities, err := s.query(s.rebind("SELECT data FROM "+table+where+" ORDER BY id LIMIT ? OFFSET ?"),
+			append(args, query.Limit, query.Offset), newEntity, func(interface{}) bool { return true })
+		return entities, total, err
+	}
+
+	total := 0
+	entities, err := s.query(s.rebind("SELECT data FROM "+table+where+" ORDER BY id"), args, newEntity,
+		func(entity interface{}) bool {
+			if !store.Matches(entity, remaining) {
+				return false
+			}
+			total++
+			return query.Selects(total - 1)
+		})
+	return entities, total, err
+}
+
+// query decodes the data column of each row and returns the entities
+// that keep accepts.
+func (s *SQLStore) query(statement string, args []interface{}, newEntity func() interface{}, keep func(entity interface{}) bool) ([]interface{}, error) {
+	rows, err := s.db.Query(statement, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entities := []interface{}{}
+	for rows.Next() {
+		var data []byte
+		err := rows.Scan(&data)
+		if err != nil {
+			return nil, err
+		}
+		entity := newEntity()
+		err = json.Unmarshal(data, entity)
+		if err != nil {
+			return nil, err
+		}
+		if keep(entity) {
+			entities = append(entities, entity)
+		}
+	}
+
+	return entities, rows.Err()
+}
+
+// filterClause returns the WHERE clause and arguments of the filters on
+// columns of table, and the filters that are not on a column.
+func filterClause(table string, filters map[string][]string) (string, []interface{}, map[string][]string) {
+	conditions := []string{}
+	args := []interface{}{}
+	remaining := map[string][]string{}
+
+	fields := make([]string, 0, len(filters))
+	for field := range filters {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	for _, field := range fields {
+		values := filters[field]
+		column, ok := filterColumns[table][field]
+		if !ok || len(values) == 0 {
+			remaining[field] = values
+			continue
+		}
+
+		placeholders := strings.TrimSuffix(strings.Repeat("LOWER(?), ", len(values)), ", ")
+		conditions = append(conditions, "LOWER("+column+") IN ("+placeholders+")")
+		for _, value := range values {
+			args = append(args, value)
+		}
+	}
+
+	if len(conditions) == 0 {
+		return "", args, remaining
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args, remaining
+}
+
+// save inserts the row for id or updates it if it exists.  columns are
+// the lookup columns of table and values their values.
+func (s *SQLStore) save(table, id string, entity interface{}, columns []string, values ...interface{}) error {
+	data, err := json.Marshal(entity)
+	if err != nil {
+		return err
+	}
+
+	allColumns := append([]string{"id"}, columns...)
+	allColumns = append(allColumns, "data")
+	args := append([]interface{}{id}, values...)
+	args = append(args, string(data))
+	insert := "INSERT INTO " + table + " (" + strings.Join(allColumns, ", ") + ") VALUES (?" +
+		strings.Repeat(", ?", len(allColumns)-1) + ")"
+
+	assignments := make([]string, len(allColumns)-1)
+	for i, column := range allColumns[1:] {
+		switch s.upsert {
+		case onConflict:
+			assignments[i] = column + " = EXCLUDED." + column
+		case onDuplicateKey:
+			assignments[i] = column + " = VALUES(" + column + ")"
+		}
+	}
+
+	switch s.upsert {
+	case onConflict:
+		_, err = s.db.Exec(s.rebind(insert+" ON CONFLICT (id) DO UPDATE SET "+strings.Join(assignments, ", ")), args...)
+		return err
+	case onDuplicateKey:
+		_, err = s.db.Exec(s.rebind(insert+" ON DUPLICATE KEY UPDATE "+strings.Join(assignments, ", ")), args...)
+		return err
+	}
+
+	updated, err := s.update(table, id, columns, args[1:]...)
+	if err != nil || updated {
+		return err
+	}
+
+	_, err = s.db.Exec(s.rebind(insert), args...)
+	if err != nil {
+		// A concurrent save may have inserted the row since the update,
+		// in which case the insert violates the primary key
+		updated, updateErr := s.update(table, id, columns, args[1:]...)
+		if updateErr == nil && updated {
+			return nil
+		}
+	}
+	return err
+}
+
+// update sets the columns and data of the row for id and reports whether
+// it exists.
+func (s *SQLStore) update(table, id string, columns []string, values ...interface{}) (bool, error) {
+	assignments := make([]string, len(columns))
+	for i, column := range columns {
+		assignments[i] = column + " = ?"
+	}
+	args := append(append([]interface{}{}, values...), id)
+	result, err := s.db.Exec(s.rebind("UPDATE "+table+" SET "+strings.Join(assignments, ", ")+", data = ? WHERE id = ?"), args...)
+	if err != nil {
+		return false, err
+	}
+
+	updated, err := result.RowsAffected()
+	return updated > 0, err
+}
+
+func (s *SQLStore) delete(table, id string) error {
+	result, err := s.db.Exec(s.rebind("DELETE FROM "+table+" WHERE id = ?"), id)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return store.ErrNotFound
+	}
+
+	return nil
+}
+
+// rebind replaces ? placeholders with $1, $2, etc. when the driver requires it.
+func (s *SQLStore) rebind(query string) string {
+	if !s.numbered {
+		return query
+	}
+
+	var buffer bytes.Buffer
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			buffer.WriteByte('$')
+			buffer.WriteString(strconv.Itoa(n))
+			continue
+		}
+		buffer.WriteRune(c)
+	}
+
+	return buffer.String()
+}
+
+func scanData(row *sql.Row, entity interface{}) error {
+	var data []byte
+	err := row.Scan(&data)
+	if err == sql.ErrNoRows {
+		return store.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, entity)
+}
+
+func nullString(value *string) sql.NullString {
+	if value == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: *value, Valid: true}
+}
@@ -0,0 +1,170 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/prizem-io/gateway/config"
+	"github.com/prizem-io/gateway/server"
+)
+
+type (
+	// Store provides a repository for each entity that the gateway
+	// looks up while processing requests.
+	Store interface {
+		Consumers() ConsumerRepository
+		Credentials() CredentialRepository
+		Permissions() PermissionRepository
+		Plans() PlanRepository
+		Plugins() PluginRepository
+		Services() ServiceRepository
+	}
+
+	// Each List method returns the entities that query selects along
+	// with the total number of entities that match its filters.
+
+	ConsumerRepository interface {
+		GetConsumer(id string) (*config.Consumer, error)
+		ListConsumers(query Query) ([]*config.Consumer, int, error)
+		SaveConsumer(consumer *config.Consumer) error
+		DeleteConsumer(id string) error
+	}
+
+	// CredentialRepository stores credentials in the raw form that
+	// server.CredentialDecoder accepts.  The client ID is the key returned
+	// by the decoder and is used by FindCredential.
+	CredentialRepository interface {
+		GetCredential(id string) (map[string]interface{}, error)
+		FindCredential(credentialType, clientID string) (map[string]interface{}, error)
+		ListCredentials(query Query) ([]map[string]interface{}, int, error)
+		SaveCredential(clientID string, credential map[string]interface{}) error
+		DeleteCredential(id string) error
+	}
+
+	PermissionRepository interface {
+		GetPermission(id string) (*config.Permission, error)
+		ListPermissions(query Query) ([]*config.Permission, int, error)
+		SavePermission(permission *config.Permission) error
+		DeletePermission(id string) error
+	}
+
+	PlanRepository interface {
+		GetPlan(id string) (*config.Plan, error)
+		ListPlans(query Query) ([]*config.Plan, int, error)
+		SavePlan(plan *config.Plan) error
+		DeletePlan(id string) error
+	}
+
+	PluginRepository interface {
+		GetPlugin(id string) (*config.Plugin, error)
+		// FindPlugin returns the default instance of the plugin named
+		// name.  See DefaultPlugin.
+		FindPlugin(name string) (*config.Plugin, error)
+		ListPlugins(query Query) ([]*config.Plugin, int, error)
+		SavePlugin(plugin *config.Plugin) error
+		DeletePlugin(id string) error
+	}
+
+	ServiceRepository interface {
+		GetService(id string) (*config.Service, error)
+		ListServices(query Query) ([]*config.Service, int, error)
+		SaveService(service *config.Service) error
+		DeleteService(id string) error
+	}
+)
+
+var (
+	ErrNotFound = errors.New("Entity not found")
+)
+
+// Import saves the entities of gatewayConfig to s.  Plugins and services
+// without an ID are saved using their name as the ID.
+func Import(s Store, gatewayConfig *server.GatewayConfig) error {
+	for i := range gatewayConfig.Consumers {
+		err := s.Consumers().SaveConsumer(&gatewayConfig.Consumers[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, credential := range gatewayConfig.Credentials {
+		credentialType := CredentialType(credential)
+		decoder, ok := server.GetCredentialDecoder(credentialType)
+		if !ok {
+			return fmt.Errorf("Unknown credential type %q", credentialType)
+		}
+		_, clientID, err := decoder.DecodeCredential(credential)
+		if err != nil {
+			return err
+		}
+		err = s.Credentials().SaveCredential(clientID, credential)
+		if err != nil {
+			return err
+		}
+	}
+
+	for i := range gatewayConfig.Permissions {
+		err := s.Permissions().SavePermission(&gatewayConfig.Permissions[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	for i := range gatewayConfig.Plans {
+		err := s.Plans().SavePlan(&gatewayConfig.Plans[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	for i := range gatewayConfig.Plugins {
+		plugin := gatewayConfig.Plugins[i]
+		if plugin.ID == "" {
+			plugin.ID = plugin.Name
+		}
+		err := s.Plugins().SavePlugin(&plugin)
+		if err != nil {
+			return err
+		}
+	}
+
+	for i := range gatewayConfig.Services {
+		service := gatewayConfig.Services[i]
+		if service.ID == "" {
+			service.ID = service.Name
+		}
+		err := s.Services().SaveService(&service)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CredentialID returns the id of a raw credential.
+func CredentialID(credential map[string]interface{}) string {
+	id, _ := credential["id"].(string)
+	return id
+}
+
+// CredentialType returns the type of a raw credential.
+func CredentialType(credential map[string]interface{}) string {
+	credentialType, _ := credential["type"].(string)
+	return credentialType
+}
+
+// DefaultPlugin returns the default instance among plugins, which share a
+// name: the one created first, or the one with the lowest ID of those
+// created at the same time.  It returns nil if plugins is empty.
+func DefaultPlugin(plugins []*config.Plugin) *config.Plugin {
+	var first *config.Plugin
+	for _, plugin := range plugins {
+		if first == nil ||
+			plugin.CreatedDate.Before(first.CreatedDate) ||
+			plugin.CreatedDate.Equal(first.CreatedDate) && plugin.ID < first.ID {
+			first = plugin
+		}
+	}
+	return first
+}
@@ -0,0 +1,45 @@
+package usage
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+// FileSink appends events to a local file as lines of JSON.
+type FileSink struct {
+	mutex sync.Mutex
+	file  *os.File
+}
+
+func NewFileSink(path string) (*FileSink, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileSink{
+		file: file,
+	}, nil
+}
+
+func (s *FileSink) Write(events []Event) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	writer := bufio.NewWriter(s.file)
+	encoder := json.NewEncoder(writer)
+	for i := range events {
+		err := encoder.Encode(&events[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	return writer.Flush()
+}
+
+func (s *FileSink) Close() error {
+	return s.file.Close()
+}
@@ -0,0 +1,58 @@
+package usage
+
+import (
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/prizem-io/gateway/admin"
+	"github.com/prizem-io/gateway/context"
+	ef "github.com/prizem-io/gateway/errorfactory"
+	"github.com/prizem-io/gateway/server"
+)
+
+var _store Store
+
+// SetStore sets the store that reports are served from.
+func SetStore(store Store) {
+	_store = store
+}
+
+// Routes registers the usage report route.  It is intended to be passed
+// to server.AddBuildRouterCallbacks.
+func Routes(router server.Router) {
+	router.GET(admin.Prefix()+"/usage/:consumerId", admin.Protect(ReportHandler))
+}
+
+// ReportHandler serves the usage report of a consumer for the period in
+// the "period" parameter, or for the current period.
+func ReportHandler(ctx context.Context) {
+	period := ctx.Rq().URLParam("period")
+	if period == "" {
+		period = PeriodOf(time.Now())
+	} else if _, err := time.Parse(periodLayout(), period); err != nil {
+		sendError(ctx, ef.New(ctx, "invalidParameter", ef.Params{
+			"param": "period",
+		}))
+		return
+	}
+
+	if _store == nil {
+		sendError(ctx, ef.New(ctx, "notFound"))
+		return
+	}
+
+	report, err := GetReport(_store, ctx.GetDataAccessor(), ctx.Rq().Param("consumerId"), period)
+	if err != nil {
+		log.Errorf("Could not report usage: %s", err)
+		sendError(ctx, ef.New(ctx, "internalError"))
+		return
+	}
+
+	ctx.SendEntity(report)
+}
+
+func sendError(ctx context.Context, err *ef.APIError) {
+	ctx.Rs().SetStatusCode(err.Status)
+	ctx.SendEntity(err)
+}
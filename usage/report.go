@@ -0,0 +1,192 @@
+package usage
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/prizem-io/gateway/context"
+)
+
+type (
+	// Totals sums the usage of a consumer on a plan in a period.
+	Totals struct {
+		Requests int64 `json:"requests"`
+		// Errors counts the requests that failed with a 4xx or 5xx status.
+		Errors int64 `json:"errors"`
+		// Cost sums the costs of the requests that did not fail, which are
+		// the ones that are charged.
+		Cost          int64 `json:"cost"`
+		RequestBytes  int64 `json:"requestBytes"`
+		ResponseBytes int64 `json:"responseBytes"`
+	}
+
+	// Store keeps the totals of each consumer by period and plan.
+	Store interface {
+		Add(consumerID, period, planID string, totals Totals) error
+		Totals(consumerID, period string) (map[string]Totals, error)
+	}
+
+	// Aggregator is a Sink that adds the events of consumers to the totals
+	// of their period in a Store.
+	Aggregator struct {
+		store Store
+	}
+
+	// MemoryStore is a Store that keeps totals in memory.  Its totals are
+	// not shared with other gateways and are lost on restart.
+	MemoryStore struct {
+		mutex  sync.Mutex
+		totals map[string]map[string]Totals
+	}
+
+	// Report is the usage and charges of a consumer in a period.
+	Report struct {
+		ConsumerID string      `json:"consumerId"`
+		Period     string      `json:"period"`
+		Plans      []PlanUsage `json:"plans"`
+	}
+
+	// PlanUsage is the usage of a consumer on a plan.  The price amount of
+	// a plan is a price per unit of cost, so Charge is the cost of the
+	// successful requests times the price amount, not a flat fee.
+	PlanUsage struct {
+		PlanID string `json:"planId,omitempty"`
+		Totals
+		Charge   float64 `json:"charge"`
+		Currency string  `json:"currency,omitempty"`
+	}
+
+	aggregateKey struct {
+		consumerID string
+		period     string
+		planID     string
+	}
+)
+
+func NewAggregator(store Store) *Aggregator {
+	return &Aggregator{
+		store: store,
+	}
+}
+
+// Write sums events by consumer, period and plan before adding them to
+// the store.  Events without a consumer are not aggregated.
+func (a *Aggregator) Write(events []Event) error {
+	aggregates := map[aggregateKey]*Totals{}
+	for i := range events {
+		event := &events[i]
+		if event.ConsumerID == "" {
+			continue
+		}
+
+		key := aggregateKey{event.ConsumerID, PeriodOf(event.Time), event.PlanID}
+		totals, ok := aggregates[key]
+		if !ok {
+			totals = &Totals{}
+			aggregates[key] = totals
+		}
+		totals.add(event)
+	}
+
+	for key, totals := range aggregates {
+		err := a.store.Add(key.consumerID, key.period, key.planID, *totals)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (t *Totals) add(event *Event) {
+	t.Requests++
+	if event.Status >= 400 {
+		t.Errors++
+	} else {
+		t.Cost += event.Cost
+	}
+	t.RequestBytes += int64(event.RequestBytes)
+	t.ResponseBytes += int64(event.ResponseBytes)
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		totals: map[string]map[string]Totals{},
+	}
+}
+
+func (s *MemoryStore) Add(consumerID, period, planID string, totals Totals) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	key := consumerID + "|" + period
+	byPlan, ok := s.totals[key]
+	if !ok {
+		byPlan = map[string]Totals{}
+		s.totals[key] = byPlan
+	}
+
+	current := byPlan[planID]
+	current.Requests += totals.Requests
+	current.Errors += totals.Errors
+	current.Cost += totals.Cost
+	current.RequestBytes += totals.RequestBytes
+	current.ResponseBytes += totals.ResponseBytes
+	byPlan[planID] = current
+
+	return nil
+}
+
+func (s *MemoryStore) Totals(consumerID, period string) (map[string]Totals, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	byPlan := map[string]Totals{}
+	for planID, totals := range s.totals[consumerID+"|"+period] {
+		byPlan[planID] = totals
+	}
+	return byPlan, nil
+}
+
+// GetReport reports the usage of a consumer in a period from store.
+// Charges are calculated with the current prices of the plans, which are
+// looked up with accessor, as prices per unit of cost.
+func GetReport(store Store, accessor context.DataAccessor, consumerID, period string) (*Report, error) {
+	byPlan, err := store.Totals(consumerID, period)
+	if err != nil {
+		return nil, err
+	}
+
+	report := Report{
+		ConsumerID: consumerID,
+		Period:     period,
+		Plans:      make([]PlanUsage, 0, len(byPlan)),
+	}
+	for planID, totals := range byPlan {
+		planUsage := PlanUsage{
+			PlanID: planID,
+			Totals: totals,
+		}
+		if planID != "" {
+			// Plans that no longer exist are reported without charges
+			if plan, err := accessor.GetPlan(planID); err == nil && plan != nil {
+				planUsage.Charge = float64(totals.Cost) * price(plan.PriceAmount)
+				planUsage.Currency = plan.PriceCurrency
+			}
+		}
+		report.Plans = append(report.Plans, planUsage)
+	}
+	sort.Slice(report.Plans, func(i, j int) bool {
+		return report.Plans[i].PlanID < report.Plans[j].PlanID
+	})
+
+	return &report, nil
+}
+
+// price converts amount to the float64 closest to its decimal form, so
+// that a price of 0.01 is not charged as 0.009999999776.
+func price(amount float32) float64 {
+	value, _ := strconv.ParseFloat(strconv.FormatFloat(float64(amount), 'g', -1, 32), 64)
+	return value
+}
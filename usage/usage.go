@@ -0,0 +1,197 @@
+package usage
+
+import (
+	"sync/atomic"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+
+	"github.com/prizem-io/gateway/config"
+	"github.com/prizem-io/gateway/context"
+)
+
+type (
+	// Event describes a completed request.
+	Event struct {
+		Time       time.Time `json:"time"`
+		RequestID  string    `json:"requestId"`
+		ConsumerID string    `json:"consumerId,omitempty"`
+		// ClientID is the ID of the client or, if the request was not made
+		// by a registered client, of the credential.
+		ClientID  string `json:"clientId,omitempty"`
+		PlanID    string `json:"planId,omitempty"`
+		Service   string `json:"service"`
+		Operation string `json:"operation"`
+		Status    int    `json:"status"`
+		// Latency is in milliseconds.
+		Latency       float64 `json:"latency"`
+		RequestBytes  int     `json:"requestBytes"`
+		ResponseBytes int     `json:"responseBytes"`
+		// Cost is the request weight of the operation.
+		Cost int64 `json:"cost"`
+	}
+
+	// Sink receives batches of usage events.  Sinks are written to one at
+	// a time and must not retain events after Write returns.
+	Sink interface {
+		Write(events []Event) error
+	}
+
+	usageConfig struct {
+		Enabled bool `mapstructure:"enabled"`
+		// BufferSize is the number of events that may wait to be written.
+		// Events are dropped while the buffer is full.
+		BufferSize int `mapstructure:"bufferSize"`
+		// BatchSize is the largest number of events written at once.
+		BatchSize int `mapstructure:"batchSize"`
+		// FlushInterval is how long events wait for a batch to fill.
+		FlushInterval time.Duration `mapstructure:"flushInterval"`
+		// Period is "day" or "month", the period that usage is reported by.
+		Period string `mapstructure:"period"`
+	}
+)
+
+const (
+	PeriodDay   = "day"
+	PeriodMonth = "month"
+)
+
+var (
+	_config = usageConfig{
+		BufferSize:    10000,
+		BatchSize:     100,
+		FlushInterval: time.Second,
+		Period:        PeriodMonth,
+	}
+	sinks   []Sink
+	events  chan Event
+	dropped int64
+
+	periodLayouts = map[string]string{
+		PeriodDay:   "2006-01-02",
+		PeriodMonth: "2006-01",
+	}
+)
+
+func Initialize(configuration config.Configuration) error {
+	return configuration.UnmarshalKey("usage", &_config)
+}
+
+func Enabled() bool {
+	return _config.Enabled
+}
+
+// SetSinks sets the sinks that events are written to.  It must be called
+// before Start.
+func SetSinks(_sinks ...Sink) {
+	sinks = _sinks
+}
+
+// Start writes recorded events to the sinks in the background.
+func Start() {
+	if events != nil {
+		return
+	}
+
+	events = make(chan Event, _config.BufferSize)
+	go run(events)
+}
+
+// Record queues an event for the request of ctx.  It is intended to be
+// passed to server.SetSuccessHandlers and server.SetErrorHandlers.
+func Record(ctx context.Context) {
+	if events == nil {
+		return
+	}
+
+	select {
+	case events <- NewEvent(ctx):
+	default:
+		atomic.AddInt64(&dropped, 1)
+	}
+}
+
+// NewEvent returns the usage event of the request of ctx.
+func NewEvent(ctx context.Context) Event {
+	now := time.Now()
+	event := Event{
+		Time:          now.UTC(),
+		RequestID:     ctx.RequestID(),
+		Status:        ctx.Rs().StatusCode(),
+		Latency:       float64(now.Sub(ctx.Rq().Time())) / float64(time.Millisecond),
+		RequestBytes:  len(ctx.Rq().Body()),
+		ResponseBytes: len(ctx.Rs().Body()),
+		Cost:          1,
+	}
+
+	if consumer := ctx.Consumer(); consumer != nil {
+		event.ConsumerID = consumer.ID
+	}
+	if client := ctx.Client(); client != nil {
+		event.ClientID = client.ID
+	} else if credential := ctx.Credential(); credential != nil {
+		event.ClientID = credential.ID
+	}
+	if plan := ctx.Plan(); plan != nil {
+		event.PlanID = plan.ID
+	}
+	if service := ctx.Service(); service != nil {
+		event.Service = service.Name
+		event.Cost = service.RequestWeight(ctx.Operation())
+	}
+	if operation := ctx.Operation(); operation != nil {
+		event.Operation = operation.Name
+	}
+
+	return event
+}
+
+// PeriodOf returns the period that usage at t is reported in, as in
+// "2017-06" for months.
+func PeriodOf(t time.Time) string {
+	return t.UTC().Format(periodLayout())
+}
+
+func periodLayout() string {
+	layout, ok := periodLayouts[_config.Period]
+	if !ok {
+		return periodLayouts[PeriodMonth]
+	}
+	return layout
+}
+
+func run(events <-chan Event) {
+	ticker := time.NewTicker(_config.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]Event, 0, _config.BatchSize)
+	for {
+		select {
+		case event := <-events:
+			batch = append(batch, event)
+			if len(batch) < _config.BatchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		}
+
+		write(batch)
+		batch = batch[:0]
+
+		if count := atomic.SwapInt64(&dropped, 0); count > 0 {
+			log.Warnf("Dropped %d usage events because the buffer was full", count)
+		}
+	}
+}
+
+func write(batch []Event) {
+	for _, sink := range sinks {
+		err := sink.Write(batch)
+		if err != nil {
+			log.Warnf("Could not write %d usage events: %s", len(batch), err)
+		}
+	}
+}
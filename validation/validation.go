@@ -0,0 +1,520 @@
+package validation
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/prizem-io/gateway/authentication"
+	"github.com/prizem-io/gateway/backend"
+	"github.com/prizem-io/gateway/config"
+	"github.com/prizem-io/gateway/filter"
+	"github.com/prizem-io/gateway/server"
+	fasthttpserver "github.com/prizem-io/gateway/server/fasthttp"
+)
+
+type (
+	// PluginRecognizer reports whether a plugin name is handled by
+	// a registered component.
+	PluginRecognizer func(name string) bool
+
+	Problem struct {
+		Severity string `json:"severity"`
+		Location string `json:"location"`
+		Message  string `json:"message"`
+	}
+
+	Report struct {
+		Valid    bool      `json:"valid"`
+		Problems []Problem `json:"problems"`
+	}
+
+	validator struct {
+		config      *server.GatewayConfig
+		consumers   map[string]bool
+		permissions map[string]bool
+		plans       map[string]bool
+		plugins     map[string]*config.Plugin
+		problems    []Problem
+	}
+)
+
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+var (
+	pluginRecognizers = []PluginRecognizer{filter.HasFilter}
+
+	requiredConfigurationKeys = []string{
+		"gateway.listen",
+		"gateway.config",
+	}
+
+	auditableType = reflect.TypeOf(config.Auditable{})
+)
+
+func AddPluginRecognizers(recognizers ...PluginRecognizer) {
+	pluginRecognizers = append(pluginRecognizers, recognizers...)
+}
+
+// Validate checks the process configuration and the gateway configuration
+// it refers to without starting a listener or connecting to any datastore.
+// All filters, backends, authenticators and credential decoders should be
+// registered beforehand so that plugin types can be resolved.
+func Validate(configuration config.Configuration, configLocation string) *Report {
+	report := Report{
+		Problems: []Problem{},
+	}
+
+	for _, key := range requiredConfigurationKeys {
+		if !configuration.IsSet(key) {
+			report.Problems = append(report.Problems, Problem{
+				Severity: SeverityError,
+				Location: key,
+				Message:  "missing required configuration key",
+			})
+		}
+	}
+
+	if configLocation != "" {
+		report.Problems = append(report.Problems, ValidateLocation(configLocation)...)
+	}
+
+	report.Valid = true
+	for _, problem := range report.Problems {
+		if problem.Severity == SeverityError {
+			report.Valid = false
+			break
+		}
+	}
+
+	return &report
+}
+
+// ValidateLocation loads and checks the gateway configuration at configLocation.
+func ValidateLocation(configLocation string) []Problem {
+	gatewayConfig, err := server.LoadGatewayConfig(configLocation)
+	if err != nil {
+		if loadErr, ok := err.(*server.ConfigLoadError); ok {
+			problems := make([]Problem, len(loadErr.Problems))
+			for i, message := range loadErr.Problems {
+				// Load problems are prefixed with the file and line
+				location := configLocation
+				if index := strings.Index(message, ": "); index != -1 {
+					location, message = message[:index], message[index+2:]
+				}
+				problems[i] = Problem{
+					Severity: SeverityError,
+					Location: location,
+					Message:  message,
+				}
+			}
+			return problems
+		}
+
+		return []Problem{{
+			Severity: SeverityError,
+			Location: configLocation,
+			Message:  err.Error(),
+		}}
+	}
+
+	return ValidateGatewayConfig(gatewayConfig)
+}
+
+// ValidateGatewayConfig checks an already loaded gateway configuration.
+func ValidateGatewayConfig(gatewayConfig *server.GatewayConfig) []Problem {
+	v := validator{
+		config:      gatewayConfig,
+		consumers:   make(map[string]bool, len(gatewayConfig.Consumers)),
+		permissions: make(map[string]bool, len(gatewayConfig.Permissions)),
+		plans:       make(map[string]bool, len(gatewayConfig.Plans)),
+		plugins:     make(map[string]*config.Plugin, len(gatewayConfig.Plugins)),
+		problems:    []Problem{},
+	}
+
+	for _, consumer := range gatewayConfig.Consumers {
+		v.consumers[consumer.ID] = true
+	}
+	for _, permission := range gatewayConfig.Permissions {
+		v.permissions[permission.ID] = true
+	}
+	for _, plan := range gatewayConfig.Plans {
+		v.plans[plan.ID] = true
+	}
+	for i := range gatewayConfig.Plugins {
+		plugin := &gatewayConfig.Plugins[i]
+		if _, ok := v.plugins[plugin.ID]; !ok && plugin.ID != "" {
+			v.plugins[plugin.ID] = plugin
+		}
+	}
+
+	v.validateServices()
+	v.validateConsumers()
+	v.validateCredentials()
+	v.validatePermissions()
+	v.validatePlans()
+	v.validatePlugins()
+
+	for _, err := range fasthttpserver.CheckRoutes(gatewayConfig.Services) {
+		v.errorf("routes", "conflicting route: %s", err)
+	}
+
+	return v.problems
+}
+
+// ValidateEntity reports the required fields of value that have not been set.
+// value is typically one of the config *Update types.
+func ValidateEntity(location string, value interface{}) []Problem {
+	v := validator{
+		problems: []Problem{},
+	}
+	v.required(location, value)
+	return v.problems
+}
+
+func (v *validator) validateServices() {
+	for i := range v.config.Services {
+		service := &v.config.Services[i]
+		location := fmt.Sprintf("services[%s]", nameOrIndex(service.Name, i))
+
+		// Services are identified by name when no ID is given
+		v.required(location, service.ServiceUpdate)
+
+		if len(service.Hostnames) == 0 {
+			v.errorf(location+".hostnames", "at least one hostname is required")
+		}
+		v.knownEnum(location+".authenticationType", &service.AuthenticationType)
+		v.validateBackend(location+".backend", service.Backend)
+		v.validateFilters(location+".filters", service.Filters)
+		v.validateAuthenticators(location+".authenticators", service.Authenticators)
+		v.validateRequestWeights(location+".requestWeights", service)
+
+		for j := range service.Operations {
+			operation := &service.Operations[j]
+			operationLocation := fmt.Sprintf("%s.operations[%s]", location, nameOrIndex(operation.Name, j))
+
+			v.required(operationLocation, *operation)
+			v.knownEnum(operationLocation+".method", &operation.Method)
+			v.validatePermissionIDs(operationLocation+".permissionIds", operation.PermissionIDs)
+			v.validateFilters(operationLocation+".filters", operation.Filters)
+			if operation.Backend != nil {
+				v.validateBackend(operationLocation+".backend", operation.Backend)
+			}
+		}
+	}
+}
+
+// validateRequestWeights reports weights that are negative or keyed by
+// neither the name of an operation of service nor a method.
+func (v *validator) validateRequestWeights(location string, service *config.Service) {
+	for key, weight := range service.RequestWeights {
+		weightLocation := fmt.Sprintf("%s[%s]", location, key)
+		if weight < 0 {
+			v.errorf(weightLocation, "weight must not be negative")
+		}
+
+		method := config.Method(key)
+		if method.Known() {
+			continue
+		}
+		known := false
+		for j := range service.Operations {
+			if service.Operations[j].Name == key {
+				known = true
+				break
+			}
+		}
+		if !known {
+			v.errorf(weightLocation, "unknown operation or method %q", key)
+		}
+	}
+}
+
+func (v *validator) validateConsumers() {
+	for i := range v.config.Consumers {
+		consumer := &v.config.Consumers[i]
+		location := fmt.Sprintf("consumers[%s]", nameOrIndex(consumer.ID, i))
+
+		v.required(location, consumer.Entity)
+		v.required(location, consumer.ConsumerUpdate)
+		v.validatePermissionIDs(location+".permissionIds", consumer.PermissionIDs)
+		v.validateFilters(location+".filters", consumer.Filters)
+
+		if consumer.PlanID != nil && !v.plans[*consumer.PlanID] {
+			v.errorf(location+".planId", "unknown plan %q", *consumer.PlanID)
+		}
+	}
+}
+
+func (v *validator) validateCredentials() {
+	for i, credential := range v.config.Credentials {
+		id, _ := credential["id"].(string)
+		location := fmt.Sprintf("credentials[%s]", nameOrIndex(id, i))
+
+		if id == "" {
+			v.errorf(location+".id", "missing required field")
+		}
+
+		credentialType, _ := credential["type"].(string)
+		if credentialType == "" {
+			v.errorf(location+".type", "missing required field")
+			continue
+		}
+
+		decoder, ok := server.GetCredentialDecoder(credentialType)
+		if !ok {
+			v.errorf(location+".type", "unknown credential type %q", credentialType)
+			continue
+		}
+
+		_, _, err := decoder.DecodeCredential(credential)
+		if err != nil {
+			v.errorf(location, "invalid credential: %s", err)
+		}
+
+		subjectType, _ := credential["subjectType"].(string)
+		subjectID, _ := credential["subjectId"].(string)
+		if subjectType == "consumer" && !v.consumers[subjectID] {
+			v.errorf(location+".subjectId", "unknown consumer %q", subjectID)
+		}
+	}
+}
+
+func (v *validator) validatePermissions() {
+	for i := range v.config.Permissions {
+		permission := &v.config.Permissions[i]
+		location := fmt.Sprintf("permissions[%s]", nameOrIndex(permission.ID, i))
+
+		v.required(location, permission.Entity)
+		v.knownEnum(location+".type", &permission.Type)
+		v.knownEnum(location+".scope", &permission.Scope)
+	}
+}
+
+func (v *validator) validatePlans() {
+	for i := range v.config.Plans {
+		plan := &v.config.Plans[i]
+		location := fmt.Sprintf("plans[%s]", nameOrIndex(plan.ID, i))
+
+		v.required(location, plan.Entity)
+		v.required(location, plan.PlanUpdate)
+		v.validateFilters(location+".filters", plan.Filters)
+
+		for j := range plan.Quotas {
+			v.knownEnum(fmt.Sprintf("%s.quotas[%d].timeUnit", location, j), &plan.Quotas[j].TimeUnit)
+		}
+	}
+}
+
+func (v *validator) validatePlugins() {
+	ids := make(map[string]bool, len(v.config.Plugins))
+	for i := range v.config.Plugins {
+		plugin := &v.config.Plugins[i]
+		location := fmt.Sprintf("plugins[%s]", nameOrIndex(plugin.ID, i))
+
+		if ids[plugin.ID] {
+			v.errorf(location+".id", "duplicate plugin instance %q", plugin.ID)
+		}
+		ids[plugin.ID] = true
+
+		v.required(location, plugin.Entity)
+		v.required(location, plugin.PluginConfig)
+
+		if !isKnownPlugin(plugin.Name) {
+			v.errorf(location+".name", "unknown plugin %q", plugin.Name)
+		}
+	}
+}
+
+func (v *validator) validateBackend(location string, backendConfig *config.PluginConfig) {
+	if backendConfig == nil {
+		v.errorf(location, "missing required field")
+		return
+	}
+
+	if !backend.HasHandler(backendConfig.Name) {
+		v.errorf(location+".name", "unknown backend %q", backendConfig.Name)
+		return
+	}
+
+	_, err := backend.GetConfig(backendConfig.Name, backendConfig.Properties)
+	if err != nil {
+		v.errorf(location+".properties", "invalid backend configuration: %s", err)
+	}
+}
+
+func (v *validator) validateFilters(location string, filters []config.PluginConfig) {
+	for i := range filters {
+		filterLocation := fmt.Sprintf("%s[%s]", location, nameOrIndex(filters[i].Name, i))
+		filterConfig, ok := v.pluginInstance(filterLocation, &filters[i])
+		if !ok {
+			continue
+		}
+
+		if !filter.HasFilter(filterConfig.Name) {
+			v.errorf(filterLocation, "unknown filter %q", filterConfig.Name)
+			continue
+		}
+
+		_, err := filter.GetConfig(filterConfig)
+		if err != nil {
+			v.errorf(filterLocation+".properties", "invalid filter configuration: %s", err)
+		}
+
+		err = filter.CheckPredicate(filterConfig.When)
+		if err != nil {
+			v.errorf(filterLocation+".when", "invalid predicate: %s", err)
+		}
+	}
+}
+
+func (v *validator) validateAuthenticators(location string, authenticators []config.PluginConfig) {
+	for i := range authenticators {
+		authenticatorLocation := fmt.Sprintf("%s[%s]", location, nameOrIndex(authenticators[i].Name, i))
+		authenticatorConfig, ok := v.pluginInstance(authenticatorLocation, &authenticators[i])
+		if !ok {
+			continue
+		}
+
+		if !authentication.HasAuthenticator(authenticatorConfig.Name) {
+			v.errorf(authenticatorLocation, "unknown authenticator %q", authenticatorConfig.Name)
+			continue
+		}
+
+		_, err := authentication.GetAuthenticatorConfig(authenticatorConfig)
+		if err != nil {
+			v.errorf(authenticatorLocation+".properties", "invalid authenticator configuration: %s", err)
+		}
+	}
+}
+
+// pluginInstance returns pluginConfig with the name and properties of the
+// plugin instance that it references, if any, applied.
+func (v *validator) pluginInstance(location string, pluginConfig *config.PluginConfig) (*config.PluginConfig, bool) {
+	if pluginConfig.PluginID == nil {
+		return pluginConfig, true
+	}
+
+	plugin, ok := v.plugins[*pluginConfig.PluginID]
+	if !ok {
+		v.errorf(location+".pluginId", "unknown plugin instance %q", *pluginConfig.PluginID)
+		return nil, false
+	}
+	if pluginConfig.Name != "" && pluginConfig.Name != plugin.Name {
+		v.errorf(location+".name", "plugin instance %q is a %s plugin", *pluginConfig.PluginID, plugin.Name)
+		return nil, false
+	}
+
+	resolved := *pluginConfig
+	resolved.Name = plugin.Name
+	resolved.Properties = make(map[string]interface{}, len(plugin.Properties)+len(pluginConfig.Properties))
+	for key, value := range plugin.Properties {
+		resolved.Properties[key] = value
+	}
+	for key, value := range pluginConfig.Properties {
+		resolved.Properties[key] = value
+	}
+	return &resolved, true
+}
+
+func (v *validator) validatePermissionIDs(location string, permissionIDs []string) {
+	for _, permissionID := range permissionIDs {
+		// Strip the entity action, if present
+		id := permissionID
+		if index := strings.IndexByte(id, ':'); index != -1 {
+			id = id[0:index]
+		}
+
+		if !v.permissions[id] {
+			v.errorf(location, "unknown permission %q", permissionID)
+		}
+	}
+}
+
+type knowable interface {
+	Known() bool
+	String() string
+}
+
+func (v *validator) knownEnum(location string, value knowable) {
+	// Missing values are reported by required
+	if value.String() != "" && !value.Known() {
+		v.errorf(location, "invalid value %q", value.String())
+	}
+}
+
+// required reports string and pointer fields tagged as valid:"required"
+// that have not been set.  Required collections may be left empty and
+// auditable fields are maintained by the gateway.
+func (v *validator) required(location string, value interface{}) {
+	rv := reflect.ValueOf(value)
+	rt := rv.Type()
+
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		fieldValue := rv.Field(i)
+
+		if field.Anonymous {
+			if field.Type != auditableType && field.Type.Kind() == reflect.Struct {
+				v.required(location, fieldValue.Interface())
+			}
+			continue
+		}
+
+		if field.Tag.Get("valid") != "required" {
+			continue
+		}
+
+		switch fieldValue.Kind() {
+		case reflect.String, reflect.Ptr, reflect.Interface:
+			if isZero(fieldValue) {
+				v.errorf(location+"."+jsonName(field), "missing required field")
+			}
+		}
+	}
+}
+
+func (v *validator) errorf(location, format string, args ...interface{}) {
+	v.problems = append(v.problems, Problem{
+		Severity: SeverityError,
+		Location: location,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func isKnownPlugin(name string) bool {
+	for _, recognizer := range pluginRecognizers {
+		if recognizer(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func isZero(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.String:
+		return value.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return value.IsNil()
+	}
+	return false
+}
+
+func jsonName(field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+func nameOrIndex(name string, index int) string {
+	if name != "" {
+		return name
+	}
+	return fmt.Sprint(index)
+}